@@ -0,0 +1,37 @@
+// Command mint-token prints a bearer token accepted with AUTH_PROVIDER=static, for development and tests.
+//
+//	AUTH_STATIC_SECRET=... go run ./cmd/mint-token -sub 0f3c... -ttl 24h -roles admin
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+)
+
+func main() {
+	subject := flag.String("sub", "", "subject, the streamer id")
+	username := flag.String("username", "", "account name, optional")
+	roles := flag.String("roles", "", "comma separated roles, like admin, optional")
+	ttl := flag.Duration("ttl", time.Hour, "token lifetime")
+	flag.Parse()
+
+	if *subject == "" {
+		log.Fatal("-sub is required")
+	}
+
+	static, err := auth.StaticFromEnv()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	token, err := static.Mint(*subject, *username, auth.SplitList(*roles), *ttl)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	fmt.Println(token)
+}
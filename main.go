@@ -6,15 +6,22 @@ import (
 	"log"
 	"net/http"
 	"os"
+	"strings"
 	"time"
 
 	"github.com/go-chi/chi/v5"
 	"github.com/go-chi/chi/v5/middleware"
 	"github.com/go-chi/cors"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
 	"github.com/vladtenlive/ton-donate/pkg/handlers"
+	"github.com/vladtenlive/ton-donate/pkg/middlewares"
+	"github.com/vladtenlive/ton-donate/pkg/prices"
+	"github.com/vladtenlive/ton-donate/pkg/response"
 	"github.com/vladtenlive/ton-donate/pkg/storage"
 	"github.com/vladtenlive/ton-donate/pkg/ton"
+	"github.com/vladtenlive/ton-donate/pkg/twitch"
 	"github.com/vladtenlive/ton-donate/pkg/utils"
+	tonapi "github.com/xssnick/tonutils-go/ton"
 )
 
 func main() {
@@ -26,32 +33,109 @@ func main() {
 	contractAddress := os.Getenv("CONTRACT_ADDRESS")
 	notificationUrl := os.Getenv("NOTIFICATION_URL")
 
-	mongo, err := storage.NewMongoClient(ctx)
+	var repositories storage.Repositories
+	switch backend := os.Getenv("STORAGE_BACKEND"); backend {
+	case "", storage.BackendMongo:
+		mongo, err := storage.NewMongoClient(ctx)
+		if err != nil {
+			fmt.Println("FAILED TO CONNECT TO MONGO!")
+			panic(err)
+		}
+
+		report, err := mongo.Bootstrap(ctx)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, drift := range report.Drift {
+			log.Println("Mongo index drift:", drift)
+		}
+
+		repositories = mongo.Repositories()
+	case storage.BackendPostgres:
+		postgres, err := storage.NewPostgresClient(ctx)
+		if err != nil {
+			log.Fatalf("Failed to connect to postgres: %v", err)
+		}
+
+		repositories = postgres.Repositories()
+	case storage.BackendMemory:
+		log.Println("Using in-memory storage, data is lost on restart")
+		repositories = storage.NewMemoryStorage().Repositories()
+	default:
+		log.Fatalf("Unknown STORAGE_BACKEND %q, expected %s, %s or %s", backend, storage.BackendMongo, storage.BackendPostgres, storage.BackendMemory)
+	}
+
+	priceProvider, err := prices.NewProviderFromEnv(http.DefaultClient)
 	if err != nil {
-		fmt.Println("FAILED TO CONNECT TO MONGO!")
-		panic(err)
+		log.Fatal(err)
+	}
+
+	// Twitch linking, stream events and chat alerts are enabled when client id is set.
+	var twitchClient *twitch.Client
+	var chat ton.ChatSender
+	if twitchConfig := twitch.ConfigFromEnv(); twitchConfig.ClientId != "" {
+		twitchClient = twitch.NewClient(http.DefaultClient, twitchConfig)
+		if twitchClient.HasChatBot() {
+			chat = twitchClient
+		}
 	}
 
 	n := ton.NewNotifier(http.DefaultClient, notificationUrl)
-	tonConnector, err := ton.New(
-		ctx,
-		contractAddress,
-		nil,
-		mongo,
-		n,
-	)
+
+	// Without liteservers donations are not confirmed and ton_proof is checked by wallet state init only.
+	var tonConnector *ton.Connector
+	var liteClient *tonapi.APIClient
+	if os.Getenv("TON_CONFIG_URL") != "" {
+		tonConnector, err = ton.New(
+			ctx,
+			contractAddress,
+			repositories,
+			n,
+			priceProvider,
+			chat,
+		)
+		if err != nil {
+			log.Fatal(err)
+		}
+		liteClient = tonConnector.Client
+	} else {
+		log.Println("TON_CONFIG_URL is not set, donation transactions are not watched")
+	}
+
+	provider, err := auth.NewFromEnv(ctx)
 	if err != nil {
 		log.Fatal(err)
 	}
+	if os.Getenv("AUTH_PROVIDER") == auth.ProviderStatic {
+		log.Println("Using static key authentication, tokens are minted locally and must not be used in production")
+	}
 
-	auth := utils.NewAuth(context.Background(), &utils.Config{
-		CognitoRegion:     os.Getenv("COGNITO_REGION"),
-		CognitoUserPoolID: os.Getenv("COGNITO_USER_POOL_ID"),
-	})
+	// Sign in with TON wallet is enabled when token secret is set, its tokens are accepted alongside the provider's.
+	var authenticator auth.Authenticator = provider
+	var walletTokens *auth.WalletTokens
+	if secret := os.Getenv("AUTH_TOKEN_SECRET"); secret != "" {
+		walletTokens, err = auth.NewWalletTokens([]byte(secret))
+		if err != nil {
+			log.Fatal(err)
+		}
+		authenticator, err = auth.NewMulti(provider, walletTokens)
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
-	s := handlers.NewService(http.DefaultClient, nil, mongo, auth)
+	proofVerifier := ton.NewProofVerifier(
+		liteClient,
+		repositories.Nonces,
+		os.Getenv("TON_PROOF_SECRET"),
+		strings.Split(os.Getenv("TON_PROOF_DOMAINS"), ","),
+	)
 
-	go tonConnector.Start(ctx, 3*time.Second)
+	s := handlers.NewService(http.DefaultClient, repositories, contractAddress, proofVerifier, twitchClient, walletTokens, n)
+
+	if tonConnector != nil {
+		go tonConnector.Start(ctx, 3*time.Second)
+	}
 
 	cors := cors.New(cors.Options{
 		AllowedOrigins:   []string{"*"},
@@ -75,17 +159,85 @@ func main() {
 		w.Write([]byte("ok"))
 	})
 
+	// Public, viewers paying a streamer are not logged in.
 	r.Group(func(r chi.Router) {
-		r.Get("/streamer", s.GetStreamerHandler)
-		r.Post("/streamer", s.SaveStreamerHandler)
-	})
-	r.Group(func(r chi.Router) {
-		r.Get("/donations", s.GetDonationListHandler)
+		r.Get("/public/streamers/{key}", s.GetPublicStreamerHandler)
 		r.Post("/donations", s.CreateDonationHandler)
+		r.Get("/twitch/callback", s.TwitchCallbackHandler)
+		r.Post("/twitch/eventsub", s.TwitchEventSubHandler)
+
+		r.Post("/auth/ton/payload", s.GetLoginPayloadHandler)
+		r.Post("/auth/ton/login", s.WalletLoginHandler)
+		r.Post("/auth/refresh", s.RefreshTokenHandler)
+		r.Post("/auth/logout", s.LogoutHandler)
 	})
+
+	// Protected, streamer is the principal of the bearer token.
 	r.Group(func(r chi.Router) {
+		r.Use(middlewares.RequireAuth(authenticator))
+		r.Use(middlewares.RejectDisabled(repositories.Streamers))
+
+		r.Get("/streamer", s.GetStreamerHandler)
+		r.Post("/streamer", s.SaveStreamerHandler)
+		r.Post("/streamer/proof-payload", s.GetProofPayloadHandler)
+		r.Get("/streamer/wallets", s.GetWalletsHandler)
+		r.Post("/streamer/wallets", s.AddWalletHandler)
+		r.Delete("/streamer/wallets/{address}", s.RetireWalletHandler)
+
+		r.Get("/twitch/link", s.GetTwitchLinkHandler)
+		r.Delete("/twitch/link", s.UnlinkTwitchHandler)
+
+		r.Get("/donations", s.GetDonationListHandler)
+		r.Get("/donations/top", s.GetTopDonorsHandler)
+		r.Get("/donations/export", s.ExportDonationsHandler)
+
+		r.Get("/analytics", s.GetAnalyticsHandler)
+
+		r.Get("/sessions", s.GetSessionsHandler)
+		r.Post("/sessions", s.StartSessionHandler)
+		r.Get("/sessions/{id}", s.GetSessionSummaryHandler)
+		r.Get("/sessions/{id}/top", s.GetSessionTopDonorsHandler)
+		r.Post("/sessions/{id}/end", s.EndSessionHandler)
+
 		r.Get("/widgets", s.GetWidgetsHandler)
 		r.Post("/widgets", s.CreateWidgetHandler)
+		r.Get("/widgets/{id}", s.GetWidgetHandler)
+		r.Patch("/widgets/{id}", s.UpdateWidgetHandler)
+		r.Delete("/widgets/{id}", s.DeleteWidgetHandler)
+		r.Post("/widgets/{id}/reset", s.ResetGoalHandler)
+		r.Post("/widgets/{id}/archive", s.ArchiveGoalHandler)
+		r.Get("/widgets/{id}/history", s.GetGoalHistoryHandler)
+
+		r.Get("/streamer/moderators", s.GetModeratorsHandler)
+		r.Post("/streamer/moderators", s.AddModeratorHandler)
+		r.Delete("/streamer/moderators/{id}", s.RemoveModeratorHandler)
+		r.Get("/moderation/streamers", s.GetModeratedStreamersHandler)
+
+		r.Route("/streamers/{streamerId}/moderation", func(r chi.Router) {
+			r.Use(middlewares.RequireModerator(repositories.Grants))
+
+			r.Get("/", s.GetModerationQueueHandler)
+			r.Post("/{txHash}", s.ModerateDonationHandler)
+		})
+
+		r.Route("/admin", func(r chi.Router) {
+			r.Use(middlewares.RequireAdmin(repositories.Grants))
+
+			r.Get("/streamers/{key}", s.GetAdminStreamerHandler)
+			r.Post("/streamers/{streamerId}/disable", s.DisableStreamerHandler)
+			r.Post("/streamers/{streamerId}/enable", s.EnableStreamerHandler)
+
+			r.Post("/donations/{txHash}/notify", s.ResendNotificationHandler)
+			r.Post("/donations/{txHash}/moderation", s.AdminModerateDonationHandler)
+
+			r.Get("/admins", s.GetAdminsHandler)
+			r.Put("/admins/{streamerId}", s.AddAdminHandler)
+			r.Delete("/admins/{streamerId}", s.RemoveAdminHandler)
+		})
+	})
+
+	r.NotFound(func(w http.ResponseWriter, r *http.Request) {
+		response.WriteError(w, response.NotFound("Route does not exist."))
 	})
 
 	if err := http.ListenAndServe(":"+port, r); err != nil {
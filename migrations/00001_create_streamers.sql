@@ -0,0 +1,34 @@
+-- +goose Up
+CREATE TABLE streamers (
+    streamer_id         TEXT PRIMARY KEY,
+    cognito_id          TEXT NOT NULL DEFAULT '',
+    wallet_address      TEXT,
+    slug                TEXT,
+    display_name        TEXT NOT NULL DEFAULT '',
+    avatar_url          TEXT NOT NULL DEFAULT '',
+    description         TEXT NOT NULL DEFAULT '',
+    twitch_user_id      TEXT,
+    twitch_login        TEXT,
+    twitch_display_name TEXT,
+    twitch_linked_at    TIMESTAMPTZ
+);
+
+CREATE UNIQUE INDEX streamers_wallet_address_key ON streamers (wallet_address);
+CREATE UNIQUE INDEX streamers_slug_key ON streamers (slug);
+CREATE UNIQUE INDEX streamers_twitch_user_id_key ON streamers (twitch_user_id);
+CREATE INDEX streamers_cognito_id_idx ON streamers (cognito_id);
+
+-- All payout wallets ever registered, a wallet belongs to one streamer only.
+CREATE TABLE streamer_wallets (
+    streamer_id TEXT NOT NULL REFERENCES streamers (streamer_id) ON DELETE CASCADE,
+    address     TEXT NOT NULL UNIQUE,
+    is_active   BOOLEAN NOT NULL DEFAULT TRUE,
+    active_from TIMESTAMPTZ NOT NULL DEFAULT now(),
+    retired_at  TIMESTAMPTZ,
+    position    BIGSERIAL,
+    PRIMARY KEY (streamer_id, address)
+);
+
+-- +goose Down
+DROP TABLE streamer_wallets;
+DROP TABLE streamers;
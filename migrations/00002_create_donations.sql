@@ -0,0 +1,27 @@
+-- +goose Up
+CREATE TABLE donations (
+    id             BIGSERIAL PRIMARY KEY,
+    sign           TEXT NOT NULL UNIQUE,
+    tx_hash        TEXT UNIQUE,
+    streamer_id    TEXT REFERENCES streamers (streamer_id) ON DELETE CASCADE,
+    wallet_address TEXT NOT NULL DEFAULT '',
+    sender_address TEXT NOT NULL DEFAULT '',
+    amount         BIGINT NOT NULL DEFAULT 0,
+    currency       TEXT NOT NULL DEFAULT 'TON',
+    nickname       TEXT NOT NULL DEFAULT '',
+    message        TEXT NOT NULL DEFAULT '',
+    lt             BIGINT NOT NULL DEFAULT 0,
+    verified       BOOLEAN NOT NULL DEFAULT FALSE,
+    acked          BOOLEAN NOT NULL DEFAULT FALSE,
+    fiat_rates     JSONB,
+    session_id     TEXT NOT NULL DEFAULT '',
+    widget_id      TEXT NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ
+);
+
+CREATE INDEX donations_streamer_created_at_idx ON donations (streamer_id, created_at);
+CREATE INDEX donations_streamer_verified_created_at_idx ON donations (streamer_id, verified, created_at);
+CREATE INDEX donations_streamer_session_idx ON donations (streamer_id, session_id);
+
+-- +goose Down
+DROP TABLE donations;
@@ -0,0 +1,26 @@
+-- +goose Up
+CREATE TABLE widgets (
+    id              TEXT PRIMARY KEY,
+    streamer_id     TEXT NOT NULL REFERENCES streamers (streamer_id) ON DELETE CASCADE,
+    type            TEXT NOT NULL,
+    title           TEXT NOT NULL DEFAULT '',
+    amount_goal     BIGINT NOT NULL DEFAULT 0,
+    amount_current  BIGINT NOT NULL DEFAULT 0,
+    donations_count BIGINT NOT NULL DEFAULT 0,
+    is_active       BOOLEAN NOT NULL DEFAULT FALSE,
+    currency        TEXT NOT NULL DEFAULT '',
+    config          JSONB NOT NULL DEFAULT '{}',
+    starts_at       TIMESTAMPTZ,
+    ends_at         TIMESTAMPTZ,
+    started_at      TIMESTAMPTZ,
+    is_completed    BOOLEAN NOT NULL DEFAULT FALSE,
+    completed_at    TIMESTAMPTZ,
+    is_archived     BOOLEAN NOT NULL DEFAULT FALSE,
+    history         JSONB NOT NULL DEFAULT '[]',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT now()
+);
+
+CREATE INDEX widgets_streamer_type_idx ON widgets (streamer_id, type);
+
+-- +goose Down
+DROP TABLE widgets;
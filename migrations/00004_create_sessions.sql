@@ -0,0 +1,18 @@
+-- +goose Up
+-- Sessions can be started before the streamer registers a wallet, so there is no foreign key.
+CREATE TABLE sessions (
+    id          TEXT PRIMARY KEY,
+    streamer_id TEXT NOT NULL,
+    title       TEXT NOT NULL DEFAULT '',
+    source      TEXT NOT NULL,
+    external_id TEXT NOT NULL DEFAULT '',
+    started_at  TIMESTAMPTZ NOT NULL,
+    ended_at    TIMESTAMPTZ
+);
+
+CREATE INDEX sessions_streamer_started_at_idx ON sessions (streamer_id, started_at DESC);
+-- A streamer has at most one active session.
+CREATE UNIQUE INDEX sessions_streamer_active_key ON sessions (streamer_id) WHERE ended_at IS NULL;
+
+-- +goose Down
+DROP TABLE sessions;
@@ -0,0 +1,12 @@
+-- +goose Up
+-- Transactions reusing the sign of a donation confirmed by another transaction
+-- are stored as separate donations without sign, pointing to the original one.
+ALTER TABLE donations ALTER COLUMN sign DROP NOT NULL;
+ALTER TABLE donations ADD COLUMN duplicate_of TEXT;
+ALTER TABLE donations ADD CONSTRAINT donations_sign_check CHECK ((sign IS NULL) <> (duplicate_of IS NULL));
+
+-- +goose Down
+DELETE FROM donations WHERE sign IS NULL;
+ALTER TABLE donations DROP CONSTRAINT donations_sign_check;
+ALTER TABLE donations DROP COLUMN duplicate_of;
+ALTER TABLE donations ALTER COLUMN sign SET NOT NULL;
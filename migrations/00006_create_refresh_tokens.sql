@@ -0,0 +1,14 @@
+-- +goose Up
+-- Refresh tokens of streamers signed in with a TON wallet, stored by hash.
+CREATE TABLE refresh_tokens (
+    hash           TEXT PRIMARY KEY,
+    streamer_id    TEXT NOT NULL,
+    wallet_address TEXT NOT NULL,
+    created_at     TIMESTAMPTZ NOT NULL,
+    expires_at     TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX refresh_tokens_expires_at_idx ON refresh_tokens (expires_at);
+
+-- +goose Down
+DROP TABLE refresh_tokens;
@@ -0,0 +1,40 @@
+-- +goose Up
+ALTER TABLE streamers
+    ADD COLUMN disabled        BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN disabled_reason TEXT NOT NULL DEFAULT '';
+
+-- Donation is moderated once status is set.
+ALTER TABLE donations
+    ADD COLUMN moderation_status TEXT CHECK (moderation_status IN ('approved', 'hidden')),
+    ADD COLUMN moderation_reason TEXT NOT NULL DEFAULT '',
+    ADD COLUMN moderated_by      TEXT NOT NULL DEFAULT '',
+    ADD COLUMN moderated_at      TIMESTAMPTZ;
+
+CREATE INDEX donations_streamer_moderation_queue_idx ON donations (streamer_id, lt DESC)
+    WHERE verified AND message <> '' AND moderation_status IS NULL;
+
+-- Moderator grants are scoped to a streamer, admin grants have empty streamer_id.
+CREATE TABLE grants (
+    role        TEXT NOT NULL CHECK (role IN ('moderator', 'admin')),
+    streamer_id TEXT NOT NULL DEFAULT '',
+    grantee_id  TEXT NOT NULL,
+    granted_by  TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMPTZ NOT NULL,
+    PRIMARY KEY (role, streamer_id, grantee_id)
+);
+
+CREATE INDEX grants_grantee_id_idx ON grants (grantee_id);
+CREATE INDEX refresh_tokens_streamer_id_idx ON refresh_tokens (streamer_id);
+
+-- +goose Down
+DROP INDEX refresh_tokens_streamer_id_idx;
+DROP TABLE grants;
+DROP INDEX donations_streamer_moderation_queue_idx;
+ALTER TABLE donations
+    DROP COLUMN moderation_status,
+    DROP COLUMN moderation_reason,
+    DROP COLUMN moderated_by,
+    DROP COLUMN moderated_at;
+ALTER TABLE streamers
+    DROP COLUMN disabled,
+    DROP COLUMN disabled_reason;
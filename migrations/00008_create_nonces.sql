@@ -0,0 +1,11 @@
+-- +goose Up
+-- Single-use values: issued ton_proof payloads by hash and ids of received webhooks.
+CREATE TABLE nonces (
+    key        TEXT PRIMARY KEY,
+    expires_at TIMESTAMPTZ NOT NULL
+);
+
+CREATE INDEX nonces_expires_at_idx ON nonces (expires_at);
+
+-- +goose Down
+DROP TABLE nonces;
@@ -0,0 +1,6 @@
+-- +goose Up
+-- Earlier active periods of reactivated wallets, as [{"active_from": ..., "retired_at": ...}].
+ALTER TABLE streamer_wallets ADD COLUMN periods JSONB NOT NULL DEFAULT '[]';
+
+-- +goose Down
+ALTER TABLE streamer_wallets DROP COLUMN periods;
@@ -0,0 +1,78 @@
+// Package auth verifies bearer tokens and carries the authenticated principal in request context.
+package auth
+
+import (
+	"context"
+	"errors"
+	"strings"
+)
+
+// ErrNoToken is returned when request has no bearer token at all.
+var ErrNoToken = errors.New("authorization bearer token is missing")
+
+// InvalidTokenError describes why a token was rejected, the reason is safe to show to the client.
+type InvalidTokenError struct {
+	Reason string
+}
+
+func (e *InvalidTokenError) Error() string {
+	return "invalid token: " + e.Reason
+}
+
+func invalidToken(reason string) error {
+	return &InvalidTokenError{Reason: reason}
+}
+
+// Principal is the authenticated caller.
+type Principal struct {
+	// StreamerId is the subject of the identity provider account unless the provider maps another claim.
+	StreamerId string
+	// Subject is the account id at the identity provider.
+	Subject  string
+	Username string
+	// Roles are identity provider groups, like admin, see package roles. Stored grants add to them.
+	Roles []string
+}
+
+func (p *Principal) HasRole(role string) bool {
+	return contains(p.Roles, role)
+}
+
+// Authenticator verifies a bearer token and returns whom it was issued to.
+type Authenticator interface {
+	Authenticate(ctx context.Context, token string) (*Principal, error)
+}
+
+// BearerToken extracts token from "Authorization: Bearer <token>" header value.
+func BearerToken(header string) (string, error) {
+	if header == "" {
+		return "", ErrNoToken
+	}
+
+	scheme, token, found := strings.Cut(strings.TrimSpace(header), " ")
+	if !found || !strings.EqualFold(scheme, "Bearer") || strings.TrimSpace(token) == "" {
+		return "", invalidToken("authorization header must be 'Bearer <token>'")
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, principal *Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, principal)
+}
+
+// PrincipalFrom returns the principal authenticated by middleware, nil on public routes.
+func PrincipalFrom(ctx context.Context) *Principal {
+	principal, _ := ctx.Value(principalKey{}).(*Principal)
+	return principal
+}
+
+// StreamerId returns streamer id of the authenticated principal, empty on public routes.
+func StreamerId(ctx context.Context) string {
+	if principal := PrincipalFrom(ctx); principal != nil {
+		return principal.StreamerId
+	}
+	return ""
+}
@@ -0,0 +1,67 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+)
+
+type CognitoConfig struct {
+	Region     string
+	UserPoolId string
+	// App clients of the user pool whose tokens are accepted.
+	ClientIds []string
+}
+
+// Cognito authenticates AWS Cognito user pool id and access tokens.
+type Cognito struct {
+	verifier
+	clientIds []string
+}
+
+// NewCognito starts without waiting for the user pool keys, they are refreshed until ctx is done.
+func NewCognito(ctx context.Context, config CognitoConfig) *Cognito {
+	issuer := fmt.Sprintf("https://cognito-idp.%s.amazonaws.com/%s", config.Region, config.UserPoolId)
+	return &Cognito{
+		verifier: verifier{
+			issuer:  issuer,
+			methods: []string{"RS256"},
+			key:     keySetKey(NewKeySet(ctx, issuer+"/.well-known/jwks.json")),
+		},
+		clientIds: config.ClientIds,
+	}
+}
+
+func (c *Cognito) Authenticate(ctx context.Context, tokenString string) (*Principal, error) {
+	claims, err := c.parse(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	// Id tokens name the app client in aud, access tokens in client_id.
+	var clientId, username string
+	switch stringClaim(claims, "token_use") {
+	case "id":
+		audience, _ := claims.GetAudience()
+		if len(audience) == 1 {
+			clientId = audience[0]
+		}
+		username = stringClaim(claims, "cognito:username")
+	case "access":
+		clientId = stringClaim(claims, "client_id")
+		username = stringClaim(claims, "username")
+	default:
+		return nil, invalidToken("token_use must be id or access")
+	}
+
+	if !contains(c.clientIds, clientId) {
+		return nil, invalidToken("token was issued to another client")
+	}
+
+	subject, _ := claims.GetSubject()
+	return &Principal{
+		StreamerId: subject,
+		Subject:    subject,
+		Username:   username,
+		Roles:      stringsClaim(claims, "cognito:groups"),
+	}, nil
+}
@@ -0,0 +1,103 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+func TestCognitoAuthenticate(t *testing.T) {
+	key := newTestKey(t)
+	cognito := &Cognito{
+		verifier:  newTestVerifier(key),
+		clientIds: []string{"web-client", "obs-client"},
+	}
+
+	tests := []struct {
+		name      string
+		claims    jwt.MapClaims
+		principal *Principal
+		reason    string
+	}{
+		{
+			name: "id token",
+			claims: withClaims(jwt.MapClaims{
+				"token_use":        "id",
+				"aud":              "web-client",
+				"cognito:username": "alice",
+				"cognito:groups":   []string{"admin"},
+			}),
+			principal: &Principal{StreamerId: "streamer-1", Subject: "streamer-1", Username: "alice", Roles: []string{"admin"}},
+		},
+		{
+			name: "access token",
+			claims: withClaims(jwt.MapClaims{
+				"token_use": "access",
+				"client_id": "obs-client",
+				"username":  "alice",
+			}),
+			principal: &Principal{StreamerId: "streamer-1", Subject: "streamer-1", Username: "alice"},
+		},
+		{
+			name:   "missing token_use",
+			claims: withClaims(jwt.MapClaims{"client_id": "web-client"}),
+			reason: "token_use must be id or access",
+		},
+		{
+			name:   "refresh token_use",
+			claims: withClaims(jwt.MapClaims{"token_use": "refresh", "client_id": "web-client"}),
+			reason: "token_use must be id or access",
+		},
+		{
+			name:   "access token of another client",
+			claims: withClaims(jwt.MapClaims{"token_use": "access", "client_id": "other-client"}),
+			reason: "token was issued to another client",
+		},
+		{
+			name:   "access token without client_id",
+			claims: withClaims(jwt.MapClaims{"token_use": "access", "aud": "web-client"}),
+			reason: "token was issued to another client",
+		},
+		{
+			name:   "id token of another client",
+			claims: withClaims(jwt.MapClaims{"token_use": "id", "aud": "other-client"}),
+			reason: "token was issued to another client",
+		},
+		{
+			name:   "id token with client_id only",
+			claims: withClaims(jwt.MapClaims{"token_use": "id", "client_id": "web-client"}),
+			reason: "token was issued to another client",
+		},
+		{
+			name:   "id token for several audiences",
+			claims: withClaims(jwt.MapClaims{"token_use": "id", "aud": []string{"web-client", "other-client"}}),
+			reason: "token was issued to another client",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			principal, err := cognito.Authenticate(context.Background(), signToken(t, jwt.SigningMethodRS256, key, test.claims))
+			if test.reason == "" {
+				if err != nil {
+					t.Fatalf("expected token to be accepted, got %v", err)
+				}
+				if !reflect.DeepEqual(principal, test.principal) {
+					t.Fatalf("expected principal %+v, got %+v", test.principal, principal)
+				}
+				return
+			}
+
+			var invalid *InvalidTokenError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected InvalidTokenError, got %v", err)
+			}
+			if invalid.Reason != test.reason {
+				t.Fatalf("expected reason %q, got %q", test.reason, invalid.Reason)
+			}
+		})
+	}
+}
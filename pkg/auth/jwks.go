@@ -0,0 +1,175 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+const (
+	// Providers publish upcoming keys ahead of rotation, hourly refresh picks them up in time.
+	keySetRefreshInterval = time.Hour
+	// Retry sooner while no key set has been loaded yet.
+	keySetRetryInterval = 30 * time.Second
+	// Tokens with unknown kid trigger a refetch at most this often, so they cannot flood the provider.
+	keySetMinRefetchInterval = time.Minute
+	// Bounds discovery and JWKS requests, an unreachable provider must not block startup or the refresh loop.
+	keySetFetchTimeout = 10 * time.Second
+)
+
+// ErrKeysUnavailable is returned while the signing keys could not be loaded yet, the token may still be valid.
+var ErrKeysUnavailable = errors.New("token signing keys are unavailable")
+
+// KeySet is a JWKS kept fresh in background and refetched when a token is signed by an unknown key.
+type KeySet struct {
+	// issuer is set for key sets located by OpenID Connect discovery.
+	issuer string
+
+	mu        sync.RWMutex
+	url       string
+	set       jwk.Set
+	fetchedAt time.Time
+
+	// fetchMu lets a single request refetch keys while others wait for its result.
+	fetchMu sync.Mutex
+}
+
+// NewKeySet loads the key set from url and refreshes it until ctx is done. Failure to load
+// is only logged, keys are retried in background and on demand.
+func NewKeySet(ctx context.Context, url string) *KeySet {
+	k := &KeySet{url: url}
+	k.start(ctx)
+	return k
+}
+
+// NewDiscoveredKeySet is NewKeySet with url taken from the OpenID Connect discovery document of issuer.
+func NewDiscoveredKeySet(ctx context.Context, issuer string) *KeySet {
+	k := &KeySet{issuer: issuer}
+	k.start(ctx)
+	return k
+}
+
+func (k *KeySet) start(ctx context.Context) {
+	if err := k.fetch(ctx); err != nil {
+		log.Printf("Failed to load JWKS of %s, will retry: %v", k.name(), err)
+	}
+
+	go k.refreshLoop(ctx)
+}
+
+// Key returns the raw public key with id kid.
+func (k *KeySet) Key(ctx context.Context, kid string) (interface{}, error) {
+	key, loaded := k.lookup(kid)
+	if key == nil {
+		key, loaded = k.refetch(ctx, kid)
+	}
+
+	if key == nil {
+		if !loaded {
+			return nil, ErrKeysUnavailable
+		}
+		return nil, fmt.Errorf("unable to find key %q", kid)
+	}
+
+	var publicKey interface{}
+	if err := key.Raw(&publicKey); err != nil {
+		return nil, errors.New("failed to create token key")
+	}
+
+	return publicKey, nil
+}
+
+// lookup also reports whether any key set is loaded.
+func (k *KeySet) lookup(kid string) (jwk.Key, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+
+	if k.set == nil {
+		return nil, false
+	}
+	key, _ := k.set.LookupKeyID(kid)
+	return key, true
+}
+
+// refetch loads the key set again unless it was fetched recently, in case kid is a freshly rotated key.
+func (k *KeySet) refetch(ctx context.Context, kid string) (jwk.Key, bool) {
+	k.fetchMu.Lock()
+	defer k.fetchMu.Unlock()
+
+	// Another request could have fetched it while we waited.
+	k.mu.RLock()
+	recent := time.Since(k.fetchedAt) < keySetMinRefetchInterval
+	k.mu.RUnlock()
+	if !recent {
+		if err := k.fetch(ctx); err != nil {
+			log.Printf("Failed to refetch JWKS of %s: %v", k.name(), err)
+		}
+	}
+
+	return k.lookup(kid)
+}
+
+// fetch replaces the key set, keeping the previous one when loading fails.
+func (k *KeySet) fetch(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, keySetFetchTimeout)
+	defer cancel()
+
+	k.mu.RLock()
+	url := k.url
+	k.mu.RUnlock()
+
+	var err error
+	if url == "" {
+		url, err = discoverKeySetURL(ctx, k.issuer)
+	}
+
+	var set jwk.Set
+	if err == nil {
+		set, err = jwk.Fetch(ctx, url)
+	}
+
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	k.fetchedAt = time.Now()
+	if err != nil {
+		return err
+	}
+	if k.url == "" {
+		k.url = url
+	}
+	k.set = set
+	return nil
+}
+
+// name identifies the key set in logs.
+func (k *KeySet) name() string {
+	if k.issuer != "" {
+		return k.issuer
+	}
+	return k.url
+}
+
+func (k *KeySet) refreshLoop(ctx context.Context) {
+	for {
+		interval := keySetRefreshInterval
+		if _, loaded := k.lookup(""); !loaded {
+			interval = keySetRetryInterval
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(interval):
+		}
+
+		if err := k.fetch(ctx); err != nil {
+			log.Printf("Failed to refresh JWKS of %s: %v", k.name(), err)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package auth
+
+import (
+	"context"
+	"crypto/rsa"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+	"github.com/lestrrat-go/jwx/jwk"
+)
+
+// jwksServer serves the public keys of the current set, keys can be rotated or the server taken down.
+type jwksServer struct {
+	*httptest.Server
+
+	mu   sync.Mutex
+	keys map[string]*rsa.PrivateKey
+	down bool
+}
+
+func newJWKSServer(t *testing.T, keys map[string]*rsa.PrivateKey) *jwksServer {
+	t.Helper()
+
+	s := &jwksServer{keys: keys}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		s.mu.Lock()
+		defer s.mu.Unlock()
+
+		if s.down {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+
+		set := jwk.NewSet()
+		for kid, key := range s.keys {
+			public, err := jwk.New(&key.PublicKey)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			public.Set(jwk.KeyIDKey, kid)
+			set.Add(public)
+		}
+		json.NewEncoder(w).Encode(set)
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *jwksServer) setKeys(keys map[string]*rsa.PrivateKey, down bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys, s.down = keys, down
+}
+
+// expire makes the key set look fetched long ago, so that it can be refetched right away.
+func expire(k *KeySet) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.fetchedAt = time.Time{}
+}
+
+func TestKeySetKey(t *testing.T) {
+	first := newTestKey(t)
+	rotated := newTestKey(t)
+
+	tests := []struct {
+		name string
+		// prepare changes the served keys after the key set is loaded.
+		prepare func(s *jwksServer, k *KeySet)
+		down    bool
+		kid     string
+		key     *rsa.PrivateKey
+		err     error
+	}{
+		{
+			name: "known key",
+			kid:  "first",
+			key:  first,
+		},
+		{
+			name: "rotated key is refetched",
+			prepare: func(s *jwksServer, k *KeySet) {
+				s.setKeys(map[string]*rsa.PrivateKey{"first": first, "rotated": rotated}, false)
+				expire(k)
+			},
+			kid: "rotated",
+			key: rotated,
+		},
+		{
+			name: "unknown key is not refetched too often",
+			prepare: func(s *jwksServer, k *KeySet) {
+				s.setKeys(map[string]*rsa.PrivateKey{"first": first, "rotated": rotated}, false)
+			},
+			kid: "rotated",
+		},
+		{
+			// Keys stay loaded, so the kid is unknown rather than keys unavailable.
+			name: "failed refetch keeps loaded keys",
+			prepare: func(s *jwksServer, k *KeySet) {
+				s.setKeys(nil, true)
+				expire(k)
+			},
+			kid: "rotated",
+		},
+		{
+			name: "keys were never loaded",
+			down: true,
+			kid:  "first",
+			err:  ErrKeysUnavailable,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			s := newJWKSServer(t, map[string]*rsa.PrivateKey{"first": first})
+			s.setKeys(s.keys, test.down)
+			k := NewKeySet(ctx, s.URL)
+			if test.prepare != nil {
+				test.prepare(s, k)
+			}
+
+			key, err := k.Key(ctx, test.kid)
+			switch {
+			case test.err != nil:
+				if !errors.Is(err, test.err) {
+					t.Fatalf("expected %v, got %v", test.err, err)
+				}
+			case test.key == nil:
+				if err == nil || errors.Is(err, ErrKeysUnavailable) {
+					t.Fatalf("expected unknown key error, got %v", err)
+				}
+			default:
+				if err != nil {
+					t.Fatal(err)
+				}
+				if public, ok := key.(*rsa.PublicKey); !ok || !public.Equal(&test.key.PublicKey) {
+					t.Fatalf("expected public key of %s, got %v", test.kid, key)
+				}
+			}
+		})
+	}
+}
+
+func TestVerifierParseKeysUnavailable(t *testing.T) {
+	key := newTestKey(t)
+	v := newTestVerifier(key)
+	v.key = func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+		return nil, ErrKeysUnavailable
+	}
+
+	_, err := v.parse(context.Background(), signToken(t, jwt.SigningMethodRS256, key, validClaims()))
+	if !errors.Is(err, ErrKeysUnavailable) {
+		t.Fatalf("expected ErrKeysUnavailable, got %v", err)
+	}
+}
@@ -0,0 +1,53 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// IssuerAuthenticator is an authenticator of tokens by a single issuer.
+type IssuerAuthenticator interface {
+	Authenticator
+	Issuer() string
+}
+
+func (v *verifier) Issuer() string {
+	return v.issuer
+}
+
+// Multi accepts tokens of several issuers, for example Cognito and wallet sign-in,
+// passing each token to the authenticator of its iss claim.
+type Multi struct {
+	byIssuer map[string]Authenticator
+}
+
+// NewMulti fails when two authenticators share an issuer, one of them would accept tokens of the other.
+func NewMulti(authenticators ...IssuerAuthenticator) (*Multi, error) {
+	m := &Multi{byIssuer: map[string]Authenticator{}}
+	for _, authenticator := range authenticators {
+		issuer := authenticator.Issuer()
+		if _, ok := m.byIssuer[issuer]; ok {
+			return nil, fmt.Errorf("several authenticators of issuer %q", issuer)
+		}
+		m.byIssuer[issuer] = authenticator
+	}
+	return m, nil
+}
+
+func (m *Multi) Authenticate(ctx context.Context, tokenString string) (*Principal, error) {
+	// Claims are not trusted here, the chosen authenticator verifies the token in full.
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(tokenString, claims); err != nil {
+		return nil, invalidToken("token is malformed")
+	}
+
+	issuer, _ := claims.GetIssuer()
+	authenticator, ok := m.byIssuer[issuer]
+	if !ok {
+		return nil, invalidToken("token was issued by another identity provider")
+	}
+
+	return authenticator.Authenticate(ctx, tokenString)
+}
@@ -0,0 +1,131 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// OIDCConfig describes any OpenID Connect provider: Auth0, Keycloak, Cognito or a self hosted one.
+type OIDCConfig struct {
+	// Issuer as in the iss claim, keys are located by its discovery document.
+	Issuer string
+	// Audiences accepted in aud, or in azp for providers issuing access tokens to another audience.
+	Audiences []string
+	// StreamerIdClaim keys streamers, "sub" when empty. Auth0 rules may put a namespaced claim instead.
+	StreamerIdClaim string
+	// UsernameClaim is shown as the account name, "preferred_username" when empty.
+	UsernameClaim string
+	// RolesClaim lists groups of the account, "roles" when empty. Keycloak and Auth0 need a mapper to add it.
+	RolesClaim string
+}
+
+// OIDC authenticates tokens of a generic OpenID Connect provider.
+type OIDC struct {
+	verifier
+	config OIDCConfig
+}
+
+// NewOIDC starts without waiting for discovery, keys are refreshed until ctx is done.
+func NewOIDC(ctx context.Context, config OIDCConfig) (*OIDC, error) {
+	if config.Issuer == "" {
+		return nil, errors.New("OIDC issuer is not set")
+	} else if len(config.Audiences) == 0 {
+		return nil, errors.New("OIDC audiences are not set")
+	}
+
+	if config.StreamerIdClaim == "" {
+		config.StreamerIdClaim = "sub"
+	}
+	if config.UsernameClaim == "" {
+		config.UsernameClaim = "preferred_username"
+	}
+	if config.RolesClaim == "" {
+		config.RolesClaim = "roles"
+	}
+
+	return &OIDC{
+		verifier: verifier{
+			issuer:  config.Issuer,
+			methods: []string{"RS256", "RS384", "RS512", "ES256", "ES384", "ES512", "PS256", "PS384", "PS512"},
+			key:     keySetKey(NewDiscoveredKeySet(ctx, config.Issuer)),
+		},
+		config: config,
+	}, nil
+}
+
+func (o *OIDC) Authenticate(ctx context.Context, tokenString string) (*Principal, error) {
+	claims, err := o.parse(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	if !o.acceptsAudience(claims) {
+		return nil, invalidToken("token was issued to another audience")
+	}
+
+	streamerId := stringClaim(claims, o.config.StreamerIdClaim)
+	if streamerId == "" {
+		return nil, invalidToken("token has no " + o.config.StreamerIdClaim + " claim")
+	}
+
+	subject, _ := claims.GetSubject()
+	return &Principal{
+		StreamerId: streamerId,
+		Subject:    subject,
+		Username:   stringClaim(claims, o.config.UsernameClaim),
+		Roles:      stringsClaim(claims, o.config.RolesClaim),
+	}, nil
+}
+
+func (o *OIDC) acceptsAudience(claims jwt.MapClaims) bool {
+	audience, _ := claims.GetAudience()
+	for _, aud := range audience {
+		if contains(o.config.Audiences, aud) {
+			return true
+		}
+	}
+	return contains(o.config.Audiences, stringClaim(claims, "azp"))
+}
+
+type discoveryDocument struct {
+	Issuer  string `json:"issuer"`
+	JWKSURL string `json:"jwks_uri"`
+}
+
+// discoverKeySetURL reads jwks_uri from the issuer's /.well-known/openid-configuration.
+func discoverKeySetURL(ctx context.Context, issuer string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.TrimSuffix(issuer, "/")+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch discovery document: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to fetch discovery document (status = %d)", resp.StatusCode)
+	}
+
+	var document discoveryDocument
+	if err := json.NewDecoder(resp.Body).Decode(&document); err != nil {
+		return "", fmt.Errorf("failed to decode discovery document: %w", err)
+	}
+
+	// Tokens are checked against configured issuer, a mismatch means wrong configuration.
+	if document.Issuer != issuer {
+		return "", fmt.Errorf("discovery document is of issuer %q, expected %q", document.Issuer, issuer)
+	} else if document.JWKSURL == "" {
+		return "", errors.New("discovery document has no jwks_uri")
+	}
+
+	return document.JWKSURL, nil
+}
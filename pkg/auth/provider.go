@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// Identity providers selectable by AUTH_PROVIDER.
+const (
+	ProviderCognito = "cognito"
+	ProviderOIDC    = "oidc"
+	ProviderStatic  = "static"
+)
+
+// NewFromEnv creates authenticator set by AUTH_PROVIDER, Cognito when empty.
+func NewFromEnv(ctx context.Context) (IssuerAuthenticator, error) {
+	provider, err := newProvider(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	// Tokens are routed by issuer, see Multi, wallet tokens must not be verified by the provider.
+	if provider.Issuer() == WalletIssuer {
+		return nil, fmt.Errorf("issuer %q is reserved for sign in with TON wallet", WalletIssuer)
+	}
+	return provider, nil
+}
+
+func newProvider(ctx context.Context) (IssuerAuthenticator, error) {
+	switch provider := os.Getenv("AUTH_PROVIDER"); provider {
+	case "", ProviderCognito:
+		return NewCognito(ctx, CognitoConfig{
+			Region:     os.Getenv("COGNITO_REGION"),
+			UserPoolId: os.Getenv("COGNITO_USER_POOL_ID"),
+			ClientIds:  SplitList(os.Getenv("COGNITO_CLIENT_IDS")),
+		}), nil
+	case ProviderOIDC:
+		oidc, err := NewOIDC(ctx, OIDCConfig{
+			Issuer:          os.Getenv("OIDC_ISSUER"),
+			Audiences:       SplitList(os.Getenv("OIDC_AUDIENCES")),
+			StreamerIdClaim: os.Getenv("OIDC_STREAMER_ID_CLAIM"),
+			UsernameClaim:   os.Getenv("OIDC_USERNAME_CLAIM"),
+			RolesClaim:      os.Getenv("OIDC_ROLES_CLAIM"),
+		})
+		if err != nil {
+			return nil, err
+		}
+		return oidc, nil
+	case ProviderStatic:
+		static, err := StaticFromEnv()
+		if err != nil {
+			return nil, err
+		}
+		return static, nil
+	default:
+		return nil, fmt.Errorf("unknown AUTH_PROVIDER %q, expected %s, %s or %s", provider, ProviderCognito, ProviderOIDC, ProviderStatic)
+	}
+}
+
+// StaticFromEnv creates static key authenticator from AUTH_STATIC_SECRET and AUTH_STATIC_ISSUER.
+func StaticFromEnv() (*Static, error) {
+	return NewStatic(os.Getenv("AUTH_STATIC_ISSUER"), []byte(os.Getenv("AUTH_STATIC_SECRET")))
+}
+
+// SplitList splits comma separated values skipping empty ones.
+func SplitList(value string) []string {
+	var values []string
+	for _, v := range strings.Split(value, ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			values = append(values, v)
+		}
+	}
+	return values
+}
@@ -0,0 +1,76 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// DefaultStaticIssuer is the issuer of locally minted tokens unless configured otherwise.
+const DefaultStaticIssuer = "ton-donate-local"
+
+// Shorter secrets are brute forceable, HS256 wants at least 256 bits.
+const minSecretLength = 32
+
+// Static authenticates HS256 tokens signed by a shared secret, for development and tests
+// where no identity provider is available. Mint creates such tokens.
+type Static struct {
+	verifier
+	secret []byte
+}
+
+func NewStatic(issuer string, secret []byte) (*Static, error) {
+	if len(secret) < minSecretLength {
+		return nil, errors.New("static auth secret must be at least 32 bytes")
+	}
+	if issuer == "" {
+		issuer = DefaultStaticIssuer
+	}
+
+	return &Static{
+		verifier: verifier{
+			issuer:  issuer,
+			methods: []string{"HS256"},
+			key: func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+				return secret, nil
+			},
+		},
+		secret: secret,
+	}, nil
+}
+
+func (s *Static) Authenticate(ctx context.Context, tokenString string) (*Principal, error) {
+	claims, err := s.parse(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims.GetSubject()
+	return &Principal{
+		StreamerId: subject,
+		Subject:    subject,
+		Username:   stringClaim(claims, "preferred_username"),
+		Roles:      stringsClaim(claims, "roles"),
+	}, nil
+}
+
+// Mint signs a token for subject valid for ttl.
+func (s *Static) Mint(subject string, username string, roles []string, ttl time.Duration) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss": s.issuer,
+		"sub": subject,
+		"iat": now.Unix(),
+		"exp": now.Add(ttl).Unix(),
+	}
+	if username != "" {
+		claims["preferred_username"] = username
+	}
+	if len(roles) > 0 {
+		claims["roles"] = roles
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.secret)
+}
@@ -0,0 +1,108 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// Identity provider clocks and ours may disagree slightly.
+const clockLeeway = 30 * time.Second
+
+// verifier checks what every token must satisfy regardless of provider: signature, issuer,
+// expiration and subject.
+type verifier struct {
+	issuer  string
+	methods []string
+	// key returns the verification key for a token, a *KeySet lookup by kid for asymmetric providers.
+	key func(ctx context.Context, token *jwt.Token) (interface{}, error)
+}
+
+func (v *verifier) parse(ctx context.Context, tokenString string) (jwt.MapClaims, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		return v.key(ctx, token)
+	},
+		jwt.WithValidMethods(v.methods),
+		jwt.WithIssuer(v.issuer),
+		jwt.WithLeeway(clockLeeway))
+	if errors.Is(err, ErrKeysUnavailable) {
+		return nil, err
+	} else if err != nil {
+		return nil, invalidToken(tokenReason(err))
+	}
+
+	// Parser checks expiration only when the claim is present.
+	if exp, err := claims.GetExpirationTime(); err != nil || exp == nil {
+		return nil, invalidToken("token has no expiration time")
+	}
+
+	if subject, err := claims.GetSubject(); err != nil || subject == "" {
+		return nil, invalidToken("token has no subject")
+	}
+
+	return claims, nil
+}
+
+// keySetKey looks up the token key by its kid header.
+func keySetKey(keys *KeySet) func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+	return func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+		kid, ok := token.Header["kid"].(string)
+		if !ok {
+			return nil, errors.New("kid header not found")
+		}
+		return keys.Key(ctx, kid)
+	}
+}
+
+// tokenReason tells the client why the token was rejected without echoing parser internals.
+func tokenReason(err error) string {
+	switch {
+	case errors.Is(err, jwt.ErrTokenExpired):
+		return "token is expired"
+	case errors.Is(err, jwt.ErrTokenNotValidYet), errors.Is(err, jwt.ErrTokenUsedBeforeIssued):
+		return "token is not valid yet"
+	case errors.Is(err, jwt.ErrTokenInvalidIssuer):
+		return "token was issued by another identity provider"
+	case errors.Is(err, jwt.ErrTokenRequiredClaimMissing):
+		return "token is missing a required claim"
+	case errors.Is(err, jwt.ErrTokenMalformed):
+		return "token is malformed"
+	default:
+		return "token signature is invalid"
+	}
+}
+
+// stringClaim returns a string claim, empty when missing or not a string.
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// stringsClaim returns a claim which is a list of strings or a single string.
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
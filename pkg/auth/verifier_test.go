@@ -0,0 +1,173 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"testing"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+func newTestKey(t *testing.T) *rsa.PrivateKey {
+	t.Helper()
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+// newTestVerifier accepts RS256 tokens of testIssuer signed by key.
+func newTestVerifier(key *rsa.PrivateKey) verifier {
+	return verifier{
+		issuer:  testIssuer,
+		methods: []string{"RS256"},
+		key: func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+			return &key.PublicKey, nil
+		},
+	}
+}
+
+func signToken(t *testing.T, method jwt.SigningMethod, key interface{}, claims jwt.MapClaims) string {
+	t.Helper()
+
+	token, err := jwt.NewWithClaims(method, claims).SignedString(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return token
+}
+
+func validClaims() jwt.MapClaims {
+	return jwt.MapClaims{
+		"iss": testIssuer,
+		"sub": "streamer-1",
+		"exp": time.Now().Add(time.Hour).Unix(),
+	}
+}
+
+func withClaims(changes jwt.MapClaims) jwt.MapClaims {
+	claims := validClaims()
+	for name, value := range changes {
+		if value == nil {
+			delete(claims, name)
+		} else {
+			claims[name] = value
+		}
+	}
+	return claims
+}
+
+func TestVerifierParse(t *testing.T) {
+	key := newTestKey(t)
+	otherKey := newTestKey(t)
+
+	// Algorithm confusion: the public key, which is no secret, used as HMAC secret.
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	publicPem := pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: publicKey})
+
+	tests := []struct {
+		name   string
+		token  string
+		reason string
+	}{
+		{
+			name:  "valid",
+			token: signToken(t, jwt.SigningMethodRS256, key, validClaims()),
+		},
+		{
+			name:   "expired",
+			token:  signToken(t, jwt.SigningMethodRS256, key, withClaims(jwt.MapClaims{"exp": time.Now().Add(-time.Hour).Unix()})),
+			reason: "token is expired",
+		},
+		{
+			name:  "expired within leeway",
+			token: signToken(t, jwt.SigningMethodRS256, key, withClaims(jwt.MapClaims{"exp": time.Now().Add(-clockLeeway / 2).Unix()})),
+		},
+		{
+			name:   "not valid yet",
+			token:  signToken(t, jwt.SigningMethodRS256, key, withClaims(jwt.MapClaims{"nbf": time.Now().Add(time.Hour).Unix()})),
+			reason: "token is not valid yet",
+		},
+		{
+			name:   "wrong issuer",
+			token:  signToken(t, jwt.SigningMethodRS256, key, withClaims(jwt.MapClaims{"iss": "https://other.example.com"})),
+			reason: "token was issued by another identity provider",
+		},
+		{
+			name:   "missing issuer",
+			token:  signToken(t, jwt.SigningMethodRS256, key, withClaims(jwt.MapClaims{"iss": nil})),
+			reason: "token is missing a required claim",
+		},
+		{
+			name:   "missing expiration",
+			token:  signToken(t, jwt.SigningMethodRS256, key, withClaims(jwt.MapClaims{"exp": nil})),
+			reason: "token has no expiration time",
+		},
+		{
+			name:   "missing subject",
+			token:  signToken(t, jwt.SigningMethodRS256, key, withClaims(jwt.MapClaims{"sub": nil})),
+			reason: "token has no subject",
+		},
+		{
+			name:   "empty subject",
+			token:  signToken(t, jwt.SigningMethodRS256, key, withClaims(jwt.MapClaims{"sub": ""})),
+			reason: "token has no subject",
+		},
+		{
+			name:   "signed by another key",
+			token:  signToken(t, jwt.SigningMethodRS256, otherKey, validClaims()),
+			reason: "token signature is invalid",
+		},
+		{
+			name:   "HS256 signed with public key",
+			token:  signToken(t, jwt.SigningMethodHS256, publicPem, validClaims()),
+			reason: "token signature is invalid",
+		},
+		{
+			name:   "unsigned",
+			token:  signToken(t, jwt.SigningMethodNone, jwt.UnsafeAllowNoneSignatureType, validClaims()),
+			reason: "token signature is invalid",
+		},
+		{
+			name:   "malformed",
+			token:  "not.a.token",
+			reason: "token is malformed",
+		},
+	}
+
+	v := newTestVerifier(key)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			claims, err := v.parse(context.Background(), test.token)
+			if test.reason == "" {
+				if err != nil {
+					t.Fatalf("expected token to be accepted, got %v", err)
+				}
+				if subject, _ := claims.GetSubject(); subject != "streamer-1" {
+					t.Fatalf("expected subject streamer-1, got %q", subject)
+				}
+				return
+			}
+
+			var invalid *InvalidTokenError
+			if !errors.As(err, &invalid) {
+				t.Fatalf("expected InvalidTokenError, got %v", err)
+			}
+			if invalid.Reason != test.reason {
+				t.Fatalf("expected reason %q, got %q", test.reason, invalid.Reason)
+			}
+		})
+	}
+}
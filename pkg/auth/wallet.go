@@ -0,0 +1,90 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	jwt "github.com/golang-jwt/jwt/v5"
+)
+
+// WalletIssuer is the issuer of tokens the server gives to streamers signed in with a TON wallet.
+const WalletIssuer = "ton-donate"
+
+const (
+	// Access tokens can't be revoked, so they are short lived and renewed by refresh tokens.
+	WalletAccessTokenTTL  = 15 * time.Minute
+	WalletRefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+// WalletTokens issues and authenticates the server's own HS256 access tokens for streamers
+// signed in with a TON wallet. Refresh tokens are random strings, see NewRefreshToken.
+type WalletTokens struct {
+	verifier
+	secret []byte
+}
+
+func NewWalletTokens(secret []byte) (*WalletTokens, error) {
+	if len(secret) < minSecretLength {
+		return nil, errors.New("wallet token secret must be at least 32 bytes")
+	}
+
+	return &WalletTokens{
+		verifier: verifier{
+			issuer:  WalletIssuer,
+			methods: []string{"HS256"},
+			key: func(ctx context.Context, token *jwt.Token) (interface{}, error) {
+				return secret, nil
+			},
+		},
+		secret: secret,
+	}, nil
+}
+
+func (t *WalletTokens) Authenticate(ctx context.Context, tokenString string) (*Principal, error) {
+	claims, err := t.parse(ctx, tokenString)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, _ := claims.GetSubject()
+	return &Principal{
+		StreamerId: subject,
+		Subject:    subject,
+		Username:   stringClaim(claims, "wallet"),
+	}, nil
+}
+
+// IssueAccessToken signs an access token for the streamer valid for WalletAccessTokenTTL.
+func (t *WalletTokens) IssueAccessToken(streamerId string, walletAddress string) (string, error) {
+	now := time.Now()
+	claims := jwt.MapClaims{
+		"iss":    t.issuer,
+		"sub":    streamerId,
+		"wallet": walletAddress,
+		"iat":    now.Unix(),
+		"exp":    now.Add(WalletAccessTokenTTL).Unix(),
+	}
+
+	return jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(t.secret)
+}
+
+// NewRefreshToken returns a random refresh token for the client and its hash to store.
+func NewRefreshToken() (token string, hash string, err error) {
+	data := make([]byte, 32)
+	if _, err := rand.Read(data); err != nil {
+		return "", "", err
+	}
+
+	token = hex.EncodeToString(data)
+	return token, HashRefreshToken(token), nil
+}
+
+// HashRefreshToken is the stored form of a refresh token, tokens are random so no salt is needed.
+func HashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/prices"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/roles"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/vladtenlive/ton-donate/pkg/ton"
+	"github.com/vladtenlive/ton-donate/pkg/utils"
+)
+
+// Handlers in this file are mounted behind middlewares.RequireAdmin.
+
+type AdminStreamerModel struct {
+	GetStreamerModel
+
+	Disabled       bool            `json:"disabled"`
+	DisabledReason string          `json:"disabled_reason,omitempty"`
+	Grants         []storage.Grant `json:"grants"`
+}
+
+// GetAdminStreamerHandler looks up a streamer by id, wallet address or slug, together with the roles it holds.
+func (s *Service) GetAdminStreamerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := chi.URLParam(r, "key")
+
+	streamer, err := s.streamers.GetStreamerByStreamerId(ctx, key)
+	if err == nil && streamer == nil {
+		if walletAddress, parseErr := utils.NormalizeWalletAddress(key); parseErr == nil {
+			streamer, err = s.streamers.GetStreamerByWalletAddress(ctx, walletAddress)
+		} else {
+			streamer, err = s.streamers.GetStreamerBySlug(ctx, key)
+		}
+	}
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	} else if streamer == nil {
+		response.WriteError(w, response.NotFound("Streamer does not exist."))
+		return
+	}
+
+	grants, err := s.grants.GetGranteeGrants(ctx, streamer.StreamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load account roles.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &AdminStreamerModel{
+		GetStreamerModel: GetStreamerModel{
+			StreamerId:    streamer.StreamerId,
+			WalletAddress: streamer.WalletAddress,
+			Slug:          streamer.Slug,
+			DisplayName:   streamer.DisplayName,
+			AvatarUrl:     streamer.AvatarUrl,
+			Description:   streamer.Description,
+			Wallets:       streamer.GetWallets(),
+		},
+		Disabled:       streamer.Disabled,
+		DisabledReason: streamer.DisabledReason,
+		Grants:         grants,
+	})
+}
+
+type DisableStreamerRequest struct {
+	Reason string `json:"reason" validate:"required,max=300"`
+}
+
+// DisableStreamerHandler blocks the streamer from signing in and receiving donations, its refresh tokens are revoked.
+func (s *Service) DisableStreamerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	streamerId := chi.URLParam(r, "streamerId")
+
+	var payload DisableStreamerRequest
+	err := decodeRequest(w, r, &payload)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	found, err := s.streamers.SetStreamerDisabled(ctx, streamerId, true, payload.Reason)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to disable streamer.", err))
+		return
+	} else if !found {
+		response.WriteError(w, response.NotFound("Streamer with such id does not exist."))
+		return
+	}
+
+	// Access tokens are rejected by middlewares.RejectDisabled until they expire.
+	if err := s.refreshTokens.DeleteStreamerRefreshTokens(ctx, streamerId); err != nil {
+		response.WriteError(w, response.Internal("Failed to revoke streamer refresh tokens.", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) EnableStreamerHandler(w http.ResponseWriter, r *http.Request) {
+	found, err := s.streamers.SetStreamerDisabled(r.Context(), chi.URLParam(r, "streamerId"), false, "")
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to enable streamer.", err))
+		return
+	} else if !found {
+		response.WriteError(w, response.NotFound("Streamer with such id does not exist."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ResendNotificationHandler sends the alert of a confirmed donation to the streamer again.
+func (s *Service) ResendNotificationHandler(w http.ResponseWriter, r *http.Request) {
+	donation, err := s.donations.GetDonationByTxHash(r.Context(), chi.URLParam(r, "txHash"))
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load donation.", err))
+		return
+	} else if donation == nil {
+		response.WriteError(w, response.NotFound("Donation with such transaction hash does not exist."))
+		return
+	} else if donation.Moderation != nil && donation.Moderation.Status == storage.ModerationHidden {
+		response.WriteError(w, response.Conflict("Donation is hidden by moderation."))
+		return
+	}
+
+	err = s.notifier.Send(ton.NotificationRequest{
+		Id:         donation.TxHash,
+		Amount:     donation.Amount,
+		Fiat:       prices.FiatAmounts(donation.Amount, donation.FiatRates),
+		Text:       donation.Message,
+		Nickname:   donation.From,
+		StreamerId: donation.StreamerId,
+	})
+	if err != nil {
+		response.WriteError(w, response.Upstream("Failed to send notification.", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// AdminModerateDonationHandler approves or hides a donation of any streamer.
+func (s *Service) AdminModerateDonationHandler(w http.ResponseWriter, r *http.Request) {
+	s.moderateDonation(w, r, "")
+}
+
+// GetAdminsHandler lists stored admin grants, admins by identity provider group are not listed.
+func (s *Service) GetAdminsHandler(w http.ResponseWriter, r *http.Request) {
+	grants, err := s.grants.GetStreamerGrants(r.Context(), roles.Admin, "")
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load admins.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &grants)
+}
+
+func (s *Service) AddAdminHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamer, err := s.streamers.GetStreamerByStreamerId(ctx, chi.URLParam(r, "streamerId"))
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	} else if streamer == nil {
+		response.WriteError(w, response.NotFound("Streamer with such id does not exist."))
+		return
+	}
+
+	grant := storage.Grant{
+		Role:      roles.Admin,
+		GranteeId: streamer.StreamerId,
+		GrantedBy: auth.StreamerId(ctx),
+		CreatedAt: time.Now().UTC(),
+	}
+	if err := s.grants.SaveGrant(ctx, grant); err != nil {
+		response.WriteError(w, response.Internal("Failed to save admin.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &grant)
+}
+
+func (s *Service) RemoveAdminHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	granteeId := chi.URLParam(r, "streamerId")
+	if granteeId == auth.StreamerId(ctx) {
+		response.WriteError(w, response.Validation("Admins cannot remove themselves."))
+		return
+	}
+
+	removed, err := s.grants.DeleteGrant(ctx, roles.Admin, "", granteeId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to remove admin.", err))
+		return
+	} else if !removed {
+		response.WriteError(w, response.NotFound("Account is not an admin."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/prices"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+type GetAnalyticsModel struct {
+	Interval string `json:"interval"`
+	Timezone string `json:"timezone"`
+	Currency string `json:"currency"`
+	*storage.Analytics
+}
+
+// GetAnalyticsHandler returns donations count, sum, average and unique donors bucketed by
+// "interval" (hour, day or week) in "tz" timezone, filtered by "currency", "status", "from" and "to".
+func (s *Service) GetAnalyticsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	query, err := parseAnalyticsQuery(r)
+	if err != nil {
+		response.WriteError(w, response.Validation(err.Error()))
+		return
+	}
+	query.StreamerId = streamerId
+
+	analytics, err := s.donations.GetAnalytics(ctx, query)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load analytics.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &GetAnalyticsModel{
+		Interval:  query.Interval,
+		Timezone:  query.Timezone,
+		Currency:  query.Currency,
+		Analytics: analytics})
+}
+
+func parseAnalyticsQuery(r *http.Request) (storage.AnalyticsQuery, error) {
+	values := r.URL.Query()
+	query := storage.AnalyticsQuery{
+		Interval: storage.IntervalDay,
+		Timezone: "UTC",
+		Currency: storage.CurrencyTON,
+		Status:   values.Get("status"),
+	}
+
+	switch interval := values.Get("interval"); interval {
+	case "":
+	case storage.IntervalHour, storage.IntervalDay, storage.IntervalWeek:
+		query.Interval = interval
+	default:
+		return query, queryError("Parameter 'interval' must be hour, day or week.")
+	}
+
+	if tz := values.Get("tz"); tz != "" {
+		if _, err := time.LoadLocation(tz); err != nil {
+			return query, queryError("Parameter 'tz' must be IANA timezone name.")
+		}
+		query.Timezone = tz
+	}
+
+	if currency := values.Get("currency"); currency != "" {
+		if currency != storage.CurrencyTON && !prices.IsFiatCurrency(currency) {
+			return query, queryError("Parameter 'currency' must be TON or a supported fiat currency.")
+		}
+		query.Currency = currency
+	}
+
+	switch query.Status {
+	case "", storage.DonationStatusPending, storage.DonationStatusVerified, storage.DonationStatusAcked:
+	default:
+		return query, queryError("Parameter 'status' must be pending, verified or acked.")
+	}
+
+	var err error
+	if query.From, err = parseTimeParam(values.Get("from")); err != nil {
+		return query, queryError("Parameter 'from' must be RFC 3339 time.")
+	}
+	if query.To, err = parseTimeParam(values.Get("to")); err != nil {
+		return query, queryError("Parameter 'to' must be RFC 3339 time.")
+	}
+	if query.From != nil && query.To != nil && !query.To.After(*query.From) {
+		return query, queryError("Parameter 'to' must be after 'from'.")
+	}
+
+	return query, nil
+}
@@ -0,0 +1,42 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+func TestParseAnalyticsQueryCurrency(t *testing.T) {
+	tests := []struct {
+		name     string
+		query    string
+		currency string
+		err      bool
+	}{
+		{name: "TON by default", currency: storage.CurrencyTON},
+		{name: "TON", query: "?currency=TON", currency: storage.CurrencyTON},
+		{name: "fiat currency", query: "?currency=USD", currency: "USD"},
+		{name: "fiat currency which is not configured", query: "?currency=EUR", err: true},
+		{name: "unknown currency", query: "?currency=DOGE", err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			t.Setenv("FIAT_CURRENCIES", "USD")
+
+			query, err := parseAnalyticsQuery(httptest.NewRequest(http.MethodGet, "/analytics"+test.query, nil))
+			if test.err {
+				if _, ok := err.(queryError); !ok {
+					t.Fatalf("expected query error, got %v", err)
+				}
+				return
+			}
+
+			if err != nil || query.Currency != test.currency {
+				t.Fatalf("expected %s, got %s, %v", test.currency, query.Currency, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,234 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/vladtenlive/ton-donate/pkg/ton"
+	"github.com/vladtenlive/ton-donate/pkg/utils"
+)
+
+// Sign-in payloads are bound to this subject, so payloads issued for wallet registration can't be used to sign in.
+const loginProofSubject = "ton-login"
+
+// walletStreamerPrefix keys streamers who signed up with a wallet rather than an identity provider account.
+const walletStreamerPrefix = "ton:"
+
+var errInvalidRefreshToken = response.Unauthorized("Refresh token is invalid or expired.")
+
+// errAccountDisabled matches the error of middlewares.RejectDisabled for streamers signing in.
+func errAccountDisabled(streamer *storage.Streamer) *response.Error {
+	return response.Forbidden("Account is disabled: " + streamer.DisabledReason)
+}
+
+type TokenModel struct {
+	AccessToken  string `json:"access_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int64  `json:"expires_in"`
+	RefreshToken string `json:"refresh_token"`
+	StreamerId   string `json:"streamerId"`
+}
+
+// GetLoginPayloadHandler issues payload for TON Connect ton_proof request used to sign in.
+func (s *Service) GetLoginPayloadHandler(w http.ResponseWriter, r *http.Request) {
+	if s.walletTokens == nil {
+		writeWalletLoginDisabled(w)
+		return
+	}
+
+	payload, err := s.proofVerifier.IssuePayload(r.Context(), loginProofSubject)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to generate proof payload.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &GetProofPayloadModel{payload})
+}
+
+type WalletLoginRequest struct {
+	WalletAddress string     `json:"wallet_address" validate:"required,ton_address"`
+	Proof         *ton.Proof `json:"proof" validate:"required"`
+}
+
+// WalletLoginHandler signs in the streamer owning the wallet, streamer is created on first sign in.
+func (s *Service) WalletLoginHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if s.walletTokens == nil {
+		writeWalletLoginDisabled(w)
+		return
+	}
+
+	var payload WalletLoginRequest
+	err := decodeRequest(w, r, &payload)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	wallet, err := utils.ParseWalletAddress(payload.WalletAddress)
+	if err != nil {
+		response.WriteError(w, response.Validation("Wrong wallet address: "+err.Error()))
+		return
+	}
+	walletAddress := utils.FormatWalletAddress(wallet)
+
+	err = s.proofVerifier.Verify(ctx, wallet, payload.Proof, loginProofSubject)
+	if err != nil {
+		response.WriteError(w, response.Forbidden("Failed to verify wallet ownership: "+err.Error()))
+		return
+	}
+
+	streamer, err := s.streamers.GetStreamerByWalletAddress(ctx, walletAddress)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	}
+
+	if streamer == nil {
+		streamer, err = s.createWalletStreamer(ctx, walletAddress)
+		if errors.Is(err, storage.ErrWalletAddressTaken) {
+			response.WriteError(w, response.Conflict("Streamer with this wallet address has been registered meanwhile, sign in again."))
+			return
+		} else if err != nil {
+			response.WriteError(w, response.Internal("Failed to save streamer.", err))
+			return
+		}
+	} else if !streamer.IsActiveWallet(walletAddress) {
+		// Retired wallets could have been handed over, they keep past donations but don't grant access.
+		response.WriteError(w, response.Forbidden("Wallet was retired by its streamer, sign in with an active wallet."))
+		return
+	} else if streamer.Disabled {
+		response.WriteError(w, errAccountDisabled(streamer))
+		return
+	}
+
+	s.writeTokens(w, ctx, streamer.StreamerId, walletAddress)
+}
+
+func (s *Service) createWalletStreamer(ctx context.Context, walletAddress string) (*storage.Streamer, error) {
+	streamer := storage.Streamer{
+		StreamerId:    walletStreamerPrefix + walletAddress,
+		WalletAddress: walletAddress,
+	}
+
+	if err := s.streamers.SaveStreamer(ctx, streamer); err != nil {
+		return nil, err
+	}
+	if err := s.streamers.AddStreamerWallet(ctx, streamer.StreamerId, walletAddress); err != nil {
+		return nil, err
+	}
+
+	return &streamer, nil
+}
+
+type RefreshTokenRequest struct {
+	RefreshToken string `json:"refresh_token" validate:"required,max=128"`
+}
+
+// RefreshTokenHandler exchanges a refresh token for new access and refresh tokens, the old refresh token is spent.
+func (s *Service) RefreshTokenHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if s.walletTokens == nil {
+		writeWalletLoginDisabled(w)
+		return
+	}
+
+	var payload RefreshTokenRequest
+	err := decodeRequest(w, r, &payload)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	token, err := s.refreshTokens.ConsumeRefreshToken(ctx, auth.HashRefreshToken(payload.RefreshToken))
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load refresh token.", err))
+		return
+	} else if token == nil {
+		response.WriteError(w, errInvalidRefreshToken)
+		return
+	}
+
+	// Streamer could have retired the wallet since signing in.
+	streamer, err := s.streamers.GetStreamerByStreamerId(ctx, token.StreamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	} else if streamer == nil || !streamer.IsActiveWallet(token.WalletAddress) {
+		response.WriteError(w, errInvalidRefreshToken)
+		return
+	} else if streamer.Disabled {
+		response.WriteError(w, errAccountDisabled(streamer))
+		return
+	}
+
+	s.writeTokens(w, ctx, token.StreamerId, token.WalletAddress)
+}
+
+// LogoutHandler revokes the refresh token, issued access tokens stay valid until they expire.
+func (s *Service) LogoutHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if s.walletTokens == nil {
+		writeWalletLoginDisabled(w)
+		return
+	}
+
+	var payload RefreshTokenRequest
+	err := decodeRequest(w, r, &payload)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	_, err = s.refreshTokens.ConsumeRefreshToken(ctx, auth.HashRefreshToken(payload.RefreshToken))
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to revoke refresh token.", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) writeTokens(w http.ResponseWriter, ctx context.Context, streamerId string, walletAddress string) {
+	accessToken, err := s.walletTokens.IssueAccessToken(streamerId, walletAddress)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to issue access token.", err))
+		return
+	}
+
+	refreshToken, hash, err := auth.NewRefreshToken()
+	if err == nil {
+		now := time.Now().UTC()
+		err = s.refreshTokens.CreateRefreshToken(ctx, storage.RefreshToken{
+			Hash:          hash,
+			StreamerId:    streamerId,
+			WalletAddress: walletAddress,
+			CreatedAt:     now,
+			ExpiresAt:     now.Add(auth.WalletRefreshTokenTTL),
+		})
+	}
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to issue refresh token.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &TokenModel{
+		AccessToken:  accessToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int64(auth.WalletAccessTokenTTL.Seconds()),
+		RefreshToken: refreshToken,
+		StreamerId:   streamerId,
+	})
+}
+
+func writeWalletLoginDisabled(w http.ResponseWriter) {
+	response.WriteError(w, response.NotFound("Sign in with TON wallet is not configured."))
+}
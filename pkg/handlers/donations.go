@@ -1,23 +1,24 @@
 package handlers
 
 import (
-	"encoding/json"
+	"errors"
 	"net/http"
+	"time"
 
-	"github.com/labstack/gommon/log"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/prices"
+	"github.com/vladtenlive/ton-donate/pkg/response"
 	"github.com/vladtenlive/ton-donate/pkg/storage"
-	parsers "github.com/vladtenlive/ton-donate/pkg/utils/parsers"
+	"github.com/vladtenlive/ton-donate/pkg/utils"
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
 )
 
-type GetDonationListResponse struct {
-	Data  *[]GetDonationListModel `json:"data"`
-	Error string                  `json:"error"`
-}
-
 type GetDonationListModel struct {
 	From    string `json:"nickname,omitempty" bson:"nickname,omitempty"`
 	Message string `json:"text,omitempty" bson:"message,omitempty"`
 	Amount  uint64 `json:"amount,omitempty" bson:"amount,omitempty"`
+	// Amount by fiat currency at the rate of acknowledgement.
+	Fiat map[string]float64 `json:"fiat,omitempty" bson:"-"`
 }
 
 // ToDo: Confirmed (from transaction), Acked (for sending to notificator)
@@ -25,21 +26,15 @@ type GetDonationListModel struct {
 func (s *Service) GetDonationListHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	streamerId := parsers.GetStreamerId(r, s.auth)
+	streamerId := auth.StreamerId(r.Context())
 	if streamerId == "" {
-		response, _ := json.Marshal(&GetDonationListResponse{nil, "Failed to parse streamer id."})
-
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+		response.WriteError(w, errUnauthorized)
 		return
 	}
 
-	donations, err := s.mongoStorage.GetStreamerDonations(ctx, streamerId)
+	donations, err := s.donations.GetStreamerDonations(ctx, streamerId)
 	if err != nil {
-		response, _ := json.Marshal(&GetDonationListResponse{nil, "Failed to load streamer donations."})
-
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+		response.WriteError(w, response.Internal("Failed to load streamer donations.", err))
 		return
 	}
 
@@ -48,75 +43,68 @@ func (s *Service) GetDonationListHandler(w http.ResponseWriter, r *http.Request)
 		donationsModel = append(donationsModel, GetDonationListModel{
 			From:    donation.From,
 			Message: donation.Message,
-			Amount:  donation.Amount})
+			Amount:  donation.Amount,
+			Fiat:    prices.FiatAmounts(donation.Amount, donation.FiatRates)})
 	}
-	response, _ := json.Marshal(&GetDonationListResponse{&donationsModel, ""})
-
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	response.WriteJSON(w, http.StatusOK, &donationsModel)
 }
 
+// CreateDonationRequest announces a donation before it is sent, amount is in nanoTON up to 1M TON.
 type CreateDonationRequest struct {
-	Amount        uint64 `json:"amount"`
-	From          string `json:"nickname"`
-	WalletAddress string `json:"wallet_address"`
-	Message       string `json:"text"`
-	Sign          string `json:"sign"`
+	Amount        uint64 `json:"amount" validate:"min=1,max=1000000000000000"`
+	From          string `json:"nickname" validate:"max=64"`
+	WalletAddress string `json:"wallet_address" validate:"required,ton_address"`
+	Message       string `json:"text" validate:"max=300"`
+	Sign          string `json:"sign" validate:"required,max=128"`
+	WidgetId      string `json:"widgetId,omitempty" validate:"max=64"`
 }
 
 func (s *Service) CreateDonationHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 	var req CreateDonationRequest
 
-	err := json.NewDecoder(r.Body).Decode(&req)
+	err := decodeRequest(w, r, &req)
 	if err != nil {
-		log.Error(err)
-		http.Error(w, http.StatusText(http.StatusBadRequest), http.StatusBadRequest)
+		response.WriteError(w, err)
 		return
 	}
 
-	if req.Sign == "" {
-		log.Error("Wrong sign: ", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Wrong sign: " + err.Error()))
-		return
-	}
-
-	if req.WalletAddress == "" {
-		log.Error("Please provide wallet address: ", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Please provide wallet address: " + err.Error()))
+	req.WalletAddress, err = utils.NormalizeWalletAddress(req.WalletAddress)
+	if err != nil {
+		response.WriteError(w, response.Validation("Wrong wallet address: "+err.Error()))
 		return
 	}
 
-	streamer, err := s.mongoStorage.GetStreamerByWalletAddress(ctx, req.WalletAddress)
+	streamer, err := s.streamers.GetStreamerByWalletAddress(ctx, req.WalletAddress)
 	if err != nil {
-		log.Error("Streamer with current wallet address does not exist: ", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Streamer with current wallet address does not exist: " + err.Error()))
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
 		return
 	} else if streamer == nil {
-		log.Error("Streamer with current wallet address does not exist: ", err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Streamer with current wallet address does not exist: " + err.Error()))
+		response.WriteError(w, response.NotFound("Streamer with current wallet address does not exist."))
 		return
 	}
 
-	// Check if exist donation with the same sign or error
-	donation, err := s.mongoStorage.GetDonationBySign(ctx, req.Sign)
-	if err != nil {
-		log.Error(err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(err.Error()))
+	if streamer.Disabled {
+		response.WriteError(w, response.Validation("Streamer does not accept donations."))
 		return
-	} else if donation != nil {
-		// Donation was saved previously, do not allow flood of donations by same transaction.
-		log.Error(err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte("Donation has already been saved."))
+	}
+
+	if !streamer.IsActiveWallet(req.WalletAddress) {
+		response.WriteError(w, response.Validation("Streamer does not accept donations to this wallet anymore."))
 		return
 	}
 
+	if req.WidgetId != "" {
+		widget, err := s.widgets.GetWidget(ctx, streamer.StreamerId, req.WidgetId)
+		if err != nil && !errors.Is(err, storage.ErrInvalidWidgetId) {
+			response.WriteError(w, response.Internal("Failed to load goal widget.", err))
+			return
+		} else if widget == nil || widget.Type != widgets.TypeDonationGoal || !widget.IsActive {
+			response.WriteError(w, response.Validation("Streamer does not have such active goal widget."))
+			return
+		}
+	}
+
 	newDonation := storage.Donation{
 		From:          req.From,
 		StreamerId:    streamer.StreamerId,
@@ -128,15 +116,20 @@ func (s *Service) CreateDonationHandler(w http.ResponseWriter, r *http.Request)
 		Lt:            0,  // we dont know it at this point, only after it's been processed by Ton
 		Verified:      false,
 		Acked:         false,
+		WidgetId:      req.WidgetId,
+		Currency:      storage.CurrencyTON,
+		CreatedAt:     time.Now().UTC(),
 	}
 
-	_, err = s.mongoStorage.CreateDonation(ctx, newDonation)
-	if err != nil {
-		log.Error(err)
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write([]byte(err.Error()))
+	err = s.donations.CreateDonation(ctx, newDonation)
+	if errors.Is(err, storage.ErrDuplicate) {
+		// Donation was saved previously, do not allow flood of donations by same transaction.
+		response.WriteError(w, response.Conflict("Donation has already been saved."))
+		return
+	} else if err != nil {
+		response.WriteError(w, response.Internal("Failed to save donation.", err))
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	response.WriteJSON(w, http.StatusOK, nil)
 }
@@ -0,0 +1,115 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/xssnick/tonutils-go/address"
+)
+
+// testnetWallet is a testnet flagged user friendly address, distinct for each seed.
+func testnetWallet(seed byte) string {
+	data := make([]byte, 32)
+	data[31] = seed
+
+	addr := address.NewAddress(0, 0, data)
+	addr.SetBounce(true)
+	addr.SetTestnetOnly(true)
+	return addr.String()
+}
+
+// newTestService is a Service on in-memory storage with one streamer receiving to wallet.
+func newTestService(t *testing.T, wallet string) (*Service, *storage.MemoryStorage) {
+	t.Helper()
+	t.Setenv("TON_NET", "testnet")
+
+	memory := storage.NewMemoryStorage()
+	err := memory.SaveStreamer(context.Background(), storage.Streamer{StreamerId: "streamer-1", WalletAddress: wallet})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return NewService(http.DefaultClient, memory.Repositories(), "", nil, nil, nil, nil), memory
+}
+
+type testEnvelope struct {
+	Error *response.Error `json:"error"`
+}
+
+func TestCreateDonationHandler(t *testing.T) {
+	wallet := testnetWallet(1)
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		code   response.Code
+	}{
+		{
+			name:   "created",
+			body:   `{"amount": 1000000000, "nickname": "bob", "wallet_address": "` + wallet + `", "text": "hi", "sign": "sign-1"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "same sign again",
+			body:   `{"amount": 2000000000, "nickname": "eve", "wallet_address": "` + wallet + `", "text": "hi", "sign": "sign-1"}`,
+			status: http.StatusConflict,
+			code:   response.CodeConflict,
+		},
+		{
+			name:   "another sign",
+			body:   `{"amount": 1000000000, "nickname": "bob", "wallet_address": "` + wallet + `", "sign": "sign-2"}`,
+			status: http.StatusOK,
+		},
+		{
+			name:   "unknown wallet",
+			body:   `{"amount": 1000000000, "wallet_address": "` + testnetWallet(2) + `", "sign": "sign-3"}`,
+			status: http.StatusNotFound,
+			code:   response.CodeNotFound,
+		},
+		{
+			name:   "zero amount",
+			body:   `{"amount": 0, "wallet_address": "` + wallet + `", "sign": "sign-4"}`,
+			status: http.StatusBadRequest,
+			code:   response.CodeValidation,
+		},
+	}
+
+	// Cases run in order against the same storage, the conflict is with the first donation.
+	s, memory := newTestService(t, wallet)
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/donations", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+			s.CreateDonationHandler(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body)
+			}
+
+			var body testEnvelope
+			if err := json.Unmarshal(w.Body.Bytes(), &body); err != nil {
+				t.Fatal(err)
+			}
+			if test.code == "" && body.Error != nil {
+				t.Fatalf("expected no error, got %+v", body.Error)
+			} else if test.code != "" && (body.Error == nil || body.Error.Code != test.code) {
+				t.Fatalf("expected error %s, got %+v", test.code, body.Error)
+			}
+		})
+	}
+
+	donation, err := memory.GetDonationBySign(context.Background(), "sign-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if donation == nil || donation.From != "bob" || donation.Amount != 1000000000 {
+		t.Fatalf("expected the first donation to be kept, got %+v", donation)
+	}
+}
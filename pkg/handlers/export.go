@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"encoding/csv"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/prices"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+// ExportDonationsHandler streams streamer donations as CSV with fiat values at the rate of acknowledgement.
+func (s *Service) ExportDonationsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	donations, err := s.donations.GetStreamerDonations(ctx, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer donations.", err))
+		return
+	}
+
+	currencies := prices.FiatCurrencies()
+	header := []string{"created_at", "nickname", "sender_address", "amount_ton", "status", "tx_hash", "message"}
+	for _, currency := range currencies {
+		header = append(header, "rate_"+currency, "amount_"+currency)
+	}
+
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="donations.csv"`)
+	w.WriteHeader(http.StatusOK)
+
+	writer := csv.NewWriter(w)
+	writer.Write(header)
+	for _, donation := range *donations {
+		createdAt := ""
+		if !donation.CreatedAt.IsZero() {
+			createdAt = donation.CreatedAt.Format(time.RFC3339)
+		}
+
+		record := []string{
+			createdAt,
+			csvText(donation.From),
+			donation.SenderAddress,
+			strconv.FormatFloat(float64(donation.Amount)/1e9, 'f', -1, 64),
+			donationStatus(donation),
+			donation.TxHash,
+			csvText(donation.Message),
+		}
+
+		for _, currency := range currencies {
+			rate, ok := donation.FiatRates[currency]
+			if !ok {
+				record = append(record, "", "")
+				continue
+			}
+			record = append(record,
+				strconv.FormatFloat(rate, 'f', -1, 64),
+				strconv.FormatFloat(prices.ToFiat(donation.Amount, rate), 'f', 2, 64))
+		}
+
+		writer.Write(record)
+	}
+	writer.Flush()
+
+	// The status is sent already, a failed write can only be logged.
+	if err := writer.Error(); err != nil {
+		log.Errorf("Failed to export donations of %s: %v", streamerId, err)
+	}
+}
+
+// csvText escapes text of donors which spreadsheets would otherwise run as a formula.
+func csvText(text string) string {
+	if text != "" && strings.ContainsRune("=+-@\t\r", rune(text[0])) {
+		return "'" + text
+	}
+	return text
+}
+
+func donationStatus(donation storage.Donation) string {
+	switch {
+	case donation.Acked:
+		return storage.DonationStatusAcked
+	case donation.Verified:
+		return storage.DonationStatusVerified
+	default:
+		return storage.DonationStatusPending
+	}
+}
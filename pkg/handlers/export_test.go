@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+func TestExportDonationsHandlerEscapesFormulas(t *testing.T) {
+	s, memory := newTestService(t, testnetWallet(1))
+
+	texts := []string{"=HYPERLINK(\"http://evil\")", "+1", "-1", "@SUM(A1)", "\tcmd", "\rcmd", "bob", ""}
+	for i, text := range texts {
+		err := memory.CreateDonation(context.Background(), storage.Donation{
+			Sign:       fmt.Sprintf("sign-%d", i),
+			StreamerId: "streamer-1",
+			From:       text,
+			Message:    text,
+			Amount:     1,
+			CreatedAt:  time.Date(2026, 1, 1, 0, 0, i, 0, time.UTC),
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	w := httptest.NewRecorder()
+	s.ExportDonationsHandler(w, streamerRequest(http.MethodGet, "/donations/export", nil))
+	if w.Code != http.StatusOK {
+		t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+	}
+
+	records, err := csv.NewReader(w.Body).ReadAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	escaped := map[string]string{}
+	for _, record := range records[1:] {
+		if record[1] != record[6] {
+			t.Fatalf("expected nickname and message escaped alike, got %q and %q", record[1], record[6])
+		}
+		escaped[record[1]] = record[6]
+	}
+	for _, expected := range []string{"'=HYPERLINK(\"http://evil\")", "'+1", "'-1", "'@SUM(A1)", "'\tcmd", "'\rcmd", "bob", ""} {
+		if _, ok := escaped[expected]; !ok {
+			t.Fatalf("expected %q among exported texts, got %q", expected, records)
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+const (
+	defaultTopDonorsLimit = 10
+	maxTopDonorsLimit     = 100
+)
+
+// GetTopDonorsHandler returns leaderboard for all time, for the range given by "from" and "to" (RFC 3339)
+// or for stream "session" (id or "current").
+// Donors are grouped by nickname or, with "by=wallet", by sender wallet.
+func (s *Service) GetTopDonorsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	query, err := parseTopDonorsQuery(r)
+	if err != nil {
+		response.WriteError(w, response.Validation(err.Error()))
+		return
+	}
+	query.StreamerId = streamerId
+
+	if sessionId := r.URL.Query().Get("session"); sessionId != "" {
+		session, err := s.loadSession(r, streamerId, sessionId)
+		if err != nil || session == nil {
+			writeSession(w, session, err)
+			return
+		}
+		query.SessionId = session.Id.Hex()
+	}
+
+	topDonors, err := s.donations.GetTopDonors(ctx, query)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load top donors.", err))
+		return
+	}
+
+	if topDonors.Donors == nil {
+		topDonors.Donors = []storage.TopDonor{}
+	}
+	if topDonors.Totals == nil {
+		topDonors.Totals = []storage.CurrencyTotal{}
+	}
+	response.WriteJSON(w, http.StatusOK, topDonors)
+}
+
+type queryError string
+
+func (e queryError) Error() string {
+	return string(e)
+}
+
+func parseTopDonorsQuery(r *http.Request) (storage.TopDonorsQuery, error) {
+	values := r.URL.Query()
+	query := storage.TopDonorsQuery{
+		GroupBy:  storage.DonorByNickname,
+		Currency: values.Get("currency"),
+		Limit:    defaultTopDonorsLimit,
+	}
+
+	switch values.Get("by") {
+	case "", storage.DonorByNickname:
+	case storage.DonorByWallet:
+		query.GroupBy = storage.DonorByWallet
+	default:
+		return query, queryError("Parameter 'by' must be nickname or wallet.")
+	}
+
+	if limit := values.Get("limit"); limit != "" {
+		parsed, err := strconv.ParseInt(limit, 10, 64)
+		if err != nil || parsed < 1 || parsed > maxTopDonorsLimit {
+			return query, queryError("Parameter 'limit' must be between 1 and 100.")
+		}
+		query.Limit = parsed
+	}
+
+	var err error
+	if query.From, err = parseTimeParam(values.Get("from")); err != nil {
+		return query, queryError("Parameter 'from' must be RFC 3339 time.")
+	}
+	if query.To, err = parseTimeParam(values.Get("to")); err != nil {
+		return query, queryError("Parameter 'to' must be RFC 3339 time.")
+	}
+	if query.From != nil && query.To != nil && !query.To.After(*query.From) {
+		return query, queryError("Parameter 'to' must be after 'from'.")
+	}
+
+	return query, nil
+}
+
+func parseTimeParam(value string) (*time.Time, error) {
+	if value == "" {
+		return nil, nil
+	}
+
+	parsed, err := time.Parse(time.RFC3339, value)
+	if err != nil {
+		return nil, err
+	}
+
+	return &parsed, nil
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/roles"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+const moderationQueueLimit = 50
+
+// GetModeratorsHandler lists accounts the streamer delegated moderation to.
+func (s *Service) GetModeratorsHandler(w http.ResponseWriter, r *http.Request) {
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	grants, err := s.grants.GetStreamerGrants(r.Context(), roles.Moderator, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load moderators.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &grants)
+}
+
+type AddModeratorRequest struct {
+	ModeratorId string `json:"moderatorId" validate:"required,max=128"`
+}
+
+// AddModeratorHandler delegates moderation of the streamer's donations to another registered streamer account.
+func (s *Service) AddModeratorHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(ctx)
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	var payload AddModeratorRequest
+	err := decodeRequest(w, r, &payload)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	if payload.ModeratorId == streamerId {
+		response.WriteError(w, response.Validation("Streamer moderates own donations already."))
+		return
+	}
+
+	moderator, err := s.streamers.GetStreamerByStreamerId(ctx, payload.ModeratorId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load moderator.", err))
+		return
+	} else if moderator == nil {
+		response.WriteError(w, response.NotFound("Moderator must be a registered streamer account."))
+		return
+	}
+
+	grant := storage.Grant{
+		Role:       roles.Moderator,
+		StreamerId: streamerId,
+		GranteeId:  moderator.StreamerId,
+		GrantedBy:  streamerId,
+		CreatedAt:  time.Now().UTC(),
+	}
+	if err := s.grants.SaveGrant(ctx, grant); err != nil {
+		response.WriteError(w, response.Internal("Failed to save moderator.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusCreated, &grant)
+}
+
+func (s *Service) RemoveModeratorHandler(w http.ResponseWriter, r *http.Request) {
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	removed, err := s.grants.DeleteGrant(r.Context(), roles.Moderator, streamerId, chi.URLParam(r, "id"))
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to remove moderator.", err))
+		return
+	} else if !removed {
+		response.WriteError(w, response.NotFound("Account is not a moderator of the streamer."))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// GetModeratedStreamersHandler lists grants of streamers the caller moderates.
+func (s *Service) GetModeratedStreamersHandler(w http.ResponseWriter, r *http.Request) {
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	grants, err := s.grants.GetGranteeGrants(r.Context(), streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load moderated streamers.", err))
+		return
+	}
+
+	moderated := []storage.Grant{}
+	for _, grant := range grants {
+		if grant.Role == roles.Moderator {
+			moderated = append(moderated, grant)
+		}
+	}
+
+	response.WriteJSON(w, http.StatusOK, &moderated)
+}
+
+// ModerationDonationModel is a donation as moderators see it, identified by transaction hash.
+type ModerationDonationModel struct {
+	TxHash     string                      `json:"txHash"`
+	StreamerId string                      `json:"streamerId"`
+	From       string                      `json:"nickname,omitempty"`
+	Message    string                      `json:"text,omitempty"`
+	Amount     uint64                      `json:"amount"`
+	CreatedAt  time.Time                   `json:"created_at"`
+	Moderation *storage.DonationModeration `json:"moderation,omitempty"`
+}
+
+func toModerationModel(donation storage.Donation) ModerationDonationModel {
+	return ModerationDonationModel{
+		TxHash:     donation.TxHash,
+		StreamerId: donation.StreamerId,
+		From:       donation.From,
+		Message:    donation.Message,
+		Amount:     donation.Amount,
+		CreatedAt:  donation.CreatedAt,
+		Moderation: donation.Moderation,
+	}
+}
+
+// GetModerationQueueHandler returns donations of {streamerId} waiting for review, see middlewares.RequireModerator.
+func (s *Service) GetModerationQueueHandler(w http.ResponseWriter, r *http.Request) {
+	donations, err := s.donations.GetModerationQueue(r.Context(), chi.URLParam(r, "streamerId"), moderationQueueLimit)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load moderation queue.", err))
+		return
+	}
+
+	queue := make([]ModerationDonationModel, 0, len(*donations))
+	for _, donation := range *donations {
+		queue = append(queue, toModerationModel(donation))
+	}
+
+	response.WriteJSON(w, http.StatusOK, &queue)
+}
+
+type ModerateDonationRequest struct {
+	Status string `json:"status" validate:"required,oneof=approved hidden"`
+	Reason string `json:"reason" validate:"max=300"`
+}
+
+// ModerateDonationHandler approves or hides a donation of {streamerId}, see middlewares.RequireModerator.
+func (s *Service) ModerateDonationHandler(w http.ResponseWriter, r *http.Request) {
+	s.moderateDonation(w, r, chi.URLParam(r, "streamerId"))
+}
+
+func (s *Service) moderateDonation(w http.ResponseWriter, r *http.Request, streamerId string) {
+	var payload ModerateDonationRequest
+	err := decodeRequest(w, r, &payload)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	donation, err := s.donations.ModerateDonation(r.Context(), streamerId, chi.URLParam(r, "txHash"), storage.DonationModeration{
+		Status: payload.Status,
+		Reason: payload.Reason,
+		By:     auth.StreamerId(r.Context()),
+		At:     time.Now().UTC(),
+	})
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to moderate donation.", err))
+		return
+	} else if donation == nil {
+		response.WriteError(w, response.NotFound("Donation with such transaction hash does not exist."))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, toModerationModel(*donation))
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vladtenlive/ton-donate/pkg/prices"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/vladtenlive/ton-donate/pkg/utils"
+)
+
+const publicDonationsLimit = 20
+
+// GetPublicStreamerModel is what viewers see on the donation page, it must never contain cognito ids.
+type GetPublicStreamerModel struct {
+	Slug            string                  `json:"slug,omitempty"`
+	DisplayName     string                  `json:"display_name,omitempty"`
+	AvatarUrl       string                  `json:"avatar_url,omitempty"`
+	Description     string                  `json:"description,omitempty"`
+	WalletAddress   string                  `json:"wallet_address,omitempty"`
+	Wallets         []string                `json:"wallets,omitempty"`
+	ContractAddress string                  `json:"contract_address,omitempty"`
+	Goal            *GetWidgetListModel     `json:"goal,omitempty"`
+	Donations       *[]GetDonationListModel `json:"donations"`
+}
+
+// GetPublicStreamerHandler serves the unauthenticated donation page, the key is either streamer slug or wallet address.
+func (s *Service) GetPublicStreamerHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+	key := chi.URLParam(r, "key")
+
+	var streamer *storage.Streamer
+	var err error
+	if walletAddress, parseErr := utils.NormalizeWalletAddress(key); parseErr == nil {
+		streamer, err = s.streamers.GetStreamerByWalletAddress(ctx, walletAddress)
+	} else {
+		streamer, err = s.streamers.GetStreamerBySlug(ctx, key)
+	}
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	} else if streamer == nil || streamer.Disabled {
+		response.WriteError(w, response.NotFound("Streamer does not exist."))
+		return
+	}
+
+	goal, err := s.widgets.GetActiveGoalWidget(ctx, streamer.StreamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer goal.", err))
+		return
+	}
+
+	donations, err := s.donations.GetPublicDonations(ctx, streamer.StreamerId, publicDonationsLimit)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer donations.", err))
+		return
+	}
+
+	model := GetPublicStreamerModel{
+		Slug:            streamer.Slug,
+		DisplayName:     streamer.DisplayName,
+		AvatarUrl:       streamer.AvatarUrl,
+		Description:     streamer.Description,
+		WalletAddress:   streamer.WalletAddress,
+		ContractAddress: s.contractAddress,
+	}
+	for _, wallet := range streamer.GetWallets() {
+		if wallet.IsActive {
+			model.Wallets = append(model.Wallets, wallet.Address)
+		}
+	}
+	if goal != nil {
+		goalModel := toWidgetModel(*goal)
+		model.Goal = &goalModel
+	}
+
+	donationsModel := make([]GetDonationListModel, 0)
+	for _, donation := range *donations {
+		donationsModel = append(donationsModel, GetDonationListModel{
+			From:    donation.From,
+			Message: donation.Message,
+			Amount:  donation.Amount,
+			Fiat:    prices.FiatAmounts(donation.Amount, donation.FiatRates)})
+	}
+	model.Donations = &donationsModel
+
+	// Page is hit by every viewer, let browsers and CDNs keep it for a short while.
+	w.Header().Set("Cache-Control", "public, max-age=15, stale-while-revalidate=30")
+	response.WriteJSON(w, http.StatusOK, &model)
+}
@@ -0,0 +1,88 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"reflect"
+	"strings"
+
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/validation"
+)
+
+// Payloads are short JSON documents, anything bigger is rejected before decoding.
+const maxRequestBody = 64 << 10
+
+// decodeRequest decodes JSON body into v and validates it by its `validate` tags.
+// Unknown fields, trailing data and bodies over maxRequestBody are rejected.
+func decodeRequest(w http.ResponseWriter, r *http.Request, v any) error {
+	return decodeBody(w, r, v, false)
+}
+
+// decodeOptionalRequest works like decodeRequest but leaves v as is when the body is empty.
+// Clients could send the body chunked, so it is read rather than told from Content-Length.
+func decodeOptionalRequest(w http.ResponseWriter, r *http.Request, v any) error {
+	return decodeBody(w, r, v, true)
+}
+
+func decodeBody(w http.ResponseWriter, r *http.Request, v any, optional bool) error {
+	decoder := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxRequestBody))
+	decoder.DisallowUnknownFields()
+
+	err := decoder.Decode(v)
+	if optional && errors.Is(err, io.EOF) {
+		return nil
+	}
+	if err == nil && decoder.More() {
+		err = errors.New("unexpected data after JSON body")
+	}
+	if err != nil {
+		return decodeError(err)
+	}
+
+	if problems := validation.Struct(v); problems != nil {
+		return response.Validation("Request payload is invalid.").WithDetails(problems)
+	}
+
+	return nil
+}
+
+func decodeError(err error) error {
+	var maxBytesErr *http.MaxBytesError
+	var typeErr *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &maxBytesErr):
+		return response.TooLarge("Request body is too large.")
+	case errors.Is(err, io.EOF):
+		return response.Validation("Request body is empty.")
+	case errors.As(err, &typeErr) && typeErr.Field != "":
+		return response.Validation("Request payload is invalid.").WithDetails(map[string]string{
+			typeErr.Field: "must be " + describeType(typeErr.Type)})
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		field := strings.Trim(strings.TrimPrefix(err.Error(), "json: unknown field "), `"`)
+		return response.Validation("Request payload is invalid.").WithDetails(map[string]string{
+			field: "is not allowed"})
+	default:
+		return response.Validation("Request body is not valid JSON: " + err.Error())
+	}
+}
+
+func describeType(t reflect.Type) string {
+	switch t.Kind() {
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return "a non-negative integer"
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return "an integer"
+	case reflect.Float32, reflect.Float64:
+		return "a number"
+	case reflect.String:
+		return "a string"
+	case reflect.Bool:
+		return "a boolean"
+	default:
+		return "a " + t.Kind().String()
+	}
+}
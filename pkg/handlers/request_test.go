@@ -0,0 +1,147 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/vladtenlive/ton-donate/pkg/response"
+)
+
+type decodeTestRequest struct {
+	Name   string `json:"name" validate:"required,max=8"`
+	Amount uint64 `json:"amount"`
+}
+
+func TestDecodeRequest(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		status  int
+		message string
+		details map[string]string
+	}{
+		{
+			name: "valid",
+			body: `{"name": "alice", "amount": 5}`,
+		},
+		{
+			name: "trailing whitespace",
+			body: "{\"name\": \"alice\"}\n",
+		},
+		{
+			name:    "unknown field",
+			body:    `{"name": "alice", "admin": true}`,
+			status:  http.StatusBadRequest,
+			message: "Request payload is invalid.",
+			details: map[string]string{"admin": "is not allowed"},
+		},
+		{
+			name:    "oversized body",
+			body:    `{"name": "` + strings.Repeat("a", maxRequestBody) + `"}`,
+			status:  http.StatusRequestEntityTooLarge,
+			message: "Request body is too large.",
+		},
+		{
+			name:    "trailing object",
+			body:    `{"name": "alice"}{"name": "bob"}`,
+			status:  http.StatusBadRequest,
+			message: "Request body is not valid JSON: unexpected data after JSON body",
+		},
+		{
+			name:    "trailing garbage",
+			body:    `{"name": "alice"} garbage`,
+			status:  http.StatusBadRequest,
+			message: "Request body is not valid JSON: unexpected data after JSON body",
+		},
+		{
+			name:    "empty body",
+			body:    "",
+			status:  http.StatusBadRequest,
+			message: "Request body is empty.",
+		},
+		{
+			name:    "wrong type",
+			body:    `{"name": "alice", "amount": -1}`,
+			status:  http.StatusBadRequest,
+			message: "Request payload is invalid.",
+			details: map[string]string{"amount": "must be a non-negative integer"},
+		},
+		{
+			name:    "malformed",
+			body:    `{"name": `,
+			status:  http.StatusBadRequest,
+			message: "Request body is not valid JSON: unexpected EOF",
+		},
+		{
+			name:    "failed validation",
+			body:    `{"name": "alexandria"}`,
+			status:  http.StatusBadRequest,
+			message: "Request payload is invalid.",
+			details: map[string]string{"name": "must be at most 8 characters"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+
+			var payload decodeTestRequest
+			err := decodeRequest(w, r, &payload)
+			if test.status == 0 {
+				if err != nil {
+					t.Fatalf("expected body to be decoded, got %v", err)
+				}
+				if payload.Name != "alice" {
+					t.Fatalf("expected name alice, got %q", payload.Name)
+				}
+				return
+			}
+
+			var apiErr *response.Error
+			if !errors.As(err, &apiErr) {
+				t.Fatalf("expected response error, got %v", err)
+			}
+			if apiErr.Status != test.status || apiErr.Message != test.message {
+				t.Fatalf("expected %d %q, got %d %q", test.status, test.message, apiErr.Status, apiErr.Message)
+			}
+			if !reflect.DeepEqual(apiErr.Details, test.details) {
+				t.Fatalf("expected details %v, got %v", test.details, apiErr.Details)
+			}
+		})
+	}
+}
+
+func TestDecodeOptionalRequest(t *testing.T) {
+	tests := []struct {
+		name string
+		body string
+		err  bool
+	}{
+		{name: "empty body", body: ""},
+		{name: "whitespace", body: "\n"},
+		{name: "payload", body: `{"name": "alice"}`},
+		{name: "malformed", body: `{"name": `, err: true},
+		{name: "failed validation", body: `{"name": "alexandria"}`, err: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := httptest.NewRequest(http.MethodPost, "/", strings.NewReader(test.body))
+			r.ContentLength = -1
+
+			payload := decodeTestRequest{Name: "alice"}
+			err := decodeOptionalRequest(httptest.NewRecorder(), r, &payload)
+			if test.err != (err != nil) {
+				t.Fatalf("expected error %t, got %v", test.err, err)
+			}
+			if !test.err && payload.Name != "alice" {
+				t.Fatalf("expected name alice, got %q", payload.Name)
+			}
+		})
+	}
+}
@@ -3,22 +3,46 @@ package handlers
 import (
 	"net/http"
 
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
 	"github.com/vladtenlive/ton-donate/pkg/storage"
-	"github.com/vladtenlive/ton-donate/pkg/utils"
+	"github.com/vladtenlive/ton-donate/pkg/ton"
+	"github.com/vladtenlive/ton-donate/pkg/twitch"
 )
 
 type Service struct {
-	client       *http.Client
-	storage      storage.Storage
-	mongoStorage *storage.MongoStorage
-	auth         *utils.Auth
+	client          *http.Client
+	streamers       storage.StreamerRepository
+	donations       storage.DonationRepository
+	widgets         storage.WidgetRepository
+	sessions        storage.SessionRepository
+	refreshTokens   storage.RefreshTokenRepository
+	grants          storage.GrantRepository
+	nonces          storage.NonceRepository
+	contractAddress string
+	proofVerifier   *ton.ProofVerifier
+	twitch          *twitch.Client     // nil when Twitch integration is not configured
+	walletTokens    *auth.WalletTokens // nil when sign in with TON wallet is not configured
+	notifier        *ton.Notifier
 }
 
-func NewService(client *http.Client, storage storage.Storage, mongoStorage *storage.MongoStorage, auth *utils.Auth) *Service {
+func NewService(client *http.Client, repositories storage.Repositories, contractAddress string, proofVerifier *ton.ProofVerifier, twitch *twitch.Client, walletTokens *auth.WalletTokens, notifier *ton.Notifier) *Service {
 	return &Service{
-		client:       client,
-		storage:      nil,
-		mongoStorage: mongoStorage,
-		auth:         auth,
+		client:          client,
+		streamers:       repositories.Streamers,
+		donations:       repositories.Donations,
+		widgets:         repositories.Widgets,
+		sessions:        repositories.Sessions,
+		refreshTokens:   repositories.RefreshTokens,
+		grants:          repositories.Grants,
+		nonces:          repositories.Nonces,
+		contractAddress: contractAddress,
+		proofVerifier:   proofVerifier,
+		twitch:          twitch,
+		walletTokens:    walletTokens,
+		notifier:        notifier,
 	}
 }
+
+// errUnauthorized is returned by handlers mounted without authentication middleware.
+var errUnauthorized = response.Unauthorized("Authorization token is missing or invalid.")
@@ -0,0 +1,178 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+const sessionsLimit = 50
+
+type StartSessionRequest struct {
+	Title string `json:"title,omitempty" validate:"max=140"`
+}
+
+// StartSessionHandler starts stream session manually, there could be only one active session.
+func (s *Service) StartSessionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	var payload StartSessionRequest
+	if err := decodeOptionalRequest(w, r, &payload); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	active, err := s.sessions.GetActiveSession(ctx, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load active session.", err))
+		return
+	} else if active != nil {
+		response.WriteErrorData(w, response.Conflict("Stream session is already active."), active)
+		return
+	}
+
+	session, err := s.sessions.StartSession(ctx, storage.StreamSession{
+		StreamerId: streamerId,
+		Title:      payload.Title,
+		Source:     storage.SessionSourceManual,
+		StartedAt:  time.Now().UTC(),
+	})
+	if errors.Is(err, storage.ErrSessionActive) {
+		// Another session was started after the check above.
+		response.WriteError(w, response.Conflict("Stream session is already active."))
+		return
+	} else if err != nil {
+		response.WriteError(w, response.Internal("Failed to start session.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusCreated, session)
+}
+
+func (s *Service) EndSessionHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	sessionId := chi.URLParam(r, "id")
+	if sessionId == "current" {
+		sessionId = ""
+	}
+
+	session, err := s.sessions.EndSession(ctx, streamerId, sessionId)
+	writeSession(w, session, err)
+}
+
+func (s *Service) GetSessionsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	sessions, err := s.sessions.GetSessions(ctx, streamerId, sessionsLimit)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load sessions.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, sessions)
+}
+
+type GetSessionSummaryModel struct {
+	Session   *storage.StreamSession `json:"session"`
+	Analytics *storage.Analytics     `json:"analytics"`
+	TopDonors *storage.TopDonors     `json:"top_donors"`
+}
+
+// GetSessionSummaryHandler returns session totals bucketed by hour and its top donors, "current" is the active session.
+func (s *Service) GetSessionSummaryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	session, err := s.loadSession(r, streamerId, chi.URLParam(r, "id"))
+	if err != nil || session == nil {
+		writeSession(w, session, err)
+		return
+	}
+
+	analytics, err := s.donations.GetAnalytics(ctx, storage.AnalyticsQuery{
+		StreamerId: streamerId,
+		SessionId:  session.Id.Hex(),
+		Interval:   storage.IntervalHour,
+		Timezone:   "UTC",
+		Currency:   storage.CurrencyTON,
+	})
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load session analytics.", err))
+		return
+	}
+
+	topDonors, err := s.donations.GetTopDonors(ctx, storage.TopDonorsQuery{
+		StreamerId: streamerId,
+		SessionId:  session.Id.Hex(),
+		GroupBy:    storage.DonorByNickname,
+		Limit:      defaultTopDonorsLimit,
+	})
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load session top donors.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &GetSessionSummaryModel{session, analytics, topDonors})
+}
+
+// GetSessionTopDonorsHandler is the leaderboard of one session, accepts the same parameters as GetTopDonorsHandler.
+func (s *Service) GetSessionTopDonorsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	query.Set("session", chi.URLParam(r, "id"))
+	r.URL.RawQuery = query.Encode()
+
+	s.GetTopDonorsHandler(w, r)
+}
+
+// loadSession loads session by id, "current" stands for the active one.
+func (s *Service) loadSession(r *http.Request, streamerId string, sessionId string) (*storage.StreamSession, error) {
+	if sessionId == "current" {
+		return s.sessions.GetActiveSession(r.Context(), streamerId)
+	}
+
+	return s.sessions.GetSession(r.Context(), streamerId, sessionId)
+}
+
+func writeSession(w http.ResponseWriter, session *storage.StreamSession, err error) {
+	if errors.Is(err, storage.ErrInvalidSessionId) {
+		response.WriteError(w, response.Validation(err.Error()))
+		return
+	} else if err != nil {
+		response.WriteError(w, response.Internal("Failed to load stream session.", err))
+		return
+	} else if session == nil {
+		response.WriteError(w, response.NotFound("Stream session does not exist or is not active."))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, session)
+}
@@ -1,61 +1,69 @@
 package handlers
 
 import (
-	"encoding/json"
+	"context"
+	"errors"
 	"net/http"
+	"regexp"
 
-	"github.com/labstack/gommon/log"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
 	"github.com/vladtenlive/ton-donate/pkg/storage"
-	parsers "github.com/vladtenlive/ton-donate/pkg/utils/parsers"
+	"github.com/vladtenlive/ton-donate/pkg/ton"
+	"github.com/vladtenlive/ton-donate/pkg/utils"
 )
 
-type GetStreamerResponse struct {
-	Data  *GetStreamerModel `json:"data"`
-	Error string            `json:"error"`
-}
-
 type GetStreamerModel struct {
 	StreamerId    string `json:"streamerId,omitempty"`
 	WalletAddress string `json:"wallet_address,omitempty"`
+	Slug          string `json:"slug,omitempty"`
+	DisplayName   string `json:"display_name,omitempty"`
+	AvatarUrl     string `json:"avatar_url,omitempty"`
+	Description   string `json:"description,omitempty"`
+
+	Wallets []storage.StreamerWallet `json:"wallets,omitempty"`
 }
 
+var slugRegexp = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{2,31}$`)
+
 func (s *Service) GetStreamerHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	cognitoId := parsers.GetCognitoId(r, s.auth)
-	if cognitoId == "" {
-		response, _ := json.Marshal(&GetStreamerResponse{nil, "Failed to parse cognito id."})
-
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+	streamerId := auth.StreamerId(ctx)
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
 		return
 	}
 
-	streamer, err := s.mongoStorage.GetStreamerByCognitoId(ctx, cognitoId)
-	if err != nil || streamer == nil {
-		response, _ := json.Marshal(&GetStreamerResponse{nil, "Streamer with such id does not exist."})
-
-		w.WriteHeader(http.StatusNotFound)
-		w.Write(response)
+	// Streamers signed in with a wallet have no identity provider account.
+	streamer, err := s.streamers.GetStreamerByStreamerId(ctx, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	} else if streamer == nil {
+		response.WriteError(w, response.NotFound("Streamer with such id does not exist."))
 		return
 	}
 
-	response, _ := json.Marshal(
-		&GetStreamerResponse{
-			&GetStreamerModel{
-				StreamerId:    streamer.StreamerId,
-				WalletAddress: streamer.WalletAddress}, ""})
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	response.WriteJSON(w, http.StatusOK, &GetStreamerModel{
+		StreamerId:    streamer.StreamerId,
+		WalletAddress: streamer.WalletAddress,
+		Slug:          streamer.Slug,
+		DisplayName:   streamer.DisplayName,
+		AvatarUrl:     streamer.AvatarUrl,
+		Description:   streamer.Description,
+		Wallets:       streamer.GetWallets()})
 }
 
 type SaveStreamerRequest struct {
-	WalletAddress string `json:"wallet_address,omitempty"`
-}
-
-type RegisterStreamerResponse struct {
-	Data  SaveStreamerModel `json:"data"`
-	Error string            `json:"error"`
+	WalletAddress string `json:"wallet_address,omitempty" validate:"required,ton_address"`
+	Slug          string `json:"slug,omitempty" validate:"max=32"`
+	DisplayName   string `json:"display_name,omitempty" validate:"max=64"`
+	AvatarUrl     string `json:"avatar_url,omitempty" validate:"max=2048,url"`
+	Description   string `json:"description,omitempty" validate:"max=1000"`
+
+	// Required when wallet address changes, see GetProofPayloadHandler.
+	Proof *ton.Proof `json:"proof,omitempty"`
 }
 
 type SaveStreamerModel struct {
@@ -66,57 +74,147 @@ func (s *Service) SaveStreamerHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
 	var payload SaveStreamerRequest
-	err := json.NewDecoder(r.Body).Decode(&payload)
-
+	err := decodeRequest(w, r, &payload)
 	if err != nil {
-		response, _ := json.Marshal(&GetStreamerResponse{nil, "Failed to parse new streamer payload."})
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+		response.WriteError(w, err)
 		return
 	}
 
-	streamerId := parsers.GetStreamerId(r, s.auth)
+	streamerId := auth.StreamerId(r.Context())
 	if streamerId == "" {
-		response, _ := json.Marshal(&GetStreamerResponse{nil, "Failed to parse cognito id!"})
+		response.WriteError(w, errUnauthorized)
+		return
+	}
 
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+	wallet, err := utils.ParseWalletAddress(payload.WalletAddress)
+	if err != nil {
+		response.WriteError(w, response.Validation("Wrong wallet address: "+err.Error()))
 		return
 	}
+	payload.WalletAddress = utils.FormatWalletAddress(wallet)
+
+	existing, err := s.streamers.GetStreamerByStreamerId(ctx, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	}
+
+	// New wallet is accepted only with a proof that the streamer owns it.
+	if existing == nil || existing.WalletAddress != payload.WalletAddress {
+		err = s.proofVerifier.Verify(ctx, wallet, payload.Proof, streamerId)
+		if err != nil {
+			response.WriteError(w, response.Forbidden("Failed to verify wallet ownership: "+err.Error()))
+			return
+		}
+	}
 
 	// Check if another streamer registered such wallet, will allow to update to the same if streamer is the same
-	foundStreamer, err := s.mongoStorage.GetStreamerByWalletAddress(ctx, payload.WalletAddress)
+	foundStreamer, err := s.streamers.GetStreamerByWalletAddress(ctx, payload.WalletAddress)
 	if err != nil {
-		response, _ := json.Marshal(&GetStreamerResponse{nil, "Failed to verify streamer's wallet address."})
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+		response.WriteError(w, response.Internal("Failed to verify streamer's wallet address.", err))
 		return
 	} else if foundStreamer != nil && streamerId != foundStreamer.StreamerId {
-		response, _ := json.Marshal(&GetStreamerResponse{nil, "Streamer with this wallet address has been already registered."})
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+		response.WriteError(w, response.Conflict("Streamer with this wallet address has been already registered."))
 		return
 	}
 
+	if payload.Slug != "" {
+		if !slugRegexp.MatchString(payload.Slug) {
+			response.WriteError(w, response.Validation("Slug must be 3-32 lowercase letters, digits, '-' or '_'."))
+			return
+		}
+
+		slugStreamer, err := s.streamers.GetStreamerBySlug(ctx, payload.Slug)
+		if err != nil {
+			response.WriteError(w, response.Internal("Failed to verify streamer's slug.", err))
+			return
+		} else if slugStreamer != nil && streamerId != slugStreamer.StreamerId {
+			response.WriteError(w, response.Conflict("Streamer with this slug has been already registered."))
+			return
+		}
+	}
+
 	streamer := storage.Streamer{
 		WalletAddress: payload.WalletAddress,
 		StreamerId:    streamerId,
 		CognitoId:     streamerId, // ToDo: get from cognito, but they are actually same guid.
+		Slug:          payload.Slug,
+		DisplayName:   payload.DisplayName,
+		AvatarUrl:     payload.AvatarUrl,
+		Description:   payload.Description,
+	}
+
+	// Keep profile fields that were not sent in this request.
+	if existing != nil {
+		if streamer.Slug == "" {
+			streamer.Slug = existing.Slug
+		}
+		if streamer.DisplayName == "" {
+			streamer.DisplayName = existing.DisplayName
+		}
+		if streamer.AvatarUrl == "" {
+			streamer.AvatarUrl = existing.AvatarUrl
+		}
+		if streamer.Description == "" {
+			streamer.Description = existing.Description
+		}
 	}
 
-	_, err = s.mongoStorage.SaveStreamer(ctx, streamer)
+	err = s.streamers.SaveStreamer(ctx, streamer)
+	if errors.Is(err, storage.ErrWalletAddressTaken) {
+		// Another streamer registered the wallet after the check above.
+		response.WriteError(w, response.Conflict("Streamer with this wallet address has been already registered."))
+		return
+	} else if errors.Is(err, storage.ErrSlugTaken) {
+		// Another streamer claimed the slug after the check above.
+		response.WriteError(w, response.Conflict("Streamer with this slug has been already registered."))
+		return
+	} else if err != nil {
+		response.WriteError(w, response.Internal("Failed to save streamer.", err))
+		return
+	}
+
+	// Rotation: previous primary wallet is retired but stays in the history.
+	err = s.rotatePrimaryWallet(ctx, streamerId, existing, streamer.WalletAddress)
 	if err != nil {
-		log.Error(err)
+		response.WriteError(w, response.Internal("Failed to update streamer wallets.", err))
+		return
+	}
 
-		response, _ := json.Marshal(&RegisterStreamerResponse{Error: "Failed to parse streamer id!"})
+	response.WriteJSON(w, http.StatusCreated, &SaveStreamerModel{streamerId})
+}
+
+func (s *Service) rotatePrimaryWallet(ctx context.Context, streamerId string, existing *storage.Streamer, walletAddress string) error {
+	if existing != nil && existing.WalletAddress != "" && existing.WalletAddress != walletAddress {
+		// Streamers registered before wallet history have no wallets list yet.
+		if err := s.streamers.AddStreamerWallet(ctx, streamerId, existing.WalletAddress); err != nil {
+			return err
+		}
+		if _, err := s.streamers.RetireStreamerWallet(ctx, streamerId, existing.WalletAddress); err != nil {
+			return err
+		}
+	}
 
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+	return s.streamers.AddStreamerWallet(ctx, streamerId, walletAddress)
+}
+
+type GetProofPayloadModel struct {
+	Payload string `json:"payload"`
+}
+
+// GetProofPayloadHandler issues payload for TON Connect ton_proof request used to register a wallet.
+func (s *Service) GetProofPayloadHandler(w http.ResponseWriter, r *http.Request) {
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
 		return
 	}
 
-	response, _ := json.Marshal(&RegisterStreamerResponse{SaveStreamerModel{streamerId}, ""})
+	payload, err := s.proofVerifier.IssuePayload(r.Context(), streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to generate proof payload.", err))
+		return
+	}
 
-	w.Write(response)
-	w.WriteHeader(http.StatusCreated)
+	response.WriteJSON(w, http.StatusOK, &GetProofPayloadModel{payload})
 }
@@ -0,0 +1,235 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/labstack/gommon/log"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/vladtenlive/ton-donate/pkg/twitch"
+)
+
+// Twitch sends EventSub notifications well below this size.
+const maxEventSubBody = 1 << 20
+
+type GetTwitchLinkModel struct {
+	Url string `json:"url"`
+}
+
+// GetTwitchLinkHandler returns Twitch authorization url, consent redirects to TwitchCallbackHandler.
+func (s *Service) GetTwitchLinkHandler(w http.ResponseWriter, r *http.Request) {
+	if s.twitch == nil {
+		writeTwitchDisabled(w)
+		return
+	}
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &GetTwitchLinkModel{s.twitch.AuthorizeUrl(streamerId)})
+}
+
+// TwitchCallbackHandler is the OAuth redirect target, streamer is taken from the signed state
+// since the browser redirect carries no authorization header.
+func (s *Service) TwitchCallbackHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if s.twitch == nil {
+		writeTwitchDisabled(w)
+		return
+	}
+
+	query := r.URL.Query()
+	if query.Get("error") != "" {
+		response.WriteError(w, response.Validation("Twitch authorization was declined: "+query.Get("error_description")))
+		return
+	}
+
+	streamerId, err := s.twitch.VerifyState(query.Get("state"))
+	if err != nil {
+		response.WriteError(w, response.Validation("Twitch authorization state is invalid or expired."))
+		return
+	}
+
+	user, err := s.twitch.GetLinkedUser(ctx, query.Get("code"))
+	if err != nil {
+		response.WriteError(w, response.Upstream("Failed to authorize with Twitch.", err))
+		return
+	}
+
+	linked, err := s.streamers.GetStreamerByTwitchUserId(ctx, user.Id)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to verify Twitch account.", err))
+		return
+	} else if linked != nil && linked.StreamerId != streamerId {
+		response.WriteError(w, response.Conflict("This Twitch account is linked to another streamer."))
+		return
+	}
+
+	account := &storage.TwitchAccount{
+		UserId:      user.Id,
+		Login:       user.Login,
+		DisplayName: user.DisplayName,
+		LinkedAt:    time.Now().UTC(),
+	}
+	err = s.streamers.SetStreamerTwitch(ctx, streamerId, account)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to link Twitch account.", err))
+		return
+	}
+
+	for _, eventType := range []string{twitch.EventStreamOnline, twitch.EventStreamOffline} {
+		err = s.twitch.Subscribe(ctx, eventType, user.Id)
+		if err != nil {
+			response.WriteErrorData(w, response.Upstream("Twitch account is linked, but stream events subscription failed.", err), account)
+			return
+		}
+	}
+
+	response.WriteJSON(w, http.StatusOK, account)
+}
+
+func (s *Service) UnlinkTwitchHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	// Subscriptions stay on Twitch side, their events are ignored once no streamer has the channel.
+	err := s.streamers.SetStreamerTwitch(ctx, streamerId, nil)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to unlink Twitch account.", err))
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// TwitchEventSubHandler receives EventSub webhooks: answers the challenge handshake
+// and starts or ends stream sessions of the linked streamer.
+func (s *Service) TwitchEventSubHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	if s.twitch == nil {
+		writeTwitchDisabled(w)
+		return
+	}
+
+	body, err := io.ReadAll(io.LimitReader(r.Body, maxEventSubBody))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	message, err := s.twitch.VerifyEventSub(r.Header, body)
+	if err != nil {
+		w.WriteHeader(http.StatusForbidden)
+		return
+	}
+
+	switch r.Header.Get("Twitch-Eventsub-Message-Type") {
+	case twitch.MessageTypeVerification:
+		w.Header().Set("Content-Type", "text/plain")
+		w.WriteHeader(http.StatusOK)
+		w.Write([]byte(message.Challenge))
+		return
+	case twitch.MessageTypeRevocation:
+		log.Warnf("Twitch revoked %s subscription %s: %s", message.Subscription.Type, message.Subscription.Id, message.Subscription.Status)
+		w.WriteHeader(http.StatusNoContent)
+		return
+	case twitch.MessageTypeNotification:
+	default:
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	// Twitch delivers at least once, each message is handled by the first delivery only.
+	messageKey := "twitch_eventsub:" + r.Header.Get("Twitch-Eventsub-Message-Id")
+	err = s.nonces.CreateNonce(ctx, storage.Nonce{Key: messageKey, ExpiresAt: time.Now().Add(twitch.MaxMessageAge)})
+	if errors.Is(err, storage.ErrDuplicate) {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	} else if err != nil {
+		log.Error(err)
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	if err := s.handleStreamEvent(ctx, message); err != nil {
+		log.Error(err)
+		// Twitch retries notifications which were not acknowledged with 2xx, let the retry through.
+		if _, err := s.nonces.ConsumeNonce(ctx, messageKey); err != nil {
+			log.Error(err)
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (s *Service) handleStreamEvent(ctx context.Context, message *twitch.EventSubMessage) error {
+	streamer, err := s.streamers.GetStreamerByTwitchUserId(ctx, message.Event.BroadcasterUserId)
+	if err != nil || streamer == nil {
+		return err
+	}
+
+	switch message.Subscription.Type {
+	case twitch.EventStreamOnline:
+		return s.startTwitchSession(ctx, streamer.StreamerId, message)
+	case twitch.EventStreamOffline:
+		return s.endTwitchSession(ctx, streamer.StreamerId)
+	}
+	return nil
+}
+
+func (s *Service) startTwitchSession(ctx context.Context, streamerId string, message *twitch.EventSubMessage) error {
+	// Manually started session or a redelivered notification keeps the active session.
+	active, err := s.sessions.GetActiveSession(ctx, streamerId)
+	if err != nil || active != nil {
+		return err
+	}
+
+	startedAt, err := time.Parse(time.RFC3339, message.Event.StartedAt)
+	if err != nil {
+		startedAt = time.Now()
+	}
+
+	_, err = s.sessions.StartSession(ctx, storage.StreamSession{
+		StreamerId: streamerId,
+		Source:     storage.SessionSourceTwitch,
+		ExternalId: message.Event.Id,
+		StartedAt:  startedAt.UTC(),
+	})
+	if errors.Is(err, storage.ErrSessionActive) {
+		// Started concurrently, by the streamer or another delivery of the notification.
+		return nil
+	}
+	return err
+}
+
+// endTwitchSession ends the active session if Twitch started it, manual sessions are ended by the streamer.
+func (s *Service) endTwitchSession(ctx context.Context, streamerId string) error {
+	active, err := s.sessions.GetActiveSession(ctx, streamerId)
+	if err != nil || active == nil || active.Source != storage.SessionSourceTwitch {
+		return err
+	}
+
+	_, err = s.sessions.EndSession(ctx, streamerId, active.Id.Hex())
+	return err
+}
+
+func writeTwitchDisabled(w http.ResponseWriter) {
+	response.WriteError(w, response.NotFound("Twitch integration is not configured."))
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/vladtenlive/ton-donate/pkg/twitch"
+)
+
+const eventSubSecret = "eventsub secret"
+
+// eventSubRequest is an EventSub webhook delivery as Twitch signs it.
+func eventSubRequest(messageType string, messageId string, body string) *http.Request {
+	timestamp := time.Now().UTC().Format(time.RFC3339Nano)
+
+	mac := hmac.New(sha256.New, []byte(eventSubSecret))
+	mac.Write([]byte(messageId + timestamp + body))
+
+	r := httptest.NewRequest(http.MethodPost, "/twitch/eventsub", strings.NewReader(body))
+	r.Header.Set("Twitch-Eventsub-Message-Type", messageType)
+	r.Header.Set("Twitch-Eventsub-Message-Id", messageId)
+	r.Header.Set("Twitch-Eventsub-Message-Timestamp", timestamp)
+	r.Header.Set("Twitch-Eventsub-Message-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+	return r
+}
+
+func streamEvent(eventType string) string {
+	return `{"subscription": {"type": "` + eventType + `"}, "event": {"id": "stream-1", "broadcaster_user_id": "42", "started_at": "2026-01-02T15:04:05Z"}}`
+}
+
+func TestTwitchEventSubHandler(t *testing.T) {
+	ctx := context.Background()
+	memory := storage.NewMemoryStorage()
+	if err := memory.SaveStreamer(ctx, storage.Streamer{StreamerId: "streamer-1"}); err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.SetStreamerTwitch(ctx, "streamer-1", &storage.TwitchAccount{UserId: "42"}); err != nil {
+		t.Fatal(err)
+	}
+
+	client := twitch.NewClient(http.DefaultClient, twitch.Config{ClientId: "client", EventSubSecret: eventSubSecret})
+	s := NewService(http.DefaultClient, memory.Repositories(), "", nil, client, nil, nil)
+
+	// Steps run in order against the same storage.
+	tests := []struct {
+		name    string
+		request func() *http.Request
+		// before runs ahead of the request.
+		before func(t *testing.T)
+		status int
+		body   string
+		// active is the source of the active session after the request, empty for none.
+		active string
+	}{
+		{
+			name: "challenge",
+			request: func() *http.Request {
+				return eventSubRequest(twitch.MessageTypeVerification, "message-1", `{"challenge": "pogchamp"}`)
+			},
+			status: http.StatusOK,
+			body:   "pogchamp",
+		},
+		{
+			name: "bad signature",
+			request: func() *http.Request {
+				r := eventSubRequest(twitch.MessageTypeNotification, "message-2", streamEvent(twitch.EventStreamOnline))
+				r.Header.Set("Twitch-Eventsub-Message-Signature", "sha256=00")
+				return r
+			},
+			status: http.StatusForbidden,
+		},
+		{
+			name: "stream online starts session",
+			request: func() *http.Request {
+				return eventSubRequest(twitch.MessageTypeNotification, "message-3", streamEvent(twitch.EventStreamOnline))
+			},
+			status: http.StatusNoContent,
+			active: storage.SessionSourceTwitch,
+		},
+		{
+			name: "redelivered message is dropped",
+			before: func(t *testing.T) {
+				if _, err := memory.EndSession(ctx, "streamer-1", ""); err != nil {
+					t.Fatal(err)
+				}
+			},
+			request: func() *http.Request {
+				return eventSubRequest(twitch.MessageTypeNotification, "message-3", streamEvent(twitch.EventStreamOnline))
+			},
+			status: http.StatusNoContent,
+		},
+		{
+			name: "stream offline keeps manual session",
+			before: func(t *testing.T) {
+				_, err := memory.StartSession(ctx, storage.StreamSession{
+					StreamerId: "streamer-1",
+					Source:     storage.SessionSourceManual,
+					StartedAt:  time.Now().UTC(),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			request: func() *http.Request {
+				return eventSubRequest(twitch.MessageTypeNotification, "message-4", streamEvent(twitch.EventStreamOffline))
+			},
+			status: http.StatusNoContent,
+			active: storage.SessionSourceManual,
+		},
+		{
+			name: "stream online keeps manual session",
+			request: func() *http.Request {
+				return eventSubRequest(twitch.MessageTypeNotification, "message-5", streamEvent(twitch.EventStreamOnline))
+			},
+			status: http.StatusNoContent,
+			active: storage.SessionSourceManual,
+		},
+		{
+			name: "stream offline ends twitch session",
+			before: func(t *testing.T) {
+				if _, err := memory.EndSession(ctx, "streamer-1", ""); err != nil {
+					t.Fatal(err)
+				}
+				_, err := memory.StartSession(ctx, storage.StreamSession{
+					StreamerId: "streamer-1",
+					Source:     storage.SessionSourceTwitch,
+					StartedAt:  time.Now().UTC(),
+				})
+				if err != nil {
+					t.Fatal(err)
+				}
+			},
+			request: func() *http.Request {
+				return eventSubRequest(twitch.MessageTypeNotification, "message-6", streamEvent(twitch.EventStreamOffline))
+			},
+			status: http.StatusNoContent,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if test.before != nil {
+				test.before(t)
+			}
+
+			w := httptest.NewRecorder()
+			s.TwitchEventSubHandler(w, test.request())
+
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body)
+			}
+			if test.body != "" && w.Body.String() != test.body {
+				t.Fatalf("expected body %q, got %q", test.body, w.Body)
+			}
+
+			active, err := memory.GetActiveSession(ctx, "streamer-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			switch {
+			case test.active == "" && active != nil:
+				t.Fatalf("expected no active session, got %+v", active)
+			case test.active != "" && (active == nil || active.Source != test.active):
+				t.Fatalf("expected active %s session, got %+v", test.active, active)
+			}
+		})
+	}
+}
@@ -0,0 +1,145 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/ton"
+	"github.com/vladtenlive/ton-donate/pkg/utils"
+)
+
+func (s *Service) GetWalletsHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	streamer, err := s.streamers.GetStreamerByStreamerId(ctx, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	} else if streamer == nil {
+		response.WriteError(w, response.NotFound("Streamer with such id does not exist."))
+		return
+	}
+
+	wallets := streamer.GetWallets()
+	response.WriteJSON(w, http.StatusOK, &wallets)
+}
+
+type AddWalletRequest struct {
+	WalletAddress string     `json:"wallet_address" validate:"required,ton_address"`
+	Proof         *ton.Proof `json:"proof" validate:"required"`
+}
+
+type WalletModel struct {
+	WalletAddress string `json:"wallet_address"`
+}
+
+// AddWalletHandler adds one more active payout wallet, ownership is proven the same way as on registration.
+func (s *Service) AddWalletHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	var payload AddWalletRequest
+	err := decodeRequest(w, r, &payload)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	wallet, err := utils.ParseWalletAddress(payload.WalletAddress)
+	if err != nil {
+		response.WriteError(w, response.Validation("Wrong wallet address: "+err.Error()))
+		return
+	}
+	walletAddress := utils.FormatWalletAddress(wallet)
+
+	streamer, err := s.streamers.GetStreamerByStreamerId(ctx, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	} else if streamer == nil {
+		response.WriteError(w, response.NotFound("Streamer with such id does not exist."))
+		return
+	}
+
+	foundStreamer, err := s.streamers.GetStreamerByWalletAddress(ctx, walletAddress)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to verify streamer's wallet address.", err))
+		return
+	} else if foundStreamer != nil && foundStreamer.StreamerId != streamerId {
+		response.WriteError(w, response.Conflict("Streamer with this wallet address has been already registered."))
+		return
+	}
+
+	err = s.proofVerifier.Verify(ctx, wallet, payload.Proof, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Forbidden("Failed to verify wallet ownership: "+err.Error()))
+		return
+	}
+
+	// Seed history with the primary wallet of streamers registered before it existed.
+	if len(streamer.Wallets) == 0 && streamer.WalletAddress != "" {
+		err = s.streamers.AddStreamerWallet(ctx, streamerId, streamer.WalletAddress)
+	}
+	if err == nil {
+		err = s.streamers.AddStreamerWallet(ctx, streamerId, walletAddress)
+	}
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to save streamer wallet.", err))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusCreated, &WalletModel{walletAddress})
+}
+
+// RetireWalletHandler stops accepting donations to the wallet, its past donations still belong to the streamer.
+func (s *Service) RetireWalletHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	walletAddress, err := utils.NormalizeWalletAddress(chi.URLParam(r, "address"))
+	if err != nil {
+		response.WriteError(w, response.Validation("Wrong wallet address: "+err.Error()))
+		return
+	}
+
+	streamer, err := s.streamers.GetStreamerByStreamerId(ctx, streamerId)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer.", err))
+		return
+	} else if streamer == nil {
+		response.WriteError(w, response.NotFound("Streamer with such id does not exist."))
+		return
+	} else if streamer.WalletAddress == walletAddress {
+		response.WriteError(w, response.Validation("Primary wallet can not be retired, register another primary wallet first."))
+		return
+	}
+
+	retired, err := s.streamers.RetireStreamerWallet(ctx, streamerId, walletAddress)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to retire streamer wallet.", err))
+		return
+	} else if !retired {
+		response.WriteError(w, response.NotFound("Active wallet with such address does not exist."))
+		return
+	}
+
+	response.WriteJSON(w, http.StatusOK, &WalletModel{walletAddress})
+}
@@ -2,117 +2,400 @@ package handlers
 
 import (
 	"encoding/json"
-	"fmt"
+	"errors"
 	"net/http"
+	"strings"
+	"time"
 
+	"github.com/go-chi/chi/v5"
+	"github.com/labstack/gommon/log"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/prices"
+	"github.com/vladtenlive/ton-donate/pkg/response"
 	"github.com/vladtenlive/ton-donate/pkg/storage"
-	parsers "github.com/vladtenlive/ton-donate/pkg/utils/parsers"
+	"github.com/vladtenlive/ton-donate/pkg/ton"
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
 )
 
-type GetWidgetListResponse struct {
-	Data  *[]GetWidgetListModel `json:"data"`
-	Error string                `json:"error"`
-}
-
 type GetWidgetListModel struct {
-	Type          string `json:"type,omitempty"`
-	AmountGoal    uint64 `json:"amount_goal,omitempty"`
-	AmountCurrent uint64 `json:"amount_current,omitempty"`
-	IsActive      bool   `json:"isActive,omitempty"`
+	Id             string `json:"id,omitempty"`
+	Type           string `json:"type,omitempty"`
+	Title          string `json:"title,omitempty"`
+	AmountGoal     uint64 `json:"amount_goal,omitempty"`
+	Currency       string `json:"currency,omitempty"`
+	AmountCurrent  uint64 `json:"amount_current,omitempty"`
+	DonationsCount uint64 `json:"donations_count,omitempty"`
+	IsActive       bool   `json:"isActive,omitempty"`
+
+	// One of widgets package configs, depending on the type.
+	Config any `json:"config,omitempty"`
+
+	// Goal lifecycle, status is one of storage goal statuses.
+	Status      string     `json:"status,omitempty"`
+	StartsAt    *time.Time `json:"starts_at,omitempty"`
+	EndsAt      *time.Time `json:"ends_at,omitempty"`
+	CompletedAt *time.Time `json:"completed_at,omitempty"`
 }
 
 func (s *Service) GetWidgetsHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	streamerId := parsers.GetStreamerId(r, s.auth)
+	streamerId := auth.StreamerId(r.Context())
 	if streamerId == "" {
-		response, _ := json.Marshal(&GetWidgetListResponse{nil, "Failed to parse streamer id."})
-
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+		response.WriteError(w, errUnauthorized)
 		return
 	}
 
-	widgets, err := s.mongoStorage.GetWidgets(ctx, streamerId)
+	archived := r.URL.Query().Get("archived") == "true"
+	streamerWidgets, err := s.widgets.GetWidgets(ctx, streamerId, archived)
 	if err != nil {
-		response, _ := json.Marshal(&GetWidgetListResponse{nil, "Failed to load streamer widgets info."})
-
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+		response.WriteError(w, response.Internal("Failed to load streamer widgets info.", err))
 		return
 	}
 
 	widgetsModel := make([]GetWidgetListModel, 0)
-	for _, widget := range *widgets {
-		widgetsModel = append(widgetsModel, GetWidgetListModel{
-			Type:          widget.Type,
-			AmountGoal:    widget.AmountGoal,
-			AmountCurrent: widget.AmountCurrent,
-			IsActive:      widget.IsActive})
+	for _, widget := range *streamerWidgets {
+		widgetsModel = append(widgetsModel, toWidgetModel(widget))
 	}
-	response, _ := json.Marshal(&GetWidgetListResponse{&widgetsModel, ""})
+	response.WriteJSON(w, http.StatusOK, &widgetsModel)
+}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+func toWidgetModel(widget storage.Widget) GetWidgetListModel {
+	return GetWidgetListModel{
+		Id:             widget.Id.Hex(),
+		Type:           widget.Type,
+		Title:          widget.Title,
+		AmountGoal:     widget.AmountGoal,
+		Currency:       widget.Currency,
+		AmountCurrent:  widget.AmountCurrent,
+		DonationsCount: widget.DonationsCount,
+		IsActive:       widget.IsActive,
+		Config:         widget.Config.Value(),
+		Status:         widget.GoalStatus(time.Now()),
+		StartsAt:       widget.StartsAt,
+		EndsAt:         widget.EndsAt,
+		CompletedAt:    widget.CompletedAt}
 }
 
 type CreateWidgetRequest struct {
-	Type          string `json:"type,omitempty"`
-	AmountGoal    uint64 `json:"amount_goal,omitempty"`
-	AmountCurrent uint64 `json:"amount_current,omitempty"`
-}
+	Type       string `json:"type,omitempty" validate:"required"`
+	Title      string `json:"title,omitempty" validate:"max=100"`
+	AmountGoal uint64 `json:"amount_goal,omitempty" validate:"max=1000000000000000"`
+	// Goal currency, TON by default. Fiat goal amounts are in cents.
+	Currency      string `json:"currency,omitempty" validate:"max=8"`
+	AmountCurrent uint64 `json:"amount_current,omitempty" validate:"max=1000000000000000"`
+	IsActive      *bool  `json:"isActive,omitempty"`
 
-type CreateWidgetResponse struct {
-	Data  *CreateWidgetResponseModel `json:"data"`
-	Error string                     `json:"error"`
+	// Configuration of the type, validated against its schema.
+	Config json.RawMessage `json:"config,omitempty"`
+
+	// Optional time box of a donation goal.
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
 }
 
 type CreateWidgetResponseModel struct {
-	WidgetId string `json:"widgetId,omitempty"`
+	WidgetId string              `json:"widgetId,omitempty"`
+	Widget   *GetWidgetListModel `json:"widget,omitempty"`
 }
 
 func (s *Service) CreateWidgetHandler(w http.ResponseWriter, r *http.Request) {
 	ctx := r.Context()
 
-	streamerId := parsers.GetStreamerId(r, s.auth)
+	streamerId := auth.StreamerId(r.Context())
 	if streamerId == "" {
-		response, _ := json.Marshal(&CreateWidgetResponse{nil, "Failed to parse streamer id."})
-
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+		response.WriteError(w, errUnauthorized)
 		return
 	}
 
 	var payload CreateWidgetRequest
-	err := json.NewDecoder(r.Body).Decode(&payload)
+	err := decodeRequest(w, r, &payload)
+	if err != nil {
+		response.WriteError(w, err)
+		return
+	}
 
+	if !widgets.IsValidType(payload.Type) {
+		response.WriteError(w, response.Validation("Unknown widget type, expected one of: "+strings.Join(widgets.Types, ", ")))
+		return
+	}
+
+	config, err := widgets.ParseConfig(payload.Type, payload.Config)
 	if err != nil {
-		response, _ := json.Marshal(&CreateWidgetResponse{nil, "Failed to parse widget payload."})
+		response.WriteError(w, response.Validation("Wrong widget config: "+err.Error()))
+		return
+	}
 
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+	if widgets.HasGoal(payload.Type) && payload.AmountGoal == 0 {
+		response.WriteError(w, response.Validation("Please provide amount goal."))
 		return
+	} else if !widgets.HasGoal(payload.Type) {
+		payload.AmountGoal = 0
+		payload.Currency = ""
+		payload.StartsAt = nil
+		payload.EndsAt = nil
+	}
+
+	if widgets.HasGoal(payload.Type) {
+		if payload.Currency == "" {
+			payload.Currency = storage.CurrencyTON
+		} else if payload.Currency != storage.CurrencyTON && !prices.IsFiatCurrency(payload.Currency) {
+			response.WriteError(w, response.Validation("Goal currency must be TON or one of: "+strings.Join(prices.FiatCurrencies(), ", ")))
+			return
+		}
 	}
 
-	// ToDo: Create streamer donations widget info based on streamer id.
+	if payload.StartsAt != nil && payload.EndsAt != nil && !payload.EndsAt.After(*payload.StartsAt) {
+		response.WriteError(w, response.Validation("Goal end date must be after its start date."))
+		return
+	}
+
+	now := time.Now().UTC()
+
 	widget := storage.Widget{
 		StreamerId:    streamerId,
 		Type:          payload.Type,
+		Title:         payload.Title,
 		AmountGoal:    payload.AmountGoal,
 		AmountCurrent: payload.AmountCurrent,
-		IsActive:      true, // ToDo: create active widget selection
+		Currency:      payload.Currency,
+		IsActive:      payload.IsActive == nil || *payload.IsActive,
+		Config:        config,
+		StartsAt:      payload.StartsAt,
+		EndsAt:        payload.EndsAt,
+		StartedAt:     &now,
+	}
+	// A goal carried over from elsewhere may be reached already, it is not credited any further then.
+	if widget.AmountGoal > 0 && widget.AmountCurrent >= widget.AmountGoal {
+		widget.IsCompleted = true
+		widget.CompletedAt = &now
+	}
+	created, err := s.widgets.CreateWidget(ctx, widget)
+	if err != nil {
+		response.WriteError(w, response.Internal("Failed to save streamer widget.", err))
+		return
 	}
-	result, err := s.mongoStorage.CreateWidget(ctx, widget)
+
+	model := toWidgetModel(*created)
+	response.WriteJSON(w, http.StatusCreated, &CreateWidgetResponseModel{created.Id.Hex(), &model})
+}
+
+func (s *Service) GetWidgetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	widget, err := s.widgets.GetWidget(ctx, streamerId, chi.URLParam(r, "id"))
+	writeWidget(w, widget, err)
+}
+
+type UpdateWidgetRequest struct {
+	Title      *string `json:"title,omitempty" validate:"max=100"`
+	AmountGoal *uint64 `json:"amount_goal,omitempty" validate:"min=1,max=1000000000000000"`
+	IsActive   *bool   `json:"isActive,omitempty"`
+
+	// Only sent fields of the config are changed.
+	Config json.RawMessage `json:"config,omitempty"`
+
+	StartsAt *time.Time `json:"starts_at,omitempty"`
+	EndsAt   *time.Time `json:"ends_at,omitempty"`
+}
+
+func (s *Service) UpdateWidgetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	var payload UpdateWidgetRequest
+	err := decodeRequest(w, r, &payload)
 	if err != nil {
-		response, _ := json.Marshal(&CreateWidgetResponse{nil, "Failed to load streamer widgets info."})
+		response.WriteError(w, err)
+		return
+	}
+
+	widgetId := chi.URLParam(r, "id")
+	widget, err := s.widgets.GetWidget(ctx, streamerId, widgetId)
+	if err != nil || widget == nil {
+		writeWidget(w, widget, err)
+		return
+	}
+
+	update := storage.WidgetUpdate{
+		Title:    payload.Title,
+		IsActive: payload.IsActive,
+	}
+
+	if payload.AmountGoal != nil {
+		if !widgets.HasGoal(widget.Type) {
+			response.WriteError(w, response.Validation("Amount goal can be set only for a donation goal widget."))
+			return
+		}
+		update.AmountGoal = payload.AmountGoal
+	}
 
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(response)
+	if payload.StartsAt != nil || payload.EndsAt != nil {
+		if !widgets.HasGoal(widget.Type) {
+			response.WriteError(w, response.Validation("Start and end dates can be set only for a donation goal widget."))
+			return
+		}
+
+		startsAt, endsAt := widget.StartsAt, widget.EndsAt
+		if payload.StartsAt != nil {
+			startsAt = payload.StartsAt
+		}
+		if payload.EndsAt != nil {
+			endsAt = payload.EndsAt
+		}
+		if startsAt != nil && endsAt != nil && !endsAt.After(*startsAt) {
+			response.WriteError(w, response.Validation("Goal end date must be after its start date."))
+			return
+		}
+
+		update.StartsAt = payload.StartsAt
+		update.EndsAt = payload.EndsAt
+	}
+
+	if len(payload.Config) > 0 {
+		config, err := widgets.UpdateConfig(widget.Type, widget.Config, payload.Config)
+		if err != nil {
+			response.WriteError(w, response.Validation("Wrong widget config: "+err.Error()))
+			return
+		}
+		update.Config = &config
+	}
+
+	widget, completed, err := s.widgets.UpdateWidget(ctx, streamerId, widgetId, update)
+	if completed && s.notifier != nil {
+		// The goal is updated already, a failed alert does not fail the request.
+		err := s.notifier.SendGoalCompleted(ton.GoalCompletedRequest{
+			WidgetId:      widget.Id.Hex(),
+			Title:         widget.Title,
+			AmountGoal:    widget.AmountGoal,
+			AmountCurrent: widget.AmountCurrent,
+			Currency:      widget.Currency,
+			StreamerId:    widget.StreamerId,
+		})
+		if err != nil {
+			log.Errorf("Failed to send goal completion of %s: %v", widget.Id.Hex(), err)
+		}
+	}
+	writeWidget(w, widget, err)
+}
+
+func (s *Service) DeleteWidgetHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	deleted, err := s.widgets.DeleteWidget(ctx, streamerId, chi.URLParam(r, "id"))
+	if err != nil || !deleted {
+		writeWidget(w, nil, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// writeWidget writes widget loaded by id, lookups are scoped by streamer so foreign widgets are reported as missing.
+func writeWidget(w http.ResponseWriter, widget *storage.Widget, err error) {
+	if errors.Is(err, storage.ErrInvalidWidgetId) {
+		response.WriteError(w, response.Validation(err.Error()))
+		return
+	} else if err != nil {
+		response.WriteError(w, response.Internal("Failed to load streamer widget.", err))
+		return
+	} else if widget == nil {
+		response.WriteError(w, response.NotFound("Widget does not exist."))
+		return
+	}
+
+	model := toWidgetModel(*widget)
+	response.WriteJSON(w, http.StatusOK, &model)
+}
+
+type ResetGoalRequest struct {
+	AmountGoal *uint64    `json:"amount_goal,omitempty" validate:"min=1,max=1000000000000000"`
+	StartsAt   *time.Time `json:"starts_at,omitempty"`
+	EndsAt     *time.Time `json:"ends_at,omitempty"`
+}
+
+// ResetGoalHandler saves current goal round into history and starts the next one, body is optional.
+// End date which has passed is cleared unless a new one is given.
+func (s *Service) ResetGoalHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	var payload ResetGoalRequest
+	if err := decodeOptionalRequest(w, r, &payload); err != nil {
+		response.WriteError(w, err)
+		return
+	}
+
+	if payload.StartsAt != nil && payload.EndsAt != nil && !payload.EndsAt.After(*payload.StartsAt) {
+		response.WriteError(w, response.Validation("Goal end date must be after its start date."))
+		return
+	} else if payload.EndsAt != nil && !payload.EndsAt.After(time.Now()) {
+		response.WriteError(w, response.Validation("Goal end date must be in the future."))
+		return
+	}
+
+	widget, err := s.widgets.ResetGoal(ctx, streamerId, chi.URLParam(r, "id"), storage.GoalReset{
+		AmountGoal: payload.AmountGoal,
+		StartsAt:   payload.StartsAt,
+		EndsAt:     payload.EndsAt,
+	})
+	writeWidget(w, widget, err)
+}
+
+// ArchiveGoalHandler saves current goal round into history and hides the goal from widgets list.
+func (s *Service) ArchiveGoalHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
+
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
 		return
 	}
 
-	response, _ := json.Marshal(&CreateWidgetResponse{&CreateWidgetResponseModel{fmt.Sprint(result.InsertedID)}, ""})
+	widget, err := s.widgets.ArchiveGoal(ctx, streamerId, chi.URLParam(r, "id"))
+	writeWidget(w, widget, err)
+}
+
+func (s *Service) GetGoalHistoryHandler(w http.ResponseWriter, r *http.Request) {
+	ctx := r.Context()
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	streamerId := auth.StreamerId(r.Context())
+	if streamerId == "" {
+		response.WriteError(w, errUnauthorized)
+		return
+	}
+
+	widget, err := s.widgets.GetWidget(ctx, streamerId, chi.URLParam(r, "id"))
+	if err != nil || widget == nil || widget.Type != widgets.TypeDonationGoal {
+		writeWidget(w, nil, err)
+		return
+	}
+
+	history := widget.History
+	if history == nil {
+		history = make([]storage.GoalRecord, 0)
+	}
+	response.WriteJSON(w, http.StatusOK, &history)
 }
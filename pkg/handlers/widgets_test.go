@@ -0,0 +1,212 @@
+package handlers
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/vladtenlive/ton-donate/pkg/ton"
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
+)
+
+// streamerRequest is a request authenticated as streamer-1.
+func streamerRequest(method string, target string, body io.Reader) *http.Request {
+	r := httptest.NewRequest(method, target, body)
+	return r.WithContext(auth.WithPrincipal(r.Context(), &auth.Principal{StreamerId: "streamer-1"}))
+}
+
+func TestCreateWidgetHandlerCompletion(t *testing.T) {
+	tests := []struct {
+		name      string
+		body      string
+		completed bool
+	}{
+		{
+			name: "goal in progress",
+			body: `{"type": "donation_goal", "title": "goal", "amount_goal": 10, "amount_current": 9}`,
+		},
+		{
+			name:      "goal reached already",
+			body:      `{"type": "donation_goal", "title": "goal", "amount_goal": 10, "amount_current": 10}`,
+			completed: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, memory := newTestService(t, testnetWallet(1))
+
+			w := httptest.NewRecorder()
+			s.CreateWidgetHandler(w, streamerRequest(http.MethodPost, "/widgets", strings.NewReader(test.body)))
+			if w.Code != http.StatusCreated {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusCreated, w.Code, w.Body)
+			}
+
+			var created struct {
+				Data CreateWidgetResponseModel `json:"data"`
+			}
+			if err := json.NewDecoder(w.Body).Decode(&created); err != nil {
+				t.Fatal(err)
+			}
+
+			widget, err := memory.GetWidget(context.Background(), "streamer-1", created.Data.WidgetId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if widget.IsCompleted != test.completed || (widget.CompletedAt != nil) != test.completed {
+				t.Fatalf("expected completed %t, got %+v", test.completed, widget)
+			}
+		})
+	}
+}
+
+// withURLParam sets a route parameter the way chi does for matched routes.
+func withURLParam(r *http.Request, key string, value string) *http.Request {
+	routeContext := chi.NewRouteContext()
+	routeContext.URLParams.Add(key, value)
+	return r.WithContext(context.WithValue(r.Context(), chi.RouteCtxKey, routeContext))
+}
+
+func TestResetGoalHandlerBody(t *testing.T) {
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		goal   uint64
+	}{
+		{
+			name:   "no body",
+			status: http.StatusOK,
+			goal:   10,
+		},
+		{
+			name:   "chunked body",
+			body:   `{"amount_goal": 20}`,
+			status: http.StatusOK,
+			goal:   20,
+		},
+		{
+			name:   "chunked invalid body",
+			body:   `{"amount_goal": 0}`,
+			status: http.StatusBadRequest,
+			goal:   10,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			s, memory := newTestService(t, testnetWallet(1))
+			created, err := memory.CreateWidget(context.Background(), storage.Widget{
+				StreamerId: "streamer-1",
+				Type:       widgets.TypeDonationGoal,
+				AmountGoal: 10,
+				IsActive:   true,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			r := streamerRequest(http.MethodPost, "/widgets/"+created.Id.Hex()+"/reset", strings.NewReader(test.body))
+			// Chunked bodies come without Content-Length.
+			r.ContentLength = -1
+
+			w := httptest.NewRecorder()
+			s.ResetGoalHandler(w, withURLParam(r, "id", created.Id.Hex()))
+			if w.Code != test.status {
+				t.Fatalf("expected status %d, got %d: %s", test.status, w.Code, w.Body)
+			}
+
+			widget, err := memory.GetWidget(context.Background(), "streamer-1", created.Id.Hex())
+			if err != nil {
+				t.Fatal(err)
+			}
+			if widget.AmountGoal != test.goal {
+				t.Fatalf("expected goal %d, got %d", test.goal, widget.AmountGoal)
+			}
+		})
+	}
+}
+
+func TestUpdateWidgetHandlerGoalCompleted(t *testing.T) {
+	ctx := context.Background()
+
+	var mu sync.Mutex
+	var events []ton.GoalCompletedRequest
+	widgetServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var event ton.GoalCompletedRequest
+		json.NewDecoder(r.Body).Decode(&event)
+
+		mu.Lock()
+		events = append(events, event)
+		mu.Unlock()
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer widgetServer.Close()
+
+	_, memory := newTestService(t, testnetWallet(1))
+	s := NewService(http.DefaultClient, memory.Repositories(), "", nil, nil, nil, ton.NewNotifier(widgetServer.Client(), widgetServer.URL))
+
+	created, err := memory.CreateWidget(ctx, storage.Widget{
+		StreamerId:    "streamer-1",
+		Type:          widgets.TypeDonationGoal,
+		Title:         "goal",
+		AmountGoal:    10,
+		AmountCurrent: 6,
+		IsActive:      true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	widgetId := created.Id.Hex()
+
+	// Steps run in order against the same goal.
+	tests := []struct {
+		name      string
+		body      string
+		completed bool
+		events    int
+	}{
+		{name: "goal still ahead", body: `{"amount_goal": 8}`, events: 0},
+		{name: "goal lowered to the current amount", body: `{"amount_goal": 6}`, completed: true, events: 1},
+		{name: "goal lowered further", body: `{"amount_goal": 5}`, completed: true, events: 1},
+		{name: "goal raised", body: `{"amount_goal": 20}`, events: 1},
+		{name: "title changed", body: `{"title": "new goal"}`, events: 1},
+		{name: "goal lowered again", body: `{"amount_goal": 6}`, completed: true, events: 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			r := streamerRequest(http.MethodPatch, "/widgets/"+widgetId, strings.NewReader(test.body))
+			w := httptest.NewRecorder()
+			s.UpdateWidgetHandler(w, withURLParam(r, "id", widgetId))
+			if w.Code != http.StatusOK {
+				t.Fatalf("expected status %d, got %d: %s", http.StatusOK, w.Code, w.Body)
+			}
+
+			widget, err := memory.GetWidget(ctx, "streamer-1", widgetId)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if widget.IsCompleted != test.completed {
+				t.Fatalf("expected completed %t, got %+v", test.completed, widget)
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			if len(events) != test.events {
+				t.Fatalf("expected %d goal_completed events, got %+v", test.events, events)
+			}
+			if last := len(events) - 1; last >= 0 && (events[last].Event != ton.GoalCompletedEvent || events[last].WidgetId != widgetId) {
+				t.Fatalf("expected goal_completed of %s, got %+v", widgetId, events[last])
+			}
+		})
+	}
+}
@@ -0,0 +1,53 @@
+package middlewares
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+)
+
+const realm = "ton-donate"
+
+// RequireAuth rejects requests without a valid bearer token with 401 and puts the principal into request context.
+func RequireAuth(authenticator auth.Authenticator) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			token, err := auth.BearerToken(r.Header.Get("Authorization"))
+			if err == nil {
+				var principal *auth.Principal
+				principal, err = authenticator.Authenticate(r.Context(), token)
+				if err == nil {
+					next.ServeHTTP(w, r.WithContext(auth.WithPrincipal(r.Context(), principal)))
+					return
+				}
+			}
+
+			writeUnauthorized(w, err)
+		})
+	}
+}
+
+// writeUnauthorized sets the RFC 6750 challenge, requests without token get no error attribute.
+func writeUnauthorized(w http.ResponseWriter, err error) {
+	challenge := `Bearer realm="` + realm + `"`
+	message := "Authorization token is missing."
+
+	var invalid *auth.InvalidTokenError
+	if errors.As(err, &invalid) {
+		challenge += `, error="invalid_token", error_description="` + strings.ReplaceAll(invalid.Reason, `"`, `'`) + `"`
+		message = "Authorization token is invalid: " + invalid.Reason + "."
+	} else if errors.Is(err, auth.ErrKeysUnavailable) {
+		w.Header().Set("Retry-After", "30")
+		response.WriteError(w, response.Unavailable("Authorization keys are not loaded yet, retry later.", err))
+		return
+	} else if !errors.Is(err, auth.ErrNoToken) {
+		response.WriteError(w, response.Internal("Failed to verify authorization token.", err))
+		return
+	}
+
+	w.Header().Set("WWW-Authenticate", challenge)
+	response.WriteError(w, response.Unauthorized(message))
+}
@@ -0,0 +1,111 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/response"
+	"github.com/vladtenlive/ton-donate/pkg/roles"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+// RejectDisabled rejects principals whose streamer account was disabled by an admin, mount it after RequireAuth.
+func RejectDisabled(streamers storage.StreamerRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			streamer, err := streamers.GetStreamerByStreamerId(r.Context(), auth.StreamerId(r.Context()))
+			if err != nil {
+				response.WriteError(w, response.Internal("Failed to load streamer.", err))
+				return
+			} else if streamer != nil && streamer.Disabled {
+				response.WriteError(w, response.Forbidden("Account is disabled: "+streamer.DisabledReason))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireAdmin lets through platform admins, by identity provider group or by stored grant.
+func RequireAdmin(grants storage.GrantRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			admin, err := IsAdmin(r.Context(), grants)
+			if err != nil {
+				response.WriteError(w, response.Internal("Failed to load account roles.", err))
+				return
+			} else if !admin {
+				response.WriteError(w, response.Forbidden("Only platform admins can do this."))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// RequireModerator lets through the streamer of {streamerId} route parameter, its moderators and admins.
+func RequireModerator(grants storage.GrantRepository) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal := auth.PrincipalFrom(r.Context())
+			if principal == nil {
+				response.WriteError(w, response.Unauthorized("Authorization token is missing or invalid."))
+				return
+			}
+
+			streamerId := chi.URLParam(r, "streamerId")
+			if principal.StreamerId == streamerId || principal.HasRole(roles.Admin) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			granted, err := hasGrant(r.Context(), grants, func(grant storage.Grant) bool {
+				return grant.Role == roles.Admin || (grant.Role == roles.Moderator && grant.StreamerId == streamerId)
+			})
+			if err != nil {
+				response.WriteError(w, response.Internal("Failed to load account roles.", err))
+				return
+			} else if !granted {
+				response.WriteError(w, response.Forbidden("You are not a moderator of this streamer."))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+// IsAdmin tells whether the authenticated principal is a platform admin.
+func IsAdmin(ctx context.Context, grants storage.GrantRepository) (bool, error) {
+	principal := auth.PrincipalFrom(ctx)
+	if principal == nil {
+		return false, nil
+	} else if principal.HasRole(roles.Admin) {
+		return true, nil
+	}
+
+	return hasGrant(ctx, grants, func(grant storage.Grant) bool { return grant.Role == roles.Admin })
+}
+
+func hasGrant(ctx context.Context, grants storage.GrantRepository, match func(grant storage.Grant) bool) (bool, error) {
+	streamerId := auth.StreamerId(ctx)
+	if streamerId == "" {
+		return false, nil
+	}
+
+	granted, err := grants.GetGranteeGrants(ctx, streamerId)
+	if err != nil {
+		return false, err
+	}
+
+	for _, grant := range granted {
+		if match(grant) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
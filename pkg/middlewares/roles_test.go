@@ -0,0 +1,95 @@
+package middlewares
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/go-chi/chi/v5"
+	"github.com/vladtenlive/ton-donate/pkg/auth"
+	"github.com/vladtenlive/ton-donate/pkg/roles"
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+)
+
+func newTestGrants(t *testing.T) storage.GrantRepository {
+	t.Helper()
+
+	grants := storage.NewMemoryStorage()
+	for _, grant := range []storage.Grant{
+		{Role: roles.Moderator, StreamerId: "streamer-1", GranteeId: "moderator"},
+		{Role: roles.Moderator, StreamerId: "streamer-2", GranteeId: "other-moderator"},
+		{Role: roles.Admin, GranteeId: "granted-admin"},
+	} {
+		if err := grants.SaveGrant(context.Background(), grant); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return grants
+}
+
+// serve runs the middleware for a request to streamer-1 made by principal, nil for anonymous requests.
+func serve(middleware func(http.Handler) http.Handler, principal *auth.Principal) int {
+	r := chi.NewRouter()
+	r.With(middleware).Get("/streamers/{streamerId}", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNoContent)
+	})
+
+	req := httptest.NewRequest(http.MethodGet, "/streamers/streamer-1", nil)
+	if principal != nil {
+		req = req.WithContext(auth.WithPrincipal(req.Context(), principal))
+	}
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w.Code
+}
+
+func TestRequireModerator(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		status    int
+	}{
+		{"anonymous", nil, http.StatusUnauthorized},
+		{"streamer itself", &auth.Principal{StreamerId: "streamer-1"}, http.StatusNoContent},
+		{"moderator by grant", &auth.Principal{StreamerId: "moderator"}, http.StatusNoContent},
+		{"moderator of another streamer", &auth.Principal{StreamerId: "other-moderator"}, http.StatusForbidden},
+		{"another streamer", &auth.Principal{StreamerId: "streamer-2"}, http.StatusForbidden},
+		{"admin by claim", &auth.Principal{StreamerId: "claimed-admin", Roles: []string{roles.Admin}}, http.StatusNoContent},
+		{"admin by grant", &auth.Principal{StreamerId: "granted-admin"}, http.StatusNoContent},
+		{"moderator claim is not enough", &auth.Principal{StreamerId: "claimed-moderator", Roles: []string{roles.Moderator}}, http.StatusForbidden},
+	}
+
+	middleware := RequireModerator(newTestGrants(t))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := serve(middleware, test.principal); status != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, status)
+			}
+		})
+	}
+}
+
+func TestRequireAdmin(t *testing.T) {
+	tests := []struct {
+		name      string
+		principal *auth.Principal
+		status    int
+	}{
+		{"anonymous", nil, http.StatusForbidden},
+		{"streamer", &auth.Principal{StreamerId: "streamer-1"}, http.StatusForbidden},
+		{"moderator by grant", &auth.Principal{StreamerId: "moderator"}, http.StatusForbidden},
+		{"admin by claim", &auth.Principal{StreamerId: "claimed-admin", Roles: []string{roles.Admin}}, http.StatusNoContent},
+		{"admin by grant", &auth.Principal{StreamerId: "granted-admin"}, http.StatusNoContent},
+	}
+
+	middleware := RequireAdmin(newTestGrants(t))
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if status := serve(middleware, test.principal); status != test.status {
+				t.Fatalf("expected status %d, got %d", test.status, status)
+			}
+		})
+	}
+}
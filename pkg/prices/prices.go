@@ -0,0 +1,260 @@
+package prices
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrRateNotFound = errors.New("rate not found")
+
+// Provider returns price of 1 TON in fiat currencies, keyed by upper case currency code.
+type Provider interface {
+	Rates(ctx context.Context, currencies []string) (map[string]float64, error)
+}
+
+// FiatCurrencies returns currencies configured in FIAT_CURRENCIES, USD by default.
+func FiatCurrencies() []string {
+	value := os.Getenv("FIAT_CURRENCIES")
+	if value == "" {
+		return []string{"USD"}
+	}
+
+	var currencies []string
+	for _, currency := range strings.Split(value, ",") {
+		if currency = strings.ToUpper(strings.TrimSpace(currency)); currency != "" {
+			currencies = append(currencies, currency)
+		}
+	}
+
+	return currencies
+}
+
+// IsFiatCurrency checks if currency is one of configured fiat currencies.
+func IsFiatCurrency(currency string) bool {
+	for _, fiat := range FiatCurrencies() {
+		if fiat == currency {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ToFiat converts nanoTON amount to fiat amount rounded to cents.
+func ToFiat(nanoTon uint64, rate float64) float64 {
+	return math.Round(float64(nanoTon)/1e9*rate*100) / 100
+}
+
+// ToFiatCents converts nanoTON amount to fiat cents, goals denominated in fiat are kept in cents.
+func ToFiatCents(nanoTon uint64, rate float64) uint64 {
+	return uint64(math.Round(float64(nanoTon) / 1e9 * rate * 100))
+}
+
+// FiatAmounts converts nanoTON amount to each fiat currency there is a rate for, nil without rates.
+func FiatAmounts(nanoTon uint64, rates map[string]float64) map[string]float64 {
+	if len(rates) == 0 {
+		return nil
+	}
+
+	amounts := make(map[string]float64, len(rates))
+	for currency, rate := range rates {
+		amounts[currency] = ToFiat(nanoTon, rate)
+	}
+
+	return amounts
+}
+
+// FiatCents converts nanoTON amount to cents of each fiat currency there is a rate for.
+func FiatCents(nanoTon uint64, rates map[string]float64) map[string]uint64 {
+	cents := make(map[string]uint64, len(rates))
+	for currency, rate := range rates {
+		cents[currency] = ToFiatCents(nanoTon, rate)
+	}
+
+	return cents
+}
+
+// HTTPProvider loads rates from CoinGecko compatible simple price API and caches them for a while.
+type HTTPProvider struct {
+	client  *http.Client
+	baseUrl string
+	ttl     time.Duration
+
+	mu        sync.Mutex
+	rates     map[string]float64
+	fetchedAt time.Time
+
+	// fetchMu lets a single request fetch rates while others wait for its result.
+	fetchMu sync.Mutex
+}
+
+func NewHTTPProvider(client *http.Client, baseUrl string, ttl time.Duration) *HTTPProvider {
+	return &HTTPProvider{
+		client:  client,
+		baseUrl: strings.TrimRight(baseUrl, "/"),
+		ttl:     ttl,
+		rates:   map[string]float64{},
+	}
+}
+
+// Rates returns cached rates while they are fresh. The API is called without holding the lock,
+// so a slow price API does not block callers which are served from the cache. Rates of configured
+// and already cached currencies are fetched together, so that one currency does not evict another.
+func (p *HTTPProvider) Rates(ctx context.Context, currencies []string) (map[string]float64, error) {
+	if rates, ok := p.cached(currencies); ok {
+		return rates, nil
+	}
+
+	p.fetchMu.Lock()
+	defer p.fetchMu.Unlock()
+
+	// Another request could have fetched them while we waited.
+	if rates, ok := p.cached(currencies); ok {
+		return rates, nil
+	}
+
+	p.mu.Lock()
+	wanted := append(FiatCurrencies(), currencies...)
+	for currency := range p.rates {
+		wanted = append(wanted, currency)
+	}
+	p.mu.Unlock()
+
+	fetched, err := p.fetch(ctx, unique(wanted))
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	rates := make(map[string]float64, len(p.rates)+len(fetched))
+	for currency, rate := range p.rates {
+		rates[currency] = rate
+	}
+	for currency, rate := range fetched {
+		rates[currency] = rate
+	}
+	p.rates, p.fetchedAt = rates, time.Now()
+
+	return pick(rates, currencies)
+}
+
+// cached returns the rates if they are all in the cache and it is fresh.
+func (p *HTTPProvider) cached(currencies []string) (map[string]float64, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if time.Since(p.fetchedAt) > p.ttl {
+		return nil, false
+	}
+
+	rates, err := pick(p.rates, currencies)
+	return rates, err == nil
+}
+
+func (p *HTTPProvider) fetch(ctx context.Context, currencies []string) (map[string]float64, error) {
+	url := fmt.Sprintf("%s/simple/price?ids=the-open-network&vs_currencies=%s", p.baseUrl, strings.ToLower(strings.Join(currencies, ",")))
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("price api responded with status %d", resp.StatusCode)
+	}
+
+	var body map[string]map[string]float64
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, err
+	}
+
+	rates := map[string]float64{}
+	for currency, rate := range body["the-open-network"] {
+		rates[strings.ToUpper(currency)] = rate
+	}
+
+	return rates, nil
+}
+
+// StaticProvider returns fixed rates, used in tests and local development.
+type StaticProvider struct {
+	rates map[string]float64
+}
+
+func NewStaticProvider(rates map[string]float64) *StaticProvider {
+	return &StaticProvider{rates: rates}
+}
+
+// LoadStaticProvider reads rates from JSON file like {"USD": 2.15, "EUR": 1.98}.
+func LoadStaticProvider(path string) (*StaticProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var rates map[string]float64
+	if err := json.Unmarshal(data, &rates); err != nil {
+		return nil, err
+	}
+
+	return NewStaticProvider(rates), nil
+}
+
+func (p *StaticProvider) Rates(ctx context.Context, currencies []string) (map[string]float64, error) {
+	return pick(p.rates, currencies)
+}
+
+func unique(currencies []string) []string {
+	seen := map[string]bool{}
+	var result []string
+	for _, currency := range currencies {
+		if !seen[currency] {
+			seen[currency] = true
+			result = append(result, currency)
+		}
+	}
+
+	return result
+}
+
+func pick(rates map[string]float64, currencies []string) (map[string]float64, error) {
+	result := make(map[string]float64, len(currencies))
+	for _, currency := range currencies {
+		rate, ok := rates[currency]
+		if !ok {
+			return nil, fmt.Errorf("%w: %s", ErrRateNotFound, currency)
+		}
+		result[currency] = rate
+	}
+
+	return result, nil
+}
+
+// NewProviderFromEnv creates provider set by PRICE_PROVIDER: "static" reads PRICE_FILE, otherwise PRICE_API_URL is used.
+func NewProviderFromEnv(client *http.Client) (Provider, error) {
+	if os.Getenv("PRICE_PROVIDER") == "static" {
+		return LoadStaticProvider(os.Getenv("PRICE_FILE"))
+	}
+
+	baseUrl := os.Getenv("PRICE_API_URL")
+	if baseUrl == "" {
+		baseUrl = "https://api.coingecko.com/api/v3"
+	}
+
+	return NewHTTPProvider(client, baseUrl, time.Minute), nil
+}
@@ -0,0 +1,123 @@
+package prices
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// priceServer serves fixed rates and records the currencies of each request.
+type priceServer struct {
+	*httptest.Server
+
+	mu       sync.Mutex
+	requests [][]string
+}
+
+func newPriceServer(t *testing.T, rates map[string]float64) *priceServer {
+	t.Helper()
+
+	s := &priceServer{}
+	s.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		currencies := strings.Split(r.URL.Query().Get("vs_currencies"), ",")
+		sort.Strings(currencies)
+
+		s.mu.Lock()
+		s.requests = append(s.requests, currencies)
+		s.mu.Unlock()
+
+		// Slow enough for concurrent callers to miss the cache together.
+		time.Sleep(10 * time.Millisecond)
+
+		served := map[string]float64{}
+		for _, currency := range currencies {
+			if rate, ok := rates[strings.ToUpper(currency)]; ok {
+				served[currency] = rate
+			}
+		}
+		json.NewEncoder(w).Encode(map[string]map[string]float64{"the-open-network": served})
+	}))
+	t.Cleanup(s.Close)
+
+	return s
+}
+
+func (s *priceServer) fetched() [][]string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([][]string{}, s.requests...)
+}
+
+func TestHTTPProviderRates(t *testing.T) {
+	t.Setenv("FIAT_CURRENCIES", "USD,EUR")
+	ctx := context.Background()
+	server := newPriceServer(t, map[string]float64{"USD": 2, "EUR": 1.8, "GBP": 1.5})
+	p := NewHTTPProvider(server.Client(), server.URL, time.Minute)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := p.Rates(ctx, []string{"USD"}); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	// Concurrent misses share one fetch of all configured currencies.
+	if fetched := server.fetched(); !reflect.DeepEqual(fetched, [][]string{{"eur", "usd"}}) {
+		t.Fatalf("expected one fetch of configured currencies, got %v", fetched)
+	}
+
+	rates, err := p.Rates(ctx, []string{"EUR"})
+	if err != nil || !reflect.DeepEqual(rates, map[string]float64{"EUR": 1.8}) {
+		t.Fatalf("expected cached EUR rate, got %v, %v", rates, err)
+	}
+
+	// A currency missing from the cache is fetched along with the cached ones, which are kept.
+	rates, err = p.Rates(ctx, []string{"GBP"})
+	if err != nil || !reflect.DeepEqual(rates, map[string]float64{"GBP": 1.5}) {
+		t.Fatalf("expected GBP rate, got %v, %v", rates, err)
+	}
+	rates, err = p.Rates(ctx, []string{"USD", "EUR", "GBP"})
+	if err != nil || len(rates) != 3 {
+		t.Fatalf("expected all rates cached, got %v, %v", rates, err)
+	}
+	if fetched := server.fetched(); len(fetched) != 2 || !reflect.DeepEqual(fetched[1], []string{"eur", "gbp", "usd"}) {
+		t.Fatalf("expected second fetch of all currencies, got %v", fetched)
+	}
+
+	// Rates the API does not have are reported as not found.
+	if _, err := p.Rates(ctx, []string{"JPY"}); err == nil {
+		t.Fatal("expected JPY rate not to be found")
+	}
+}
+
+func TestHTTPProviderRatesExpire(t *testing.T) {
+	t.Setenv("FIAT_CURRENCIES", "USD")
+	server := newPriceServer(t, map[string]float64{"USD": 2})
+	p := NewHTTPProvider(server.Client(), server.URL, time.Minute)
+
+	for i := 0; i < 2; i++ {
+		if _, err := p.Rates(context.Background(), []string{"USD"}); err != nil {
+			t.Fatal(err)
+		}
+
+		p.mu.Lock()
+		p.fetchedAt = time.Now().Add(-2 * time.Minute)
+		p.mu.Unlock()
+	}
+
+	if fetched := server.fetched(); len(fetched) != 2 {
+		t.Fatalf("expected expired rates to be fetched again, got %v", fetched)
+	}
+}
@@ -0,0 +1,85 @@
+package response
+
+import "net/http"
+
+// Code is a machine readable error code, clients should branch on it rather than on messages.
+type Code string
+
+const (
+	CodeValidation   Code = "validation_failed"
+	CodeUnauthorized Code = "unauthorized"
+	CodeForbidden    Code = "forbidden"
+	CodeNotFound     Code = "not_found"
+	CodeConflict     Code = "conflict"
+	CodeTooLarge     Code = "payload_too_large"
+	CodeUpstream     Code = "upstream_failed"
+	CodeUnavailable  Code = "service_unavailable"
+	CodeInternal     Code = "internal_error"
+)
+
+// Error is an API error with HTTP status, code and message safe to show to the client.
+// Cause is only logged, it may contain database or upstream details.
+type Error struct {
+	Status  int               `json:"-"`
+	Code    Code              `json:"code"`
+	Message string            `json:"message"`
+	Details map[string]string `json:"details,omitempty"`
+
+	cause error
+}
+
+func (e *Error) Error() string {
+	if e.cause != nil {
+		return e.Message + ": " + e.cause.Error()
+	}
+	return e.Message
+}
+
+func (e *Error) Unwrap() error {
+	return e.cause
+}
+
+// WithDetails returns a copy of the error with per field problems, keyed by field name.
+func (e *Error) WithDetails(details map[string]string) *Error {
+	detailed := *e
+	detailed.Details = details
+	return &detailed
+}
+
+func Validation(message string) *Error {
+	return &Error{Status: http.StatusBadRequest, Code: CodeValidation, Message: message}
+}
+
+func Unauthorized(message string) *Error {
+	return &Error{Status: http.StatusUnauthorized, Code: CodeUnauthorized, Message: message}
+}
+
+func Forbidden(message string) *Error {
+	return &Error{Status: http.StatusForbidden, Code: CodeForbidden, Message: message}
+}
+
+func NotFound(message string) *Error {
+	return &Error{Status: http.StatusNotFound, Code: CodeNotFound, Message: message}
+}
+
+func Conflict(message string) *Error {
+	return &Error{Status: http.StatusConflict, Code: CodeConflict, Message: message}
+}
+
+func TooLarge(message string) *Error {
+	return &Error{Status: http.StatusRequestEntityTooLarge, Code: CodeTooLarge, Message: message}
+}
+
+// Upstream is a failure of a third party service like Twitch.
+func Upstream(message string, cause error) *Error {
+	return &Error{Status: http.StatusBadGateway, Code: CodeUpstream, Message: message, cause: cause}
+}
+
+// Unavailable is a temporary failure, the request can be retried as is.
+func Unavailable(message string, cause error) *Error {
+	return &Error{Status: http.StatusServiceUnavailable, Code: CodeUnavailable, Message: message, cause: cause}
+}
+
+func Internal(message string, cause error) *Error {
+	return &Error{Status: http.StatusInternalServerError, Code: CodeInternal, Message: message, cause: cause}
+}
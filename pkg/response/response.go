@@ -0,0 +1,52 @@
+// Package response writes JSON API responses in a single envelope:
+// {"data": ..., "error": null} on success and {"data": null, "error": {"code", "message", "details"}} on failure.
+package response
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/labstack/gommon/log"
+)
+
+type envelope struct {
+	Data  any    `json:"data"`
+	Error *Error `json:"error"`
+}
+
+func WriteJSON(w http.ResponseWriter, status int, data any) {
+	write(w, status, envelope{Data: data})
+}
+
+// WriteError writes err as API error, errors which are not *Error are reported as internal.
+func WriteError(w http.ResponseWriter, err error) {
+	WriteErrorData(w, err, nil)
+}
+
+// WriteErrorData writes API error together with data, like the resource a request conflicted with.
+func WriteErrorData(w http.ResponseWriter, err error, data any) {
+	var apiErr *Error
+	if !errors.As(err, &apiErr) {
+		apiErr = Internal("Internal server error.", err)
+	}
+
+	if apiErr.Status >= http.StatusInternalServerError {
+		log.Error(apiErr)
+	}
+
+	write(w, apiErr.Status, envelope{Data: data, Error: apiErr})
+}
+
+func write(w http.ResponseWriter, status int, body envelope) {
+	response, err := json.Marshal(&body)
+	if err != nil {
+		log.Error(err)
+		status = http.StatusInternalServerError
+		response, _ = json.Marshal(&envelope{Error: Internal("Failed to encode response.", nil)})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(response)
+}
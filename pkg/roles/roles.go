@@ -0,0 +1,14 @@
+// Package roles names what an account may do beyond managing its own streamer profile.
+package roles
+
+const (
+	// Streamer is every authenticated account, over its own data only.
+	Streamer = "streamer"
+	// Moderator is delegated by a streamer and acts on that streamer's moderation queue.
+	Moderator = "moderator"
+	// Admin is a platform operator, over every streamer.
+	Admin = "admin"
+)
+
+// Grantable roles are stored as grants, admins may also come from identity provider groups.
+var Grantable = []string{Moderator, Admin}
@@ -0,0 +1,161 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Analytics bucket sizes, same as $dateTrunc units.
+const (
+	IntervalHour = "hour"
+	IntervalDay  = "day"
+	IntervalWeek = "week"
+)
+
+// Donation statuses analytics can be filtered by.
+const (
+	DonationStatusPending  = "pending"
+	DonationStatusVerified = "verified"
+	DonationStatusAcked    = "acked"
+)
+
+type AnalyticsQuery struct {
+	StreamerId string
+	Interval   string
+	Timezone   string
+	Currency   string
+	// Empty status means all verified donations.
+	Status    string
+	SessionId string
+	From      *time.Time
+	To        *time.Time
+}
+
+type AnalyticsStats struct {
+	Count        uint64 `json:"count" bson:"count"`
+	Sum          uint64 `json:"sum" bson:"sum"`
+	Average      uint64 `json:"average" bson:"-"`
+	UniqueDonors uint64 `json:"unique_donors" bson:"unique_donors"`
+}
+
+type AnalyticsBucket struct {
+	Start          time.Time `json:"start" bson:"_id"`
+	AnalyticsStats `bson:",inline"`
+}
+
+type Analytics struct {
+	Summary AnalyticsStats    `json:"summary" bson:"summary"`
+	Buckets []AnalyticsBucket `json:"buckets" bson:"buckets"`
+}
+
+// GetAnalytics aggregates streamer donations into time buckets in the streamer timezone.
+func (m *MongoStorage) GetAnalytics(ctx context.Context, query AnalyticsQuery) (*Analytics, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
+
+	match := bson.D{{Key: "streamer_id", Value: query.StreamerId}}
+	switch query.Status {
+	case DonationStatusPending:
+		match = append(match, bson.E{Key: "verified", Value: bson.D{{Key: "$ne", Value: true}}})
+	case DonationStatusVerified:
+		match = append(match,
+			bson.E{Key: "verified", Value: true},
+			bson.E{Key: "acked", Value: bson.D{{Key: "$ne", Value: true}}})
+	case DonationStatusAcked:
+		match = append(match, bson.E{Key: "acked", Value: true})
+	default:
+		match = append(match, bson.E{Key: "verified", Value: true})
+	}
+
+	if query.SessionId != "" {
+		match = append(match, bson.E{Key: "session_id", Value: query.SessionId})
+	}
+
+	// Donations saved before currencies were introduced are in TON.
+	currencies := bson.A{query.Currency}
+	if query.Currency == CurrencyTON {
+		currencies = append(currencies, nil)
+	}
+	match = append(match, bson.E{Key: "currency", Value: bson.D{{Key: "$in", Value: currencies}}})
+
+	createdAt := bson.D{}
+	if query.From != nil {
+		createdAt = append(createdAt, bson.E{Key: "$gte", Value: *query.From})
+	}
+	if query.To != nil {
+		createdAt = append(createdAt, bson.E{Key: "$lt", Value: *query.To})
+	}
+	if len(createdAt) > 0 {
+		match = append(match, bson.E{Key: "created_at", Value: createdAt})
+	}
+
+	// Donations without sender have it stored as empty string, like in Postgres nullif(sender_address, '').
+	sender := bson.D{{Key: "$ifNull", Value: bson.A{"$sender_address", ""}}}
+	donorKey := bson.D{{Key: "$cond", Value: bson.A{
+		bson.D{{Key: "$eq", Value: bson.A{sender, ""}}},
+		"$nickname",
+		sender,
+	}}}
+	stats := func(id any) bson.D {
+		return bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: id},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}},
+			{Key: "sum", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+			{Key: "donors", Value: bson.D{{Key: "$addToSet", Value: donorKey}}}}}}
+	}
+	uniqueDonors := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "unique_donors", Value: bson.D{{Key: "$size", Value: "$donors"}}}}}}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: match}},
+		bson.D{{Key: "$facet", Value: bson.D{
+			{Key: "buckets", Value: bson.A{
+				stats(bson.D{{Key: "$dateTrunc", Value: bson.D{
+					{Key: "date", Value: "$created_at"},
+					{Key: "unit", Value: query.Interval},
+					{Key: "timezone", Value: query.Timezone},
+					{Key: "startOfWeek", Value: "monday"}}}}),
+				uniqueDonors,
+				bson.D{{Key: "$sort", Value: bson.D{{Key: "_id", Value: 1}}}}}},
+			{Key: "summary", Value: bson.A{
+				stats(nil),
+				uniqueDonors}}}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "buckets", Value: 1},
+			{Key: "summary", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+				bson.D{{Key: "$arrayElemAt", Value: bson.A{"$summary", 0}}}, bson.D{}}}}}}}},
+	}
+
+	iter, err := m.client.Database(dbName).Collection(collectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Analytics
+	if err := iter.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	result := Analytics{Buckets: []AnalyticsBucket{}}
+	if len(results) > 0 {
+		result = results[0]
+	}
+
+	result.Summary.Average = average(result.Summary)
+	for i := range result.Buckets {
+		result.Buckets[i].Average = average(result.Buckets[i].AnalyticsStats)
+	}
+
+	return &result, nil
+}
+
+func average(stats AnalyticsStats) uint64 {
+	if stats.Count == 0 {
+		return 0
+	}
+
+	return stats.Sum / stats.Count
+}
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTruncateDate(t *testing.T) {
+	tokyo, err := time.LoadLocation("Asia/Tokyo")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Wednesday 2026-01-07 23:30 in Tokyo.
+	at := time.Date(2026, 1, 7, 14, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		interval string
+		location *time.Location
+		start    time.Time
+	}{
+		{interval: IntervalHour, location: time.UTC, start: time.Date(2026, 1, 7, 14, 0, 0, 0, time.UTC)},
+		{interval: IntervalDay, location: time.UTC, start: time.Date(2026, 1, 7, 0, 0, 0, 0, time.UTC)},
+		{interval: IntervalDay, location: tokyo, start: time.Date(2026, 1, 6, 15, 0, 0, 0, time.UTC)},
+		{interval: IntervalWeek, location: time.UTC, start: time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{interval: IntervalWeek, location: tokyo, start: time.Date(2026, 1, 4, 15, 0, 0, 0, time.UTC)},
+	}
+
+	for _, test := range tests {
+		t.Run(test.interval+" in "+test.location.String(), func(t *testing.T) {
+			if start := truncateDate(at, test.interval, test.location); !start.Equal(test.start) {
+				t.Fatalf("expected %v, got %v", test.start, start)
+			}
+		})
+	}
+
+	// Weeks start on monday, sunday belongs to the week before.
+	sunday := time.Date(2026, 1, 11, 12, 0, 0, 0, time.UTC)
+	if start := truncateDate(sunday, IntervalWeek, time.UTC); !start.Equal(time.Date(2026, 1, 5, 0, 0, 0, 0, time.UTC)) {
+		t.Fatalf("expected sunday in the week of monday 5th, got %v", start)
+	}
+}
+
+func TestMemoryGetAnalytics(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage()
+
+	day := func(d int, hour int) time.Time { return time.Date(2026, 1, d, hour, 0, 0, 0, time.UTC) }
+	donations := []Donation{
+		{TxHash: "hash-1", From: "alice", SenderAddress: "wallet-a", Amount: 4, Verified: true, CreatedAt: day(5, 10)},
+		{TxHash: "hash-2", From: "bob", SenderAddress: "wallet-a", Amount: 6, Verified: true, CreatedAt: day(5, 12)},
+		// Without sender donors are told apart by nickname, without both they are not counted.
+		{TxHash: "hash-3", From: "carol", Amount: 2, Verified: true, CreatedAt: day(6, 9)},
+		{TxHash: "hash-4", Amount: 3, Verified: true, CreatedAt: day(6, 10)},
+		{TxHash: "hash-5", Amount: 5, Verified: true, CreatedAt: day(6, 11)},
+		// Hidden donations are still income.
+		{TxHash: "hash-6", From: "dave", SenderAddress: "wallet-d", Amount: 10, Verified: true, Acked: true, CreatedAt: day(7, 8),
+			Moderation: &DonationModeration{Status: ModerationHidden}},
+		{TxHash: "hash-7", From: "erin", SenderAddress: "wallet-e", Amount: 500, Currency: "USD", Verified: true, CreatedAt: day(7, 9)},
+		{TxHash: "hash-8", From: "frank", SenderAddress: "wallet-f", Amount: 100, CreatedAt: day(7, 10)},
+	}
+	for _, donation := range donations {
+		donation.StreamerId = "streamer-1"
+		if err := memory.CreateDonation(ctx, donation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	from := day(6, 0)
+	to := day(7, 0)
+	later := day(8, 0)
+	tests := []struct {
+		name    string
+		query   AnalyticsQuery
+		summary AnalyticsStats
+		buckets []AnalyticsBucket
+	}{
+		{
+			name:    "daily",
+			query:   AnalyticsQuery{Interval: IntervalDay, Currency: CurrencyTON},
+			summary: AnalyticsStats{Count: 6, Sum: 30, Average: 5, UniqueDonors: 3},
+			buckets: []AnalyticsBucket{
+				{Start: day(5, 0), AnalyticsStats: AnalyticsStats{Count: 2, Sum: 10, Average: 5, UniqueDonors: 1}},
+				{Start: day(6, 0), AnalyticsStats: AnalyticsStats{Count: 3, Sum: 10, Average: 3, UniqueDonors: 1}},
+				{Start: day(7, 0), AnalyticsStats: AnalyticsStats{Count: 1, Sum: 10, Average: 10, UniqueDonors: 1}},
+			},
+		},
+		{
+			name:    "weekly",
+			query:   AnalyticsQuery{Interval: IntervalWeek, Currency: CurrencyTON},
+			summary: AnalyticsStats{Count: 6, Sum: 30, Average: 5, UniqueDonors: 3},
+			buckets: []AnalyticsBucket{
+				{Start: day(5, 0), AnalyticsStats: AnalyticsStats{Count: 6, Sum: 30, Average: 5, UniqueDonors: 3}},
+			},
+		},
+		{
+			name:    "currency",
+			query:   AnalyticsQuery{Interval: IntervalDay, Currency: "USD"},
+			summary: AnalyticsStats{Count: 1, Sum: 500, Average: 500, UniqueDonors: 1},
+			buckets: []AnalyticsBucket{
+				{Start: day(7, 0), AnalyticsStats: AnalyticsStats{Count: 1, Sum: 500, Average: 500, UniqueDonors: 1}},
+			},
+		},
+		{
+			name:    "pending",
+			query:   AnalyticsQuery{Interval: IntervalDay, Currency: CurrencyTON, Status: DonationStatusPending},
+			summary: AnalyticsStats{Count: 1, Sum: 100, Average: 100, UniqueDonors: 1},
+			buckets: []AnalyticsBucket{
+				{Start: day(7, 0), AnalyticsStats: AnalyticsStats{Count: 1, Sum: 100, Average: 100, UniqueDonors: 1}},
+			},
+		},
+		{
+			name:    "range",
+			query:   AnalyticsQuery{Interval: IntervalDay, Currency: CurrencyTON, From: &from, To: &to},
+			summary: AnalyticsStats{Count: 3, Sum: 10, Average: 3, UniqueDonors: 1},
+			buckets: []AnalyticsBucket{
+				{Start: day(6, 0), AnalyticsStats: AnalyticsStats{Count: 3, Sum: 10, Average: 3, UniqueDonors: 1}},
+			},
+		},
+		{
+			name:    "empty range",
+			query:   AnalyticsQuery{Interval: IntervalDay, Currency: CurrencyTON, From: &later},
+			buckets: []AnalyticsBucket{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.query.StreamerId = "streamer-1"
+			test.query.Timezone = "UTC"
+			analytics, err := memory.GetAnalytics(ctx, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if analytics.Summary != test.summary {
+				t.Fatalf("expected summary %+v, got %+v", test.summary, analytics.Summary)
+			}
+			if !reflect.DeepEqual(analytics.Buckets, test.buckets) {
+				t.Fatalf("expected buckets %+v, got %+v", test.buckets, analytics.Buckets)
+			}
+		})
+	}
+}
@@ -0,0 +1,478 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/vladtenlive/ton-donate/pkg/roles"
+	"github.com/vladtenlive/ton-donate/pkg/utils"
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// collectionSpec is the expected state of a collection: document schema and indexes.
+type collectionSpec struct {
+	name    string
+	schema  bson.M
+	indexes []mongo.IndexModel
+	// replace names indexes which are rebuilt when they drift. Only list indexes relaxed since an
+	// earlier release, rebuilding a stricter index could fail on existing data.
+	replace []string
+	// migrate brings documents of earlier releases to the schema, it must be a no-op when run again.
+	migrate func(ctx context.Context, collection *mongo.Collection) error
+}
+
+// IndexDrift is a difference between expected and actual indexes which bootstrap could not fix.
+type IndexDrift struct {
+	Collection string
+	Index      string
+	Problem    string
+}
+
+func (d IndexDrift) String() string {
+	return fmt.Sprintf("%s.%s: %s", d.Collection, d.Index, d.Problem)
+}
+
+// BootstrapReport lists index drift found during Bootstrap, empty when collections match expectations.
+type BootstrapReport struct {
+	Drift []IndexDrift
+}
+
+// Amounts are uint64 which the driver stores as int64, small values could be int32 after manual edits.
+var bsonInteger = bson.A{"int", "long"}
+
+// nonEmpty is a partial filter for unique indexes on optional string fields.
+func nonEmpty(field string) *options.IndexOptions {
+	return options.Index().SetUnique(true).SetPartialFilterExpression(bson.D{{Key: field, Value: bson.D{{Key: "$gt", Value: ""}}}})
+}
+
+func collectionSpecs() []collectionSpec {
+	return []collectionSpec{
+		{
+			name: os.Getenv("DB_STREAMERS_COLLECTION_NAME"),
+			schema: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"streamer_id"},
+				"properties": bson.M{
+					"streamer_id":    bson.M{"bsonType": "string"},
+					"cognito_id":     bson.M{"bsonType": "string"},
+					"wallet_address": bson.M{"bsonType": "string"},
+					"slug":           bson.M{"bsonType": "string"},
+					"disabled":       bson.M{"bsonType": "bool"},
+					"wallets": bson.M{
+						"bsonType": "array",
+						"items": bson.M{
+							"bsonType": "object",
+							"required": bson.A{"address", "is_active"},
+							"properties": bson.M{
+								"address":   bson.M{"bsonType": "string"},
+								"is_active": bson.M{"bsonType": "bool"},
+								"periods":   bson.M{"bsonType": "array"},
+							},
+						},
+					},
+				},
+			},
+			indexes: []mongo.IndexModel{
+				{Keys: bson.D{{Key: "streamer_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				{Keys: bson.D{{Key: "cognito_id", Value: 1}}},
+				{Keys: bson.D{{Key: "wallet_address", Value: 1}}, Options: nonEmpty("wallet_address")},
+				// Unique across streamers, a streamer can't list the same wallet twice anyway.
+				{Keys: bson.D{{Key: "wallets.address", Value: 1}}, Options: nonEmpty("wallets.address")},
+				{Keys: bson.D{{Key: "slug", Value: 1}}, Options: nonEmpty("slug")},
+				// EventSub notifications resolve the streamer by linked Twitch user.
+				{Keys: bson.D{{Key: "twitch.user_id", Value: 1}}, Options: options.Index().SetSparse(true)},
+			},
+			migrate: migrateWalletAddresses,
+		},
+		{
+			name: os.Getenv("DB_DONATIONS_COLLECTION_NAME"),
+			schema: bson.M{
+				"bsonType": "object",
+				"properties": bson.M{
+					"sign":         bson.M{"bsonType": "string"},
+					"duplicate_of": bson.M{"bsonType": "string"},
+					"tx_hash":      bson.M{"bsonType": "string"},
+					"streamer_id":  bson.M{"bsonType": "string"},
+					"amount":       bson.M{"bsonType": bsonInteger},
+					"lt":           bson.M{"bsonType": bsonInteger},
+					"currency":     bson.M{"bsonType": "string"},
+					"verified":     bson.M{"bsonType": "bool"},
+					"acked":        bson.M{"bsonType": "bool"},
+					"fiat_rates":   bson.M{"bsonType": "object"},
+					"moderation": bson.M{
+						"bsonType": "object",
+						"required": bson.A{"status"},
+						"properties": bson.M{
+							"status": bson.M{"enum": ModerationStatuses},
+						},
+					},
+					"created_at": bson.M{"bsonType": "date"},
+				},
+			},
+			indexes: []mongo.IndexModel{
+				// Transactions reusing a claimed sign are stored without sign, see SaveDonation.
+				{Keys: bson.D{{Key: "sign", Value: 1}}, Options: nonEmpty("sign")},
+				{Keys: bson.D{{Key: "tx_hash", Value: 1}}, Options: nonEmpty("tx_hash")},
+				// Analytics and leaderboards range over streamer donations by time.
+				{Keys: bson.D{{Key: "streamer_id", Value: 1}, {Key: "created_at", Value: 1}}},
+				{Keys: bson.D{{Key: "streamer_id", Value: 1}, {Key: "verified", Value: 1}, {Key: "created_at", Value: 1}}},
+				{Keys: bson.D{{Key: "streamer_id", Value: 1}, {Key: "session_id", Value: 1}}},
+				// Public page shows the latest verified donations.
+				{Keys: bson.D{{Key: "streamer_id", Value: 1}, {Key: "verified", Value: 1}, {Key: "lt", Value: -1}}},
+			},
+			// Sign index was unique over every document before duplicates were stored without sign.
+			replace: []string{"sign_1"},
+			migrate: migrateWalletAddresses,
+		},
+		{
+			name: os.Getenv("DB_WIDGETS_COLLECTION_NAME"),
+			schema: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"streamer_id", "type"},
+				"properties": bson.M{
+					"streamer_id":     bson.M{"bsonType": "string"},
+					"type":            bson.M{"enum": widgets.Types},
+					"amount_goal":     bson.M{"bsonType": bsonInteger},
+					"amount_current":  bson.M{"bsonType": bsonInteger},
+					"donations_count": bson.M{"bsonType": bsonInteger},
+					"is_active":       bson.M{"bsonType": "bool"},
+					"config":          bson.M{"bsonType": "object"},
+					"history":         bson.M{"bsonType": "array"},
+				},
+			},
+			indexes: []mongo.IndexModel{
+				{Keys: bson.D{{Key: "streamer_id", Value: 1}, {Key: "type", Value: 1}}},
+			},
+			migrate: migrateLegacyWidgets,
+		},
+		{
+			name: os.Getenv("DB_SESSIONS_COLLECTION_NAME"),
+			schema: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"streamer_id", "source", "started_at"},
+				"properties": bson.M{
+					"streamer_id": bson.M{"bsonType": "string"},
+					"source":      bson.M{"enum": bson.A{SessionSourceManual, SessionSourceTwitch}},
+					"started_at":  bson.M{"bsonType": "date"},
+					"ended_at":    bson.M{"bsonType": "date"},
+
+					"active_streamer_id": bson.M{"bsonType": "string"},
+				},
+			},
+			indexes: []mongo.IndexModel{
+				{Keys: bson.D{{Key: "streamer_id", Value: 1}, {Key: "started_at", Value: -1}}},
+				// At most one active session per streamer, see StreamSession.ActiveStreamerId.
+				{Keys: bson.D{{Key: "active_streamer_id", Value: 1}}, Options: nonEmpty("active_streamer_id")},
+			},
+		},
+		{
+			name: os.Getenv("DB_REFRESH_TOKENS_COLLECTION_NAME"),
+			schema: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"hash", "streamer_id", "expires_at"},
+				"properties": bson.M{
+					"hash":           bson.M{"bsonType": "string"},
+					"streamer_id":    bson.M{"bsonType": "string"},
+					"wallet_address": bson.M{"bsonType": "string"},
+					"created_at":     bson.M{"bsonType": "date"},
+					"expires_at":     bson.M{"bsonType": "date"},
+				},
+			},
+			indexes: []mongo.IndexModel{
+				{Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+				// Expired tokens are removed by Mongo.
+				{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+				{Keys: bson.D{{Key: "streamer_id", Value: 1}}},
+			},
+		},
+		{
+			name: os.Getenv("DB_NONCES_COLLECTION_NAME"),
+			schema: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"key", "expires_at"},
+				"properties": bson.M{
+					"key":        bson.M{"bsonType": "string"},
+					"expires_at": bson.M{"bsonType": "date"},
+				},
+			},
+			indexes: []mongo.IndexModel{
+				{Keys: bson.D{{Key: "key", Value: 1}}, Options: options.Index().SetUnique(true)},
+				// Expired nonces are removed by Mongo.
+				{Keys: bson.D{{Key: "expires_at", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+			},
+		},
+		{
+			name: os.Getenv("DB_GRANTS_COLLECTION_NAME"),
+			schema: bson.M{
+				"bsonType": "object",
+				"required": bson.A{"role", "streamer_id", "grantee_id"},
+				"properties": bson.M{
+					"role":        bson.M{"enum": roles.Grantable},
+					"streamer_id": bson.M{"bsonType": "string"},
+					"grantee_id":  bson.M{"bsonType": "string"},
+					"granted_by":  bson.M{"bsonType": "string"},
+					"created_at":  bson.M{"bsonType": "date"},
+				},
+			},
+			indexes: []mongo.IndexModel{
+				{Keys: bson.D{{Key: "role", Value: 1}, {Key: "streamer_id", Value: 1}, {Key: "grantee_id", Value: 1}}, Options: options.Index().SetUnique(true)},
+				// Every authorized request looks up roles of the caller.
+				{Keys: bson.D{{Key: "grantee_id", Value: 1}}},
+			},
+		},
+	}
+}
+
+// Bootstrap makes collections match expected state, running it again is a no-op.
+// Documents of earlier releases are migrated first. Collections get JSON schema validators,
+// which only apply to new and already valid documents, and missing indexes are created. Indexes which differ from expected or can't be created,
+// for example unique ones over duplicated data, are not touched and reported as drift,
+// unless the collection spec lists them to be replaced.
+func (m *MongoStorage) Bootstrap(ctx context.Context) (*BootstrapReport, error) {
+	db := m.client.Database(os.Getenv("DB_NAME"))
+
+	existing, err := db.ListCollectionNames(ctx, bson.D{})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &BootstrapReport{}
+	for _, spec := range collectionSpecs() {
+		if spec.migrate != nil {
+			if err := spec.migrate(ctx, db.Collection(spec.name)); err != nil {
+				return nil, fmt.Errorf("failed to migrate %s: %w", spec.name, err)
+			}
+		}
+
+		if err := ensureValidator(ctx, db, spec, contains(existing, spec.name)); err != nil {
+			return nil, fmt.Errorf("failed to set %s validator: %w", spec.name, err)
+		}
+
+		drift, err := ensureIndexes(ctx, db.Collection(spec.name), spec.indexes, spec.replace)
+		if err != nil {
+			return nil, fmt.Errorf("failed to check %s indexes: %w", spec.name, err)
+		}
+		report.Drift = append(report.Drift, drift...)
+	}
+
+	return report, nil
+}
+
+// migrateLegacyWidgets turns widgets created before widget types, which have free-form or no type,
+// into donation goals, so that they are still found and credited.
+func migrateLegacyWidgets(ctx context.Context, collection *mongo.Collection) error {
+	config, err := widgets.ParseConfig(widgets.TypeDonationGoal, nil)
+	if err != nil {
+		return err
+	}
+
+	legacy := bson.D{{Key: "type", Value: bson.D{{Key: "$nin", Value: widgets.Types}}}}
+	withoutConfig := append(bson.D{{Key: "config.donation_goal", Value: bson.D{{Key: "$exists", Value: false}}}}, legacy...)
+	if _, err := collection.UpdateMany(ctx, withoutConfig, bson.D{{Key: "$set", Value: bson.D{{Key: "config", Value: config}}}}); err != nil {
+		return err
+	}
+
+	_, err = collection.UpdateMany(ctx, legacy, bson.D{{Key: "$set", Value: bson.D{{Key: "type", Value: widgets.TypeDonationGoal}}}})
+	return err
+}
+
+// storedAddresses are the wallet address fields of streamers and donations.
+type storedAddresses struct {
+	Id            any    `bson:"_id"`
+	WalletAddress string `bson:"wallet_address"`
+	SenderAddress string `bson:"sender_address"`
+	Wallets       []struct {
+		Address string `bson:"address"`
+	} `bson:"wallets"`
+}
+
+// migrateWalletAddresses rewrites addresses stored before they were normalized to the canonical form,
+// so that lookups by normalized address find them. Addresses which do not parse are left as is.
+// Only documents with an address not in the canonical form are loaded, so that once migrated it reads nothing.
+// Postgres storage came after normalization, so its tables need no such migration.
+func migrateWalletAddresses(ctx context.Context, collection *mongo.Collection) error {
+	notCanonical := bson.D{
+		{Key: "$gt", Value: ""},
+		{Key: "$not", Value: primitive.Regex{Pattern: utils.CanonicalWalletAddressPattern()}}}
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "wallet_address", Value: notCanonical}},
+		bson.D{{Key: "sender_address", Value: notCanonical}},
+		bson.D{{Key: "wallets", Value: bson.D{{Key: "$elemMatch", Value: bson.D{{Key: "address", Value: notCanonical}}}}}}}}}
+
+	iter, err := collection.Find(ctx, filter, options.Find().SetProjection(bson.D{
+		{Key: "wallet_address", Value: 1},
+		{Key: "sender_address", Value: 1},
+		{Key: "wallets.address", Value: 1}}))
+	if err != nil {
+		return err
+	}
+	defer iter.Close(ctx)
+
+	for iter.Next(ctx) {
+		var stored storedAddresses
+		if err := iter.Decode(&stored); err != nil {
+			return err
+		}
+
+		set := bson.D{}
+		canonical := func(key string, walletAddress string) {
+			if formatted, err := utils.CanonicalWalletAddress(walletAddress); err == nil && formatted != walletAddress {
+				set = append(set, bson.E{Key: key, Value: formatted})
+			}
+		}
+		canonical("wallet_address", stored.WalletAddress)
+		canonical("sender_address", stored.SenderAddress)
+		for i, wallet := range stored.Wallets {
+			canonical(fmt.Sprintf("wallets.%d.address", i), wallet.Address)
+		}
+		if len(set) == 0 {
+			continue
+		}
+
+		_, err := collection.UpdateByID(ctx, stored.Id, bson.D{{Key: "$set", Value: set}})
+		if mongo.IsDuplicateKeyError(err) {
+			return fmt.Errorf("%v has an address registered by another streamer in another form, resolve it manually: %w", stored.Id, err)
+		} else if err != nil {
+			return err
+		}
+	}
+
+	return iter.Err()
+}
+
+func ensureValidator(ctx context.Context, db *mongo.Database, spec collectionSpec, exists bool) error {
+	validator := bson.M{"$jsonSchema": spec.schema}
+	if !exists {
+		opts := options.CreateCollection().
+			SetValidator(validator).
+			SetValidationLevel("moderate").
+			SetValidationAction("error")
+		return db.CreateCollection(ctx, spec.name, opts)
+	}
+
+	return db.RunCommand(ctx, bson.D{
+		{Key: "collMod", Value: spec.name},
+		{Key: "validator", Value: validator},
+		{Key: "validationLevel", Value: "moderate"},
+		{Key: "validationAction", Value: "error"},
+	}).Err()
+}
+
+type indexInfo struct {
+	Name          string   `bson:"name"`
+	Key           bson.D   `bson:"key"`
+	Unique        bool     `bson:"unique"`
+	Sparse        bool     `bson:"sparse"`
+	PartialFilter bson.Raw `bson:"partialFilterExpression"`
+	ExpireAfter   *int32   `bson:"expireAfterSeconds"`
+}
+
+func ensureIndexes(ctx context.Context, collection *mongo.Collection, expected []mongo.IndexModel, replace []string) ([]IndexDrift, error) {
+	iter, err := collection.Indexes().List(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var actual []indexInfo
+	if err := iter.All(ctx, &actual); err != nil {
+		return nil, err
+	}
+
+	actualByName := map[string]indexInfo{}
+	for _, index := range actual {
+		actualByName[index.Name] = index
+	}
+
+	var drift []IndexDrift
+	expectedNames := map[string]bool{"_id_": true}
+	for _, model := range expected {
+		name := indexName(model.Keys.(bson.D))
+		expectedNames[name] = true
+
+		unique, sparse := false, false
+		var partial bson.Raw
+		var expireAfter *int32
+		if model.Options != nil {
+			unique = model.Options.Unique != nil && *model.Options.Unique
+			sparse = model.Options.Sparse != nil && *model.Options.Sparse
+			expireAfter = model.Options.ExpireAfterSeconds
+			if model.Options.PartialFilterExpression != nil {
+				partial, err = bson.Marshal(model.Options.PartialFilterExpression)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+
+		if index, ok := actualByName[name]; ok {
+			problem := ""
+			if index.Unique != unique || index.Sparse != sparse {
+				problem = fmt.Sprintf("expected unique=%t sparse=%t, found unique=%t sparse=%t", unique, sparse, index.Unique, index.Sparse)
+			} else if !bytes.Equal(index.PartialFilter, partial) {
+				problem = fmt.Sprintf("expected partial filter %s, found %s", partial, index.PartialFilter)
+			} else if !equalSeconds(index.ExpireAfter, expireAfter) {
+				problem = fmt.Sprintf("expected expireAfterSeconds %s, found %s", formatSeconds(expireAfter), formatSeconds(index.ExpireAfter))
+			}
+
+			if problem == "" {
+				continue
+			} else if !contains(replace, name) {
+				drift = append(drift, IndexDrift{collection.Name(), name, problem})
+				continue
+			}
+
+			if _, err := collection.Indexes().DropOne(ctx, name); err != nil {
+				drift = append(drift, IndexDrift{collection.Name(), name, problem + ", failed to drop: " + err.Error()})
+				continue
+			}
+		}
+
+		if _, err := collection.Indexes().CreateOne(ctx, model); err != nil {
+			drift = append(drift, IndexDrift{collection.Name(), name, "missing, failed to create: " + err.Error()})
+		}
+	}
+
+	for _, index := range actual {
+		if !expectedNames[index.Name] {
+			drift = append(drift, IndexDrift{collection.Name(), index.Name, "unexpected index"})
+		}
+	}
+
+	return drift, nil
+}
+
+func equalSeconds(a, b *int32) bool {
+	return (a == nil && b == nil) || (a != nil && b != nil && *a == *b)
+}
+
+func formatSeconds(seconds *int32) string {
+	if seconds == nil {
+		return "none"
+	}
+	return fmt.Sprint(*seconds)
+}
+
+// indexName is the name Mongo gives to an index by default, like streamer_id_1_created_at_1.
+func indexName(keys bson.D) string {
+	parts := make([]string, 0, len(keys)*2)
+	for _, key := range keys {
+		parts = append(parts, key.Key, fmt.Sprint(key.Value))
+	}
+	return strings.Join(parts, "_")
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
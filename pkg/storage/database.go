@@ -38,3 +38,17 @@ func NewMongoClient(ctx context.Context) (*MongoStorage, error) {
 
 	return &MongoStorage{client: client}, nil
 }
+
+// Repositories returns MongoStorage as every repository.
+func (m *MongoStorage) Repositories() Repositories {
+	return Repositories{
+		Streamers: m,
+		Donations: m,
+		Widgets:   m,
+		Sessions:  m,
+
+		RefreshTokens: m,
+		Grants:        m,
+		Nonces:        m,
+	}
+}
@@ -11,17 +11,39 @@ import (
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+// Currency of donations paid in TON, amounts are in nanoTON.
+const CurrencyTON = "TON"
+
 type Tx struct {
 	Sign          string
 	TxHash        string
 	Message       string
 	WalletAddress string
+	SenderAddress string
+	Currency      string
 	Amount        uint64
 	Lt            uint64
 	Acked         bool
 	CreatedAt     time.Time
 }
 
+// Moderation statuses of donations.
+const (
+	ModerationApproved = "approved"
+	// Hidden donations are not shown on the public page.
+	ModerationHidden = "hidden"
+)
+
+var ModerationStatuses = []string{ModerationApproved, ModerationHidden}
+
+type DonationModeration struct {
+	Status string `json:"status" bson:"status"`
+	Reason string `json:"reason,omitempty" bson:"reason,omitempty"`
+	// Account which moderated the donation.
+	By string    `json:"by" bson:"by"`
+	At time.Time `json:"at" bson:"at"`
+}
+
 type Donation struct {
 	TxHash        string `json:"txHash,omitempty" bson:"tx_hash,omitempty"`
 	Sign          string `json:"sign,omitempty" bson:"sign,omitempty"`
@@ -34,7 +56,25 @@ type Donation struct {
 	Verified      bool   `json:"verified,omitempty" bson:"verified,omitempty"`
 	Acked         bool   `json:"acked,omitempty" bson:"acked,omitempty"`
 
-	// ToDo: Add createdAt/modifiedAt
+	SenderAddress string `json:"sender_address,omitempty" bson:"sender_address,omitempty"`
+	Currency      string `json:"currency,omitempty" bson:"currency,omitempty"`
+
+	// Price of 1 TON in fiat currencies at acknowledgement time.
+	FiatRates map[string]float64 `json:"fiat_rates,omitempty" bson:"fiat_rates,omitempty"`
+
+	// Stream session which was live when donation was acked.
+	SessionId string `json:"sessionId,omitempty" bson:"session_id,omitempty"`
+
+	// Goal widget the donation is made for, when empty all active goals are credited.
+	WidgetId string `json:"widgetId,omitempty" bson:"widget_id,omitempty"`
+
+	// Sign of the donation this transaction reused, such donations are stored without own sign.
+	DuplicateOf string `json:"duplicateOf,omitempty" bson:"duplicate_of,omitempty"`
+
+	// Set once the streamer, a moderator or an admin reviewed the donation message.
+	Moderation *DonationModeration `json:"moderation,omitempty" bson:"moderation,omitempty"`
+
+	CreatedAt time.Time `json:"created_at,omitempty" bson:"created_at,omitempty"`
 }
 
 func (m *MongoStorage) GetStreamerDonations(ctx context.Context, streamerId string) (*[]Donation, error) {
@@ -58,11 +98,41 @@ func (m *MongoStorage) GetStreamerDonations(ctx context.Context, streamerId stri
 	return &results, nil
 }
 
+// GetPublicDonations returns the latest verified donations of a streamer, newest first.
+func (m *MongoStorage) GetPublicDonations(ctx context.Context, streamerId string, limit int64) (*[]Donation, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
+
+	filter := bson.D{
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "verified", Value: true},
+		{Key: "moderation.status", Value: bson.D{{Key: "$ne", Value: ModerationHidden}}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "lt", Value: -1}}).SetLimit(limit)
+	iter, err := m.client.Database(dbName).Collection(collectionName).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []Donation
+	if err := iter.All(ctx, &results); err != nil {
+		return nil, errors.New("Failed to retrieve data!")
+	}
+
+	return &results, nil
+}
+
 func (m *MongoStorage) GetDonationBySign(ctx context.Context, sign string) (*Donation, error) {
+	return m.findDonation(ctx, bson.D{{Key: "sign", Value: sign}})
+}
+
+func (m *MongoStorage) GetDonationByTxHash(ctx context.Context, txHash string) (*Donation, error) {
+	return m.findDonation(ctx, bson.D{{Key: "tx_hash", Value: txHash}})
+}
+
+func (m *MongoStorage) findDonation(ctx context.Context, filter bson.D) (*Donation, error) {
 	dbName := os.Getenv("DB_NAME")
 	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
-	filter := bson.D{{Key: "sign", Value: sign}}
-	// opts := options.FindOneOptions().SetSort(bson.D{{}})
 
 	result := m.client.Database(dbName).Collection(collectionName).FindOne(ctx, filter)
 	if result.Err() == mongo.ErrNoDocuments {
@@ -79,55 +149,156 @@ func (m *MongoStorage) GetDonationBySign(ctx context.Context, sign string) (*Don
 	return &donation, nil
 }
 
-func (m *MongoStorage) CreateDonation(ctx context.Context, donation Donation) (*mongo.InsertOneResult, error) {
+func (m *MongoStorage) CreateDonation(ctx context.Context, donation Donation) error {
 	dbName := os.Getenv("DB_NAME")
 	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
 
-	result, err := m.client.Database(dbName).Collection(collectionName).InsertOne(ctx, donation)
-	if err != nil {
-		return nil, err
+	_, err := m.client.Database(dbName).Collection(collectionName).InsertOne(ctx, donation)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
 	}
-
-	return result, nil
+	return err
 }
 
-func (m *MongoStorage) SaveDonation(ctx context.Context, transaction Tx, streamerId string) (*mongo.UpdateResult, error) {
+func (m *MongoStorage) SaveDonation(ctx context.Context, transaction Tx, streamerId string) (*Donation, error) {
 	dbName := os.Getenv("DB_NAME")
 	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
+	collection := m.client.Database(dbName).Collection(collectionName)
 
-	opts := options.Update().SetUpsert(true)
-	filter := bson.D{{Key: "sign", Value: transaction.Sign}}
-	update := bson.D{{Key: "$set", Value: bson.D{
+	byTxHash := bson.D{{Key: "tx_hash", Value: transaction.TxHash}}
+	saved, err := m.findDonation(ctx, byTxHash)
+	if err != nil || saved != nil {
+		return saved, err
+	}
+
+	fields := bson.D{
 		{Key: "tx_hash", Value: transaction.TxHash},
 		{Key: "wallet_address", Value: transaction.WalletAddress},
 		{Key: "streamer_id", Value: streamerId},
 		{Key: "amount", Value: transaction.Amount},
+		{Key: "sender_address", Value: transaction.SenderAddress},
+		{Key: "currency", Value: transaction.Currency},
 		{Key: "lt", Value: transaction.Lt},
-		{Key: "verified", Value: true}}}}
+		{Key: "verified", Value: true}}
 
-	result, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update, opts)
+	// Claim donation announced with the sign, or create it when the announcement is missing.
+	// Unique sign index fails the upsert when the sign is already claimed by another transaction.
+	opts := options.FindOneAndUpdate().SetUpsert(true).SetReturnDocument(options.After)
+	filter := bson.D{{Key: "sign", Value: transaction.Sign}, {Key: "tx_hash", Value: nil}}
+	update := bson.D{{Key: "$set", Value: fields},
+		{Key: "$setOnInsert", Value: bson.D{
+			{Key: "created_at", Value: transaction.CreatedAt}}}}
 
-	if err != nil {
+	var donation Donation
+	err = collection.FindOneAndUpdate(ctx, filter, update, opts).Decode(&donation)
+	if err == nil {
+		return &donation, nil
+	} else if !mongo.IsDuplicateKeyError(err) {
+		return nil, err
+	}
+
+	duplicate := Donation{
+		DuplicateOf:   transaction.Sign,
+		TxHash:        transaction.TxHash,
+		WalletAddress: transaction.WalletAddress,
+		StreamerId:    streamerId,
+		Amount:        transaction.Amount,
+		SenderAddress: transaction.SenderAddress,
+		Currency:      transaction.Currency,
+		Lt:            transaction.Lt,
+		Verified:      true,
+		CreatedAt:     transaction.CreatedAt,
+	}
+	_, err = collection.InsertOne(ctx, duplicate)
+	if mongo.IsDuplicateKeyError(err) {
+		// Transaction was saved concurrently.
+		return savedConcurrently(m.findDonation(ctx, byTxHash))
+	} else if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	return &duplicate, nil
 }
 
-func (m *MongoStorage) AckDonation(ctx context.Context, transaction Tx) (*mongo.UpdateResult, error) {
+// savedConcurrently checks result of loading the donation saved by a concurrent SaveDonation
+// after a unique index rejected this one. Its absence means another index rejected the donation.
+func savedConcurrently(donation *Donation, err error) (*Donation, error) {
+	if err == nil && donation == nil {
+		return nil, errors.New("donation collided with an existing one but transaction is not saved")
+	}
+	return donation, err
+}
+
+// DonationAck is what we learn about donation when it is acknowledged.
+type DonationAck struct {
+	FiatRates map[string]float64
+	SessionId string
+}
+
+func (m *MongoStorage) AckDonation(ctx context.Context, transaction Tx, ack DonationAck) error {
 	dbName := os.Getenv("DB_NAME")
 	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
 
-	opts := options.Update().SetUpsert(true)
-	filter := bson.D{{Key: "sign", Value: transaction.Sign}}
-	update := bson.D{{Key: "$set", Value: bson.D{
-		{Key: "acked", Value: true}}}}
+	filter := bson.D{{Key: "tx_hash", Value: transaction.TxHash}}
+	set := bson.D{{Key: "acked", Value: true}}
+	if len(ack.FiatRates) > 0 {
+		set = append(set, bson.E{Key: "fiat_rates", Value: ack.FiatRates})
+	}
+	if ack.SessionId != "" {
+		set = append(set, bson.E{Key: "session_id", Value: ack.SessionId})
+	}
+	update := bson.D{{Key: "$set", Value: set}}
+
+	_, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update)
+	return err
+}
 
-	result, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update, opts)
+// GetModerationQueue returns verified donations with a message which were not moderated yet, newest first.
+func (m *MongoStorage) GetModerationQueue(ctx context.Context, streamerId string, limit int64) (*[]Donation, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
 
+	filter := bson.D{
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "verified", Value: true},
+		{Key: "message", Value: bson.D{{Key: "$gt", Value: ""}}},
+		{Key: "moderation", Value: bson.D{{Key: "$exists", Value: false}}},
+	}
+	opts := options.Find().SetSort(bson.D{{Key: "lt", Value: -1}}).SetLimit(limit)
+	iter, err := m.client.Database(dbName).Collection(collectionName).Find(ctx, filter, opts)
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	results := []Donation{}
+	if err := iter.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// ModerateDonation sets moderation of the donation, any streamer's when streamerId is empty. Returns nil if there is no such donation.
+func (m *MongoStorage) ModerateDonation(ctx context.Context, streamerId string, txHash string, moderation DonationModeration) (*Donation, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
+
+	filter := bson.D{{Key: "tx_hash", Value: txHash}}
+	if streamerId != "" {
+		filter = append(filter, bson.E{Key: "streamer_id", Value: streamerId})
+	}
+	update := bson.D{{Key: "$set", Value: bson.D{{Key: "moderation", Value: moderation}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+
+	result := m.client.Database(dbName).Collection(collectionName).FindOneAndUpdate(ctx, filter, update, opts)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	var donation Donation
+	if err := result.Decode(&donation); err != nil {
+		return nil, err
+	}
+
+	return &donation, nil
 }
@@ -0,0 +1,146 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// GoalRecord is a finished round of a goal widget with its final total.
+type GoalRecord struct {
+	AmountGoal     uint64     `json:"amount_goal" bson:"amount_goal"`
+	AmountFinal    uint64     `json:"amount_final" bson:"amount_final"`
+	DonationsCount uint64     `json:"donations_count" bson:"donations_count"`
+	IsCompleted    bool       `json:"is_completed" bson:"is_completed"`
+	StartedAt      *time.Time `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	EndedAt        time.Time  `json:"ended_at" bson:"ended_at"`
+}
+
+// GoalReset describes the next round of the goal, nil fields keep current values.
+type GoalReset struct {
+	AmountGoal *uint64
+	StartsAt   *time.Time
+	EndsAt     *time.Time
+}
+
+// ResetGoal moves current goal round into history and starts a new one from zero.
+func (m *MongoStorage) ResetGoal(ctx context.Context, streamerId string, widgetId string, reset GoalReset) (*Widget, error) {
+	now := time.Now().UTC()
+
+	set := bson.D{
+		{Key: "history", Value: goalHistoryAppend(now)},
+		{Key: "amount_current", Value: 0},
+		{Key: "donations_count", Value: 0},
+		{Key: "is_completed", Value: false},
+		{Key: "started_at", Value: now}}
+	if reset.AmountGoal != nil {
+		set = append(set, bson.E{Key: "amount_goal", Value: *reset.AmountGoal})
+	}
+	if reset.StartsAt != nil {
+		set = append(set, bson.E{Key: "starts_at", Value: *reset.StartsAt})
+	}
+	if reset.EndsAt != nil {
+		set = append(set, bson.E{Key: "ends_at", Value: *reset.EndsAt})
+	} else {
+		// New round of a goal which has ended runs without end date.
+		set = append(set, bson.E{Key: "ends_at", Value: bson.D{{Key: "$cond", Value: bson.A{
+			bson.D{{Key: "$lte", Value: bson.A{"$ends_at", now}}}, "$$REMOVE", "$ends_at"}}}})
+	}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$set", Value: set}},
+		bson.D{{Key: "$unset", Value: bson.A{"completed_at"}}},
+	}
+
+	return m.updateGoal(ctx, streamerId, widgetId, pipeline)
+}
+
+// ArchiveGoal moves current goal round into history and deactivates the widget for good.
+func (m *MongoStorage) ArchiveGoal(ctx context.Context, streamerId string, widgetId string) (*Widget, error) {
+	now := time.Now().UTC()
+
+	pipeline := bson.A{
+		bson.D{{Key: "$set", Value: bson.D{
+			{Key: "history", Value: goalHistoryAppend(now)},
+			{Key: "is_archived", Value: true},
+			{Key: "is_active", Value: false}}}},
+	}
+
+	return m.updateGoal(ctx, streamerId, widgetId, pipeline)
+}
+
+// goalHistoryAppend is an aggregation expression appending current round to the history, so it is done atomically.
+func goalHistoryAppend(endedAt time.Time) bson.D {
+	record := bson.D{
+		{Key: "amount_goal", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$amount_goal", 0}}}},
+		{Key: "amount_final", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$amount_current", 0}}}},
+		{Key: "donations_count", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$donations_count", 0}}}},
+		{Key: "is_completed", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$is_completed", false}}}},
+		{Key: "started_at", Value: "$started_at"},
+		{Key: "ended_at", Value: endedAt}}
+
+	return bson.D{{Key: "$concatArrays", Value: bson.A{
+		bson.D{{Key: "$ifNull", Value: bson.A{"$history", bson.A{}}}},
+		bson.A{record}}}}
+}
+
+func (m *MongoStorage) updateGoal(ctx context.Context, streamerId string, widgetId string, pipeline bson.A) (*Widget, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_WIDGETS_COLLECTION_NAME")
+
+	id, err := primitive.ObjectIDFromHex(widgetId)
+	if err != nil {
+		return nil, ErrInvalidWidgetId
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "type", Value: widgets.TypeDonationGoal},
+		{Key: "is_archived", Value: bson.D{{Key: "$ne", Value: true}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	result := m.client.Database(dbName).Collection(collectionName).FindOneAndUpdate(ctx, filter, pipeline, opts)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	var widget Widget
+	if err := result.Decode(&widget); err != nil {
+		return nil, err
+	}
+
+	return &widget, nil
+}
+
+// Goal statuses, derived from the lifecycle fields.
+const (
+	GoalStatusScheduled = "scheduled"
+	GoalStatusRunning   = "running"
+	GoalStatusCompleted = "completed"
+	GoalStatusEnded     = "ended"
+	GoalStatusArchived  = "archived"
+)
+
+// GoalStatus returns status of goal widget at the moment, empty for other widget types.
+func (w *Widget) GoalStatus(now time.Time) string {
+	switch {
+	case w.Type != widgets.TypeDonationGoal:
+		return ""
+	case w.IsArchived:
+		return GoalStatusArchived
+	case w.IsCompleted:
+		return GoalStatusCompleted
+	case w.StartsAt != nil && now.Before(*w.StartsAt):
+		return GoalStatusScheduled
+	case w.EndsAt != nil && !now.Before(*w.EndsAt):
+		return GoalStatusEnded
+	default:
+		return GoalStatusRunning
+	}
+}
@@ -0,0 +1,83 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+// Grant gives a role to an account. Moderator grants are scoped to the streamer who delegated them,
+// admin grants are platform wide and have empty StreamerId.
+type Grant struct {
+	Role       string `json:"role" bson:"role"`
+	StreamerId string `json:"streamerId,omitempty" bson:"streamer_id"`
+	// GranteeId is the streamer id of the account, as authenticated.
+	GranteeId string    `json:"granteeId" bson:"grantee_id"`
+	GrantedBy string    `json:"granted_by" bson:"granted_by"`
+	CreatedAt time.Time `json:"created_at" bson:"created_at"`
+}
+
+// SaveGrant creates the grant, saving an existing one is a no-op.
+func (m *MongoStorage) SaveGrant(ctx context.Context, grant Grant) error {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_GRANTS_COLLECTION_NAME")
+
+	opts := options.Update().SetUpsert(true)
+	filter := grantFilter(grant.Role, grant.StreamerId, grant.GranteeId)
+	update := bson.D{{Key: "$setOnInsert", Value: bson.D{
+		{Key: "granted_by", Value: grant.GrantedBy},
+		{Key: "created_at", Value: grant.CreatedAt}}}}
+
+	_, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update, opts)
+	return err
+}
+
+func (m *MongoStorage) DeleteGrant(ctx context.Context, role string, streamerId string, granteeId string) (bool, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_GRANTS_COLLECTION_NAME")
+
+	result, err := m.client.Database(dbName).Collection(collectionName).DeleteOne(ctx, grantFilter(role, streamerId, granteeId))
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+// GetGranteeGrants returns every role of the account.
+func (m *MongoStorage) GetGranteeGrants(ctx context.Context, granteeId string) ([]Grant, error) {
+	return m.findGrants(ctx, bson.D{{Key: "grantee_id", Value: granteeId}})
+}
+
+// GetStreamerGrants returns grants of the role scoped to the streamer, platform wide ones for empty streamerId.
+func (m *MongoStorage) GetStreamerGrants(ctx context.Context, role string, streamerId string) ([]Grant, error) {
+	return m.findGrants(ctx, bson.D{{Key: "role", Value: role}, {Key: "streamer_id", Value: streamerId}})
+}
+
+func (m *MongoStorage) findGrants(ctx context.Context, filter bson.D) ([]Grant, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_GRANTS_COLLECTION_NAME")
+
+	opts := options.Find().SetSort(bson.D{{Key: "created_at", Value: 1}})
+	cursor, err := m.client.Database(dbName).Collection(collectionName).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	grants := []Grant{}
+	if err := cursor.All(ctx, &grants); err != nil {
+		return nil, err
+	}
+
+	return grants, nil
+}
+
+func grantFilter(role string, streamerId string, granteeId string) bson.D {
+	return bson.D{
+		{Key: "role", Value: role},
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "grantee_id", Value: granteeId}}
+}
@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"sort"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+)
+
+// Donor grouping of the leaderboard.
+const (
+	DonorByNickname = "nickname"
+	DonorByWallet   = "wallet"
+)
+
+type TopDonorsQuery struct {
+	StreamerId string
+	GroupBy    string
+	// Currency donors are ranked by, other currencies are still summed up in totals.
+	Currency string
+	// Optional stream session, can be combined with the time range.
+	SessionId string
+	From      *time.Time
+	To        *time.Time
+	Limit     int64
+}
+
+type CurrencyTotal struct {
+	Currency string `json:"currency" bson:"currency"`
+	Amount   uint64 `json:"amount" bson:"amount"`
+}
+
+type TopDonor struct {
+	Donor          string          `json:"donor" bson:"_id"`
+	Totals         []CurrencyTotal `json:"totals" bson:"totals"`
+	DonationsCount uint64          `json:"donations_count" bson:"donations_count"`
+}
+
+type TopDonors struct {
+	Donors      []TopDonor      `json:"donors" bson:"donors"`
+	DonorsCount uint64          `json:"donors_count" bson:"donors_count"`
+	Totals      []CurrencyTotal `json:"totals" bson:"totals"`
+}
+
+// GetTopDonors ranks donors of verified donations which are not hidden using aggregation over donations collection.
+func (m *MongoStorage) GetTopDonors(ctx context.Context, query TopDonorsQuery) (*TopDonors, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_DONATIONS_COLLECTION_NAME")
+
+	donorKey := bson.D{{Key: "$ifNull", Value: bson.A{"$nickname", ""}}}
+	if query.GroupBy == DonorByWallet {
+		donorKey = bson.D{{Key: "$ifNull", Value: bson.A{"$sender_address", ""}}}
+	}
+
+	currency := query.Currency
+	if currency == "" {
+		currency = CurrencyTON
+	}
+
+	pipeline := bson.A{
+		bson.D{{Key: "$match", Value: donationsMatch(query.StreamerId, query.SessionId, query.From, query.To)}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: bson.D{
+				{Key: "donor", Value: donorKey},
+				{Key: "currency", Value: bson.D{{Key: "$ifNull", Value: bson.A{"$currency", CurrencyTON}}}}}},
+			{Key: "amount", Value: bson.D{{Key: "$sum", Value: "$amount"}}},
+			{Key: "count", Value: bson.D{{Key: "$sum", Value: 1}}}}}},
+		bson.D{{Key: "$group", Value: bson.D{
+			{Key: "_id", Value: "$_id.donor"},
+			{Key: "totals", Value: bson.D{{Key: "$push", Value: bson.D{
+				{Key: "currency", Value: "$_id.currency"},
+				{Key: "amount", Value: "$amount"}}}}},
+			{Key: "donations_count", Value: bson.D{{Key: "$sum", Value: "$count"}}},
+			{Key: "rank", Value: bson.D{{Key: "$sum", Value: bson.D{{Key: "$cond", Value: bson.A{
+				bson.D{{Key: "$eq", Value: bson.A{"$_id.currency", currency}}}, "$amount", 0}}}}}}}}},
+		bson.D{{Key: "$sort", Value: bson.D{
+			{Key: "rank", Value: -1},
+			{Key: "donations_count", Value: -1},
+			{Key: "_id", Value: 1}}}},
+		bson.D{{Key: "$facet", Value: bson.D{
+			{Key: "donors", Value: bson.A{bson.D{{Key: "$limit", Value: query.Limit}}}},
+			{Key: "donors_count", Value: bson.A{bson.D{{Key: "$count", Value: "count"}}}},
+			{Key: "totals", Value: bson.A{
+				bson.D{{Key: "$unwind", Value: "$totals"}},
+				bson.D{{Key: "$group", Value: bson.D{
+					{Key: "_id", Value: "$totals.currency"},
+					{Key: "amount", Value: bson.D{{Key: "$sum", Value: "$totals.amount"}}}}}},
+				bson.D{{Key: "$project", Value: bson.D{
+					{Key: "_id", Value: 0},
+					{Key: "currency", Value: "$_id"},
+					{Key: "amount", Value: 1}}}},
+				bson.D{{Key: "$sort", Value: bson.D{{Key: "currency", Value: 1}}}}}}}}},
+		bson.D{{Key: "$project", Value: bson.D{
+			{Key: "donors", Value: 1},
+			{Key: "totals", Value: 1},
+			{Key: "donors_count", Value: bson.D{{Key: "$ifNull", Value: bson.A{
+				bson.D{{Key: "$arrayElemAt", Value: bson.A{"$donors_count.count", 0}}}, 0}}}}}}},
+	}
+
+	iter, err := m.client.Database(dbName).Collection(collectionName).Aggregate(ctx, pipeline)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []TopDonors
+	if err := iter.All(ctx, &results); err != nil {
+		return nil, err
+	}
+
+	result := TopDonors{Donors: []TopDonor{}, Totals: []CurrencyTotal{}}
+	if len(results) > 0 {
+		result = results[0]
+	}
+
+	return &result, nil
+}
+
+// donationsMatch filters verified donations of the streamer made in the optional session and range,
+// donations hidden by moderation are left out as their donors are shown on stream overlays.
+func donationsMatch(streamerId string, sessionId string, from *time.Time, to *time.Time) bson.D {
+	match := bson.D{
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "verified", Value: true},
+		{Key: "moderation.status", Value: bson.D{{Key: "$ne", Value: ModerationHidden}}}}
+	if sessionId != "" {
+		match = append(match, bson.E{Key: "session_id", Value: sessionId})
+	}
+
+	createdAt := bson.D{}
+	if from != nil {
+		createdAt = append(createdAt, bson.E{Key: "$gte", Value: *from})
+	}
+	if to != nil {
+		createdAt = append(createdAt, bson.E{Key: "$lt", Value: *to})
+	}
+	if len(createdAt) > 0 {
+		match = append(match, bson.E{Key: "created_at", Value: createdAt})
+	}
+
+	return match
+}
+
+// donorAmount is the sum of one donor's donations in one currency.
+type donorAmount struct {
+	Donor    string
+	Currency string
+	Amount   uint64
+	Count    uint64
+}
+
+// rankTopDonors orders donors like the Mongo aggregation does: by amount in the currency,
+// then by donations count and donor name.
+func rankTopDonors(amounts []donorAmount, currency string, limit int64) *TopDonors {
+	type donorTotals struct {
+		donor   TopDonor
+		amounts map[string]uint64
+	}
+	donors := map[string]*donorTotals{}
+	totals := map[string]uint64{}
+
+	for _, amount := range amounts {
+		donor, ok := donors[amount.Donor]
+		if !ok {
+			donor = &donorTotals{donor: TopDonor{Donor: amount.Donor}, amounts: map[string]uint64{}}
+			donors[amount.Donor] = donor
+		}
+
+		donor.amounts[amount.Currency] += amount.Amount
+		donor.donor.DonationsCount += amount.Count
+		totals[amount.Currency] += amount.Amount
+	}
+
+	ranked := make([]*donorTotals, 0, len(donors))
+	for _, donor := range donors {
+		donor.donor.Totals = currencyTotals(donor.amounts)
+		ranked = append(ranked, donor)
+	}
+	sort.Slice(ranked, func(i, j int) bool {
+		a, b := ranked[i], ranked[j]
+		if a.amounts[currency] != b.amounts[currency] {
+			return a.amounts[currency] > b.amounts[currency]
+		}
+		if a.donor.DonationsCount != b.donor.DonationsCount {
+			return a.donor.DonationsCount > b.donor.DonationsCount
+		}
+		return a.donor.Donor < b.donor.Donor
+	})
+
+	result := TopDonors{
+		Donors:      []TopDonor{},
+		DonorsCount: uint64(len(ranked)),
+		Totals:      currencyTotals(totals),
+	}
+	for i, donor := range ranked {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+		result.Donors = append(result.Donors, donor.donor)
+	}
+
+	return &result
+}
+
+func currencyTotals(amounts map[string]uint64) []CurrencyTotal {
+	totals := make([]CurrencyTotal, 0, len(amounts))
+	for currency, amount := range amounts {
+		totals = append(totals, CurrencyTotal{Currency: currency, Amount: amount})
+	}
+	sort.Slice(totals, func(i, j int) bool { return totals[i].Currency < totals[j].Currency })
+	return totals
+}
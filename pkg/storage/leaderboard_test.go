@@ -0,0 +1,166 @@
+package storage
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRankTopDonors(t *testing.T) {
+	tests := []struct {
+		name     string
+		amounts  []donorAmount
+		currency string
+		limit    int64
+		donors   []string
+		count    uint64
+		totals   []CurrencyTotal
+	}{
+		{
+			name: "ranked by amount in the currency",
+			amounts: []donorAmount{
+				{Donor: "alice", Currency: CurrencyTON, Amount: 5, Count: 1},
+				{Donor: "bob", Currency: CurrencyTON, Amount: 7, Count: 1},
+				{Donor: "alice", Currency: "USD", Amount: 100, Count: 3},
+			},
+			currency: CurrencyTON,
+			donors:   []string{"bob", "alice"},
+			count:    2,
+			totals:   []CurrencyTotal{{Currency: CurrencyTON, Amount: 12}, {Currency: "USD", Amount: 100}},
+		},
+		{
+			name: "other currency changes the order",
+			amounts: []donorAmount{
+				{Donor: "alice", Currency: CurrencyTON, Amount: 5, Count: 1},
+				{Donor: "bob", Currency: CurrencyTON, Amount: 7, Count: 1},
+				{Donor: "alice", Currency: "USD", Amount: 100, Count: 3},
+			},
+			currency: "USD",
+			donors:   []string{"alice", "bob"},
+			count:    2,
+			totals:   []CurrencyTotal{{Currency: CurrencyTON, Amount: 12}, {Currency: "USD", Amount: 100}},
+		},
+		{
+			name: "ties are broken by donations count, then by donor",
+			amounts: []donorAmount{
+				{Donor: "carol", Currency: CurrencyTON, Amount: 10, Count: 1},
+				{Donor: "bob", Currency: CurrencyTON, Amount: 10, Count: 1},
+				{Donor: "alice", Currency: CurrencyTON, Amount: 10, Count: 2},
+			},
+			currency: CurrencyTON,
+			donors:   []string{"alice", "bob", "carol"},
+			count:    3,
+			totals:   []CurrencyTotal{{Currency: CurrencyTON, Amount: 30}},
+		},
+		{
+			name: "limit keeps totals of all donors",
+			amounts: []donorAmount{
+				{Donor: "alice", Currency: CurrencyTON, Amount: 5, Count: 1},
+				{Donor: "bob", Currency: CurrencyTON, Amount: 7, Count: 1},
+			},
+			currency: CurrencyTON,
+			limit:    1,
+			donors:   []string{"bob"},
+			count:    2,
+			totals:   []CurrencyTotal{{Currency: CurrencyTON, Amount: 12}},
+		},
+		{
+			name:     "no donations",
+			currency: CurrencyTON,
+			donors:   []string{},
+			count:    0,
+			totals:   []CurrencyTotal{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := rankTopDonors(test.amounts, test.currency, test.limit)
+
+			donors := []string{}
+			for _, donor := range result.Donors {
+				donors = append(donors, donor.Donor)
+			}
+			if !reflect.DeepEqual(donors, test.donors) {
+				t.Fatalf("expected donors %v, got %v", test.donors, donors)
+			}
+			if !reflect.DeepEqual(result.Totals, test.totals) {
+				t.Fatalf("expected totals %v, got %v", test.totals, result.Totals)
+			}
+			if result.DonorsCount != test.count {
+				t.Fatalf("expected %d donors counted, got %d", test.count, result.DonorsCount)
+			}
+		})
+	}
+}
+
+func TestMemoryGetTopDonors(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage()
+
+	now := time.Now().UTC()
+	donations := []Donation{
+		{TxHash: "hash-1", From: "alice", SenderAddress: "wallet-a", Amount: 5, Verified: true, CreatedAt: now.Add(-2 * time.Hour)},
+		{TxHash: "hash-2", From: "alice", SenderAddress: "wallet-b", Amount: 3, Verified: true, CreatedAt: now},
+		{TxHash: "hash-3", From: "bob", SenderAddress: "wallet-b", Amount: 6, Verified: true, CreatedAt: now},
+		{TxHash: "hash-4", From: "carol", SenderAddress: "wallet-c", Amount: 100, Verified: true, CreatedAt: now,
+			Moderation: &DonationModeration{Status: ModerationHidden}},
+		{TxHash: "hash-5", From: "dave", SenderAddress: "wallet-d", Amount: 100, CreatedAt: now},
+		{TxHash: "hash-6", From: "erin", SenderAddress: "wallet-e", Amount: 1, Verified: true, CreatedAt: now,
+			Moderation: &DonationModeration{Status: ModerationApproved}},
+	}
+	for _, donation := range donations {
+		donation.StreamerId = "streamer-1"
+		if err := memory.CreateDonation(ctx, donation); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	hourAgo := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+	tests := []struct {
+		name   string
+		query  TopDonorsQuery
+		donors []string
+	}{
+		{
+			name:   "hidden and unverified donations are left out",
+			query:  TopDonorsQuery{},
+			donors: []string{"alice", "bob", "erin"},
+		},
+		{
+			name:   "grouped by wallet",
+			query:  TopDonorsQuery{GroupBy: DonorByWallet},
+			donors: []string{"wallet-b", "wallet-a", "wallet-e"},
+		},
+		{
+			name:   "range",
+			query:  TopDonorsQuery{From: &hourAgo},
+			donors: []string{"bob", "alice", "erin"},
+		},
+		{
+			name:   "empty range",
+			query:  TopDonorsQuery{From: &later},
+			donors: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			test.query.StreamerId = "streamer-1"
+			result, err := memory.GetTopDonors(ctx, test.query)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			donors := []string{}
+			for _, donor := range result.Donors {
+				donors = append(donors, donor.Donor)
+			}
+			if !reflect.DeepEqual(donors, test.donors) {
+				t.Fatalf("expected donors %v, got %v", test.donors, donors)
+			}
+		})
+	}
+}
@@ -0,0 +1,1031 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// MemoryStorage keeps everything in process memory with the same semantics as MongoStorage.
+// It is meant for local development and tests, data is lost on restart.
+type MemoryStorage struct {
+	mu        sync.RWMutex
+	streamers []Streamer
+	donations []Donation
+	widgets   []Widget
+	sessions  []StreamSession
+
+	refreshTokens []RefreshToken
+	nonces        []Nonce
+	grants        []Grant
+}
+
+func NewMemoryStorage() *MemoryStorage {
+	return &MemoryStorage{}
+}
+
+// Repositories returns MemoryStorage as every repository.
+func (m *MemoryStorage) Repositories() Repositories {
+	return Repositories{
+		Streamers: m,
+		Donations: m,
+		Widgets:   m,
+		Sessions:  m,
+
+		RefreshTokens: m,
+		Grants:        m,
+		Nonces:        m,
+	}
+}
+
+// Stored values are copied in and out, so callers can't change them behind the lock.
+
+func cloneStreamer(s Streamer) *Streamer {
+	s.Wallets = append([]StreamerWallet(nil), s.Wallets...)
+	if s.Twitch != nil {
+		twitch := *s.Twitch
+		s.Twitch = &twitch
+	}
+	return &s
+}
+
+func cloneWidget(w Widget) *Widget {
+	w.History = append([]GoalRecord(nil), w.History...)
+	return &w
+}
+
+func (m *MemoryStorage) findStreamer(match func(s *Streamer) bool) *Streamer {
+	for i := range m.streamers {
+		if match(&m.streamers[i]) {
+			return &m.streamers[i]
+		}
+	}
+	return nil
+}
+
+func (m *MemoryStorage) getStreamer(match func(s *Streamer) bool) (*Streamer, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if streamer := m.findStreamer(match); streamer != nil {
+		return cloneStreamer(*streamer), nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStorage) GetStreamerByCognitoId(ctx context.Context, cognitoId string) (*Streamer, error) {
+	return m.getStreamer(func(s *Streamer) bool { return s.CognitoId == cognitoId })
+}
+
+func (m *MemoryStorage) GetStreamerByStreamerId(ctx context.Context, streamerId string) (*Streamer, error) {
+	return m.getStreamer(func(s *Streamer) bool { return s.StreamerId == streamerId })
+}
+
+// GetStreamerByWalletAddress looks up the streamer by any of the wallets, including retired ones.
+func (m *MemoryStorage) GetStreamerByWalletAddress(ctx context.Context, walletAddress string) (*Streamer, error) {
+	return m.getStreamer(func(s *Streamer) bool { return hasWallet(s, walletAddress) })
+}
+
+func (m *MemoryStorage) GetStreamerBySlug(ctx context.Context, slug string) (*Streamer, error) {
+	return m.getStreamer(func(s *Streamer) bool { return s.Slug == slug })
+}
+
+func (m *MemoryStorage) GetStreamerByTwitchUserId(ctx context.Context, twitchUserId string) (*Streamer, error) {
+	return m.getStreamer(func(s *Streamer) bool { return s.Twitch != nil && s.Twitch.UserId == twitchUserId })
+}
+
+func hasWallet(s *Streamer, walletAddress string) bool {
+	if s.WalletAddress == walletAddress {
+		return true
+	}
+	for _, wallet := range s.Wallets {
+		if wallet.Address == walletAddress {
+			return true
+		}
+	}
+	return false
+}
+
+// SaveStreamer upserts streamer profile by streamer id, wallets and linked accounts are kept.
+// Wallet address or slug registered by another streamer is rejected with ErrWalletAddressTaken or ErrSlugTaken.
+func (m *MemoryStorage) SaveStreamer(ctx context.Context, streamer Streamer) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	owner := m.findStreamer(func(s *Streamer) bool { return hasWallet(s, streamer.WalletAddress) })
+	if streamer.WalletAddress != "" && owner != nil && owner.StreamerId != streamer.StreamerId {
+		return ErrWalletAddressTaken
+	}
+
+	owner = m.findStreamer(func(s *Streamer) bool { return s.Slug == streamer.Slug })
+	if streamer.Slug != "" && owner != nil && owner.StreamerId != streamer.StreamerId {
+		return ErrSlugTaken
+	}
+
+	existing := m.findStreamer(func(s *Streamer) bool { return s.StreamerId == streamer.StreamerId })
+	if existing == nil {
+		m.streamers = append(m.streamers, Streamer{StreamerId: streamer.StreamerId})
+		existing = &m.streamers[len(m.streamers)-1]
+	}
+
+	existing.CognitoId = streamer.CognitoId
+	existing.WalletAddress = streamer.WalletAddress
+	existing.Slug = streamer.Slug
+	existing.DisplayName = streamer.DisplayName
+	existing.AvatarUrl = streamer.AvatarUrl
+	existing.Description = streamer.Description
+
+	return nil
+}
+
+// AddStreamerWallet adds active payout wallet to the streamer or reactivates previously retired one.
+func (m *MemoryStorage) AddStreamerWallet(ctx context.Context, streamerId string, walletAddress string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	streamer := m.findStreamer(func(s *Streamer) bool { return s.StreamerId == streamerId })
+	if streamer == nil {
+		return nil
+	}
+
+	for i := range streamer.Wallets {
+		wallet := &streamer.Wallets[i]
+		if wallet.Address == walletAddress {
+			if !wallet.IsActive {
+				wallet.Periods = append(wallet.Periods, wallet.retiredPeriod())
+				wallet.IsActive = true
+				wallet.ActiveFrom = time.Now().UTC()
+				wallet.RetiredAt = nil
+			}
+			return nil
+		}
+	}
+
+	streamer.Wallets = append(streamer.Wallets, StreamerWallet{
+		Address:    walletAddress,
+		IsActive:   true,
+		ActiveFrom: time.Now().UTC(),
+	})
+	return nil
+}
+
+// RetireStreamerWallet stops accepting donations to the wallet but keeps it in streamer history.
+func (m *MemoryStorage) RetireStreamerWallet(ctx context.Context, streamerId string, walletAddress string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	streamer := m.findStreamer(func(s *Streamer) bool { return s.StreamerId == streamerId })
+	if streamer == nil {
+		return false, nil
+	}
+
+	for i := range streamer.Wallets {
+		if streamer.Wallets[i].Address == walletAddress && streamer.Wallets[i].IsActive {
+			now := time.Now().UTC()
+			streamer.Wallets[i].IsActive = false
+			streamer.Wallets[i].RetiredAt = &now
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// SetStreamerTwitch links Twitch channel to the streamer, nil account unlinks it.
+func (m *MemoryStorage) SetStreamerTwitch(ctx context.Context, streamerId string, account *TwitchAccount) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	streamer := m.findStreamer(func(s *Streamer) bool { return s.StreamerId == streamerId })
+	if streamer == nil {
+		return nil
+	}
+
+	streamer.Twitch = nil
+	if account != nil {
+		twitch := *account
+		streamer.Twitch = &twitch
+	}
+	return nil
+}
+
+// SetStreamerDisabled disables the account with a reason or enables it back, false when there is no such streamer.
+func (m *MemoryStorage) SetStreamerDisabled(ctx context.Context, streamerId string, disabled bool, reason string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	streamer := m.findStreamer(func(s *Streamer) bool { return s.StreamerId == streamerId })
+	if streamer == nil {
+		return false, nil
+	}
+
+	streamer.Disabled = disabled
+	streamer.DisabledReason = ""
+	if disabled {
+		streamer.DisabledReason = reason
+	}
+	return true, nil
+}
+
+func (m *MemoryStorage) GetStreamerDonations(ctx context.Context, streamerId string) (*[]Donation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var results []Donation
+	for _, donation := range m.donations {
+		if donation.StreamerId == streamerId {
+			results = append(results, donation)
+		}
+	}
+
+	return &results, nil
+}
+
+// GetPublicDonations returns the latest verified donations of a streamer, newest first.
+func (m *MemoryStorage) GetPublicDonations(ctx context.Context, streamerId string, limit int64) (*[]Donation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var results []Donation
+	for _, donation := range m.donations {
+		hidden := donation.Moderation != nil && donation.Moderation.Status == ModerationHidden
+		if donation.StreamerId == streamerId && donation.Verified && !hidden {
+			results = append(results, donation)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Lt > results[j].Lt })
+	if limit > 0 && int64(len(results)) > limit {
+		results = results[:limit]
+	}
+
+	return &results, nil
+}
+
+func (m *MemoryStorage) GetDonationBySign(ctx context.Context, sign string) (*Donation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if donation := m.findDonationBySign(sign); donation != nil {
+		result := *donation
+		return &result, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStorage) GetDonationByTxHash(ctx context.Context, txHash string) (*Donation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if donation := m.findDonationByTxHash(txHash); donation != nil {
+		result := *donation
+		return &result, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStorage) findDonation(match func(d *Donation) bool) *Donation {
+	for i := range m.donations {
+		if match(&m.donations[i]) {
+			return &m.donations[i]
+		}
+	}
+	return nil
+}
+
+// Donations of duplicate transactions have no sign, only announced and confirmed ones are unique by it.
+func (m *MemoryStorage) findDonationBySign(sign string) *Donation {
+	return m.findDonation(func(d *Donation) bool { return d.Sign != "" && d.Sign == sign })
+}
+
+func (m *MemoryStorage) findDonationByTxHash(txHash string) *Donation {
+	return m.findDonation(func(d *Donation) bool { return d.TxHash != "" && d.TxHash == txHash })
+}
+
+func (m *MemoryStorage) CreateDonation(ctx context.Context, donation Donation) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findDonationBySign(donation.Sign) != nil {
+		return ErrDuplicate
+	}
+
+	m.donations = append(m.donations, donation)
+	return nil
+}
+
+func (m *MemoryStorage) SaveDonation(ctx context.Context, transaction Tx, streamerId string) (*Donation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	donation := m.findDonationByTxHash(transaction.TxHash)
+	if donation == nil {
+		donation = m.findDonationBySign(transaction.Sign)
+		switch {
+		case donation == nil:
+			m.donations = append(m.donations, Donation{Sign: transaction.Sign, CreatedAt: transaction.CreatedAt})
+			donation = &m.donations[len(m.donations)-1]
+		case donation.TxHash != "":
+			// Sign is claimed by another transaction, keep both donations.
+			m.donations = append(m.donations, Donation{DuplicateOf: transaction.Sign, CreatedAt: transaction.CreatedAt})
+			donation = &m.donations[len(m.donations)-1]
+		}
+
+		donation.TxHash = transaction.TxHash
+		donation.WalletAddress = transaction.WalletAddress
+		donation.StreamerId = streamerId
+		donation.Amount = transaction.Amount
+		donation.SenderAddress = transaction.SenderAddress
+		donation.Currency = transaction.Currency
+		donation.Lt = transaction.Lt
+		donation.Verified = true
+	}
+
+	result := *donation
+	return &result, nil
+}
+
+func (m *MemoryStorage) AckDonation(ctx context.Context, transaction Tx, ack DonationAck) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	donation := m.findDonationByTxHash(transaction.TxHash)
+	if donation == nil {
+		return nil
+	}
+
+	donation.Acked = true
+	if len(ack.FiatRates) > 0 {
+		donation.FiatRates = ack.FiatRates
+	}
+	if ack.SessionId != "" {
+		donation.SessionId = ack.SessionId
+	}
+
+	return nil
+}
+
+// GetModerationQueue returns verified donations with a message which were not moderated yet, newest first.
+func (m *MemoryStorage) GetModerationQueue(ctx context.Context, streamerId string, limit int64) (*[]Donation, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []Donation{}
+	for _, donation := range m.donations {
+		if donation.StreamerId == streamerId && donation.Verified && donation.Message != "" && donation.Moderation == nil {
+			results = append(results, donation)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].Lt > results[j].Lt })
+	if limit > 0 && int64(len(results)) > limit {
+		results = results[:limit]
+	}
+
+	return &results, nil
+}
+
+// ModerateDonation sets moderation of the donation, any streamer's when streamerId is empty. Returns nil if there is no such donation.
+func (m *MemoryStorage) ModerateDonation(ctx context.Context, streamerId string, txHash string, moderation DonationModeration) (*Donation, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	donation := m.findDonationByTxHash(txHash)
+	if donation == nil || (streamerId != "" && donation.StreamerId != streamerId) {
+		return nil, nil
+	}
+
+	donation.Moderation = &moderation
+	result := *donation
+	return &result, nil
+}
+
+// matchDonations returns verified donations of the streamer made in the optional session and range.
+func (m *MemoryStorage) matchDonations(streamerId string, sessionId string, from *time.Time, to *time.Time, match func(d *Donation) bool) []Donation {
+	var results []Donation
+	for _, donation := range m.donations {
+		switch {
+		case donation.StreamerId != streamerId,
+			sessionId != "" && donation.SessionId != sessionId,
+			from != nil && donation.CreatedAt.Before(*from),
+			to != nil && !donation.CreatedAt.Before(*to),
+			!match(&donation):
+			continue
+		}
+		results = append(results, donation)
+	}
+	return results
+}
+
+func donationCurrency(d *Donation) string {
+	if d.Currency == "" {
+		return CurrencyTON
+	}
+	return d.Currency
+}
+
+// GetTopDonors ranks donors of verified donations which are not hidden the same way as the Mongo aggregation.
+func (m *MemoryStorage) GetTopDonors(ctx context.Context, query TopDonorsQuery) (*TopDonors, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	currency := query.Currency
+	if currency == "" {
+		currency = CurrencyTON
+	}
+
+	var amounts []donorAmount
+	shown := func(d *Donation) bool {
+		return d.Verified && (d.Moderation == nil || d.Moderation.Status != ModerationHidden)
+	}
+	for _, donation := range m.matchDonations(query.StreamerId, query.SessionId, query.From, query.To, shown) {
+		donor := donation.From
+		if query.GroupBy == DonorByWallet {
+			donor = donation.SenderAddress
+		}
+
+		amounts = append(amounts, donorAmount{
+			Donor:    donor,
+			Currency: donationCurrency(&donation),
+			Amount:   donation.Amount,
+			Count:    1,
+		})
+	}
+
+	return rankTopDonors(amounts, currency, query.Limit), nil
+}
+
+// GetAnalytics aggregates streamer donations into time buckets in the streamer timezone.
+func (m *MemoryStorage) GetAnalytics(ctx context.Context, query AnalyticsQuery) (*Analytics, error) {
+	location, err := time.LoadLocation(query.Timezone)
+	if err != nil {
+		return nil, err
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	match := func(d *Donation) bool {
+		if donationCurrency(d) != query.Currency {
+			return false
+		}
+
+		switch query.Status {
+		case DonationStatusPending:
+			return !d.Verified
+		case DonationStatusVerified:
+			return d.Verified && !d.Acked
+		case DonationStatusAcked:
+			return d.Acked
+		default:
+			return d.Verified
+		}
+	}
+
+	type stats struct {
+		AnalyticsStats
+		donors map[string]bool
+	}
+	add := func(s *stats, d *Donation) {
+		// Like in Mongo, donations without sender and nickname are not counted as a donor.
+		donor := d.SenderAddress
+		if donor == "" {
+			donor = d.From
+		}
+		s.Count++
+		s.Sum += d.Amount
+		if donor != "" {
+			s.donors[donor] = true
+		}
+		s.UniqueDonors = uint64(len(s.donors))
+		s.Average = s.Sum / s.Count
+	}
+
+	summary := &stats{donors: map[string]bool{}}
+	buckets := map[time.Time]*stats{}
+	for _, donation := range m.matchDonations(query.StreamerId, query.SessionId, query.From, query.To, match) {
+		start := truncateDate(donation.CreatedAt, query.Interval, location)
+		bucket, ok := buckets[start]
+		if !ok {
+			bucket = &stats{donors: map[string]bool{}}
+			buckets[start] = bucket
+		}
+
+		add(bucket, &donation)
+		add(summary, &donation)
+	}
+
+	result := Analytics{Summary: summary.AnalyticsStats, Buckets: []AnalyticsBucket{}}
+	for start, bucket := range buckets {
+		result.Buckets = append(result.Buckets, AnalyticsBucket{Start: start, AnalyticsStats: bucket.AnalyticsStats})
+	}
+	sort.Slice(result.Buckets, func(i, j int) bool { return result.Buckets[i].Start.Before(result.Buckets[j].Start) })
+
+	return &result, nil
+}
+
+// truncateDate works like $dateTrunc with weeks starting on monday.
+func truncateDate(t time.Time, interval string, location *time.Location) time.Time {
+	local := t.In(location)
+	year, month, day := local.Date()
+
+	switch interval {
+	case IntervalHour:
+		return time.Date(year, month, day, local.Hour(), 0, 0, 0, location).UTC()
+	case IntervalWeek:
+		daysSinceMonday := (int(local.Weekday()) + 6) % 7
+		return time.Date(year, month, day-daysSinceMonday, 0, 0, 0, 0, location).UTC()
+	default:
+		return time.Date(year, month, day, 0, 0, 0, 0, location).UTC()
+	}
+}
+
+// GetWidgets returns streamer widgets, archived goals are returned only when asked for.
+func (m *MemoryStorage) GetWidgets(ctx context.Context, streamerId string, archived bool) (*[]Widget, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	var results []Widget
+	for _, widget := range m.widgets {
+		if widget.StreamerId == streamerId && widget.IsArchived == archived && len(results) < 100 {
+			results = append(results, *cloneWidget(widget))
+		}
+	}
+
+	return &results, nil
+}
+
+// GetActiveGoalWidget returns the active donation goal widget, or nil if the streamer has none.
+func (m *MemoryStorage) GetActiveGoalWidget(ctx context.Context, streamerId string) (*Widget, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	for _, widget := range m.widgets {
+		if widget.StreamerId == streamerId && widget.Type == widgets.TypeDonationGoal && widget.IsActive && !widget.IsArchived {
+			return cloneWidget(widget), nil
+		}
+	}
+
+	return nil, nil
+}
+
+// findWidget returns stored widget of the streamer, widgets of other streamers are not found.
+func (m *MemoryStorage) findWidget(streamerId string, widgetId string) (*Widget, error) {
+	id, err := primitive.ObjectIDFromHex(widgetId)
+	if err != nil {
+		return nil, ErrInvalidWidgetId
+	}
+
+	for i := range m.widgets {
+		if m.widgets[i].Id == id && m.widgets[i].StreamerId == streamerId {
+			return &m.widgets[i], nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (m *MemoryStorage) GetWidget(ctx context.Context, streamerId string, widgetId string) (*Widget, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	widget, err := m.findWidget(streamerId, widgetId)
+	if err != nil || widget == nil {
+		return nil, err
+	}
+
+	return cloneWidget(*widget), nil
+}
+
+// CreateWidget stores new widget of existing streamer and returns it with the assigned id.
+func (m *MemoryStorage) CreateWidget(ctx context.Context, widget Widget) (*Widget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.findStreamer(func(s *Streamer) bool { return s.StreamerId == widget.StreamerId }) == nil {
+		return nil, errors.New("Streamer does not exist.")
+	}
+
+	widget.Id = primitive.NewObjectID()
+	m.widgets = append(m.widgets, *cloneWidget(widget))
+
+	return &widget, nil
+}
+
+// UpdateWidget applies the update to streamer's widget and returns the updated one, nil if there is no such widget.
+func (m *MemoryStorage) UpdateWidget(ctx context.Context, streamerId string, widgetId string, update WidgetUpdate) (*Widget, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	widget, err := m.findWidget(streamerId, widgetId)
+	if err != nil || widget == nil {
+		return nil, false, err
+	}
+
+	completed := false
+
+	if update.Title != nil {
+		widget.Title = *update.Title
+	}
+	if update.AmountGoal != nil {
+		widget.AmountGoal = *update.AmountGoal
+
+		if widget.IsCompleted != widget.reachedGoal() {
+			widget.IsCompleted = widget.reachedGoal()
+			widget.CompletedAt = nil
+			if widget.IsCompleted {
+				now := time.Now().UTC()
+				widget.CompletedAt = &now
+				completed = true
+			}
+		}
+	}
+	if update.IsActive != nil {
+		widget.IsActive = *update.IsActive
+	}
+	if update.Config != nil {
+		widget.Config = *update.Config
+	}
+	if update.StartsAt != nil {
+		startsAt := *update.StartsAt
+		widget.StartsAt = &startsAt
+	}
+	if update.EndsAt != nil {
+		endsAt := *update.EndsAt
+		widget.EndsAt = &endsAt
+	}
+
+	return cloneWidget(*widget), completed, nil
+}
+
+// DeleteWidget removes streamer's widget, returns false if there is no such widget.
+func (m *MemoryStorage) DeleteWidget(ctx context.Context, streamerId string, widgetId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	widget, err := m.findWidget(streamerId, widgetId)
+	if err != nil || widget == nil {
+		return false, err
+	}
+
+	for i := range m.widgets {
+		if m.widgets[i].Id == widget.Id {
+			m.widgets = append(m.widgets[:i], m.widgets[i+1:]...)
+			break
+		}
+	}
+
+	return true, nil
+}
+
+// AddToCurrentAmount credits donation to streamer's running goal widgets, see MongoStorage.AddToCurrentAmount.
+func (m *MemoryStorage) AddToCurrentAmount(ctx context.Context, streamerId string, widgetId string, donatedAmount uint64, fiatCents map[string]uint64) (*GoalCredit, error) {
+	var id primitive.ObjectID
+	if widgetId != "" {
+		var err error
+		if id, err = primitive.ObjectIDFromHex(widgetId); err != nil {
+			return nil, ErrInvalidWidgetId
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now().UTC()
+	credit := &GoalCredit{}
+	for i := range m.widgets {
+		widget := &m.widgets[i]
+		if widget.StreamerId != streamerId || !widget.IsActive {
+			continue
+		}
+
+		switch {
+		case widget.Type == widgets.TypeDonationCounter:
+			widget.AmountCurrent += donatedAmount
+			widget.DonationsCount++
+		case widget.Type == widgets.TypeDonationGoal && !widget.IsArchived && !widget.IsCompleted &&
+			(widgetId == "" || widget.Id == id) &&
+			(widget.StartsAt == nil || !widget.StartsAt.After(now)) &&
+			(widget.EndsAt == nil || widget.EndsAt.After(now)):
+			amount, ok := widget.CreditAmount(donatedAmount, fiatCents)
+			if !ok {
+				credit.Skipped = append(credit.Skipped, *cloneWidget(*widget))
+				continue
+			}
+
+			widget.AmountCurrent += amount
+			widget.DonationsCount++
+
+			if widget.AmountGoal > 0 && widget.AmountCurrent >= widget.AmountGoal && widget.AmountCurrent-amount < widget.AmountGoal {
+				widget.IsCompleted = true
+				widget.CompletedAt = &now
+				credit.Completed = append(credit.Completed, *cloneWidget(*widget))
+			}
+		}
+	}
+
+	return credit, nil
+}
+
+// ResetGoal moves current goal round into history and starts a new one from zero.
+func (m *MemoryStorage) ResetGoal(ctx context.Context, streamerId string, widgetId string, reset GoalReset) (*Widget, error) {
+	return m.updateGoal(streamerId, widgetId, func(widget *Widget, now time.Time) {
+		widget.AmountCurrent = 0
+		widget.DonationsCount = 0
+		widget.IsCompleted = false
+		widget.CompletedAt = nil
+		widget.StartedAt = &now
+
+		if reset.AmountGoal != nil {
+			widget.AmountGoal = *reset.AmountGoal
+		}
+		if reset.StartsAt != nil {
+			startsAt := *reset.StartsAt
+			widget.StartsAt = &startsAt
+		}
+		if reset.EndsAt != nil {
+			endsAt := *reset.EndsAt
+			widget.EndsAt = &endsAt
+		} else if widget.EndsAt != nil && !widget.EndsAt.After(now) {
+			widget.EndsAt = nil
+		}
+	})
+}
+
+// ArchiveGoal moves current goal round into history and deactivates the widget for good.
+func (m *MemoryStorage) ArchiveGoal(ctx context.Context, streamerId string, widgetId string) (*Widget, error) {
+	return m.updateGoal(streamerId, widgetId, func(widget *Widget, now time.Time) {
+		widget.IsArchived = true
+		widget.IsActive = false
+	})
+}
+
+func (m *MemoryStorage) updateGoal(streamerId string, widgetId string, update func(widget *Widget, now time.Time)) (*Widget, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	widget, err := m.findWidget(streamerId, widgetId)
+	if err != nil || widget == nil || widget.Type != widgets.TypeDonationGoal || widget.IsArchived {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	widget.History = append(widget.History, GoalRecord{
+		AmountGoal:     widget.AmountGoal,
+		AmountFinal:    widget.AmountCurrent,
+		DonationsCount: widget.DonationsCount,
+		IsCompleted:    widget.IsCompleted,
+		StartedAt:      widget.StartedAt,
+		EndedAt:        now,
+	})
+	update(widget, now)
+
+	return cloneWidget(*widget), nil
+}
+
+// StartSession creates new active session.
+func (m *MemoryStorage) StartSession(ctx context.Context, session StreamSession) (*StreamSession, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	active := m.findSession(func(s *StreamSession) bool { return s.StreamerId == session.StreamerId && s.EndedAt == nil })
+	if session.EndedAt == nil && active != nil {
+		return nil, ErrSessionActive
+	}
+
+	session.Id = primitive.NewObjectID()
+	m.sessions = append(m.sessions, session)
+
+	return &session, nil
+}
+
+// findSession returns the latest started stored session matching the filter.
+func (m *MemoryStorage) findSession(match func(s *StreamSession) bool) *StreamSession {
+	var found *StreamSession
+	for i := range m.sessions {
+		if match(&m.sessions[i]) && (found == nil || m.sessions[i].StartedAt.After(found.StartedAt)) {
+			found = &m.sessions[i]
+		}
+	}
+	return found
+}
+
+// GetActiveSession returns session which is not ended yet, or nil.
+func (m *MemoryStorage) GetActiveSession(ctx context.Context, streamerId string) (*StreamSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if session := m.findSession(func(s *StreamSession) bool { return s.StreamerId == streamerId && s.EndedAt == nil }); session != nil {
+		result := *session
+		return &result, nil
+	}
+	return nil, nil
+}
+
+func (m *MemoryStorage) GetSession(ctx context.Context, streamerId string, sessionId string) (*StreamSession, error) {
+	id, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return nil, ErrInvalidSessionId
+	}
+
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	if session := m.findSession(func(s *StreamSession) bool { return s.StreamerId == streamerId && s.Id == id }); session != nil {
+		result := *session
+		return &result, nil
+	}
+	return nil, nil
+}
+
+// GetSessions returns streamer sessions, newest first.
+func (m *MemoryStorage) GetSessions(ctx context.Context, streamerId string, limit int64) (*[]StreamSession, error) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	results := []StreamSession{}
+	for _, session := range m.sessions {
+		if session.StreamerId == streamerId {
+			results = append(results, session)
+		}
+	}
+
+	sort.SliceStable(results, func(i, j int) bool { return results[i].StartedAt.After(results[j].StartedAt) })
+	if limit > 0 && int64(len(results)) > limit {
+		results = results[:limit]
+	}
+
+	return &results, nil
+}
+
+// EndSession ends the session, or the active one when sessionId is empty. Returns nil if there is no such active session.
+func (m *MemoryStorage) EndSession(ctx context.Context, streamerId string, sessionId string) (*StreamSession, error) {
+	var id primitive.ObjectID
+	if sessionId != "" {
+		var err error
+		if id, err = primitive.ObjectIDFromHex(sessionId); err != nil {
+			return nil, ErrInvalidSessionId
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	session := m.findSession(func(s *StreamSession) bool {
+		return s.StreamerId == streamerId && s.EndedAt == nil && (sessionId == "" || s.Id == id)
+	})
+	if session == nil {
+		return nil, nil
+	}
+
+	now := time.Now().UTC()
+	session.EndedAt = &now
+
+	result := *session
+	return &result, nil
+}
+
+// CreateRefreshToken also prunes expired tokens like PostgresStorage does.
+func (m *MemoryStorage) CreateRefreshToken(ctx context.Context, token RefreshToken) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	valid := m.refreshTokens[:0]
+	for _, existing := range m.refreshTokens {
+		if existing.Hash == token.Hash {
+			return ErrDuplicate
+		}
+		if existing.ExpiresAt.After(now) {
+			valid = append(valid, existing)
+		}
+	}
+
+	m.refreshTokens = append(valid, token)
+	return nil
+}
+
+// ConsumeRefreshToken deletes the token and returns it, nil if there is no such token or it has expired.
+func (m *MemoryStorage) ConsumeRefreshToken(ctx context.Context, hash string) (*RefreshToken, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, token := range m.refreshTokens {
+		if token.Hash == hash {
+			m.refreshTokens = append(m.refreshTokens[:i], m.refreshTokens[i+1:]...)
+			if !token.ExpiresAt.After(time.Now()) {
+				return nil, nil
+			}
+			return &token, nil
+		}
+	}
+	return nil, nil
+}
+
+// DeleteStreamerRefreshTokens signs the streamer out of every device.
+func (m *MemoryStorage) DeleteStreamerRefreshTokens(ctx context.Context, streamerId string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	kept := m.refreshTokens[:0]
+	for _, token := range m.refreshTokens {
+		if token.StreamerId != streamerId {
+			kept = append(kept, token)
+		}
+	}
+	m.refreshTokens = kept
+	return nil
+}
+
+// CreateNonce also prunes expired nonces like PostgresStorage does.
+func (m *MemoryStorage) CreateNonce(ctx context.Context, nonce Nonce) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := time.Now()
+	valid := m.nonces[:0]
+	for _, existing := range m.nonces {
+		if !existing.ExpiresAt.After(now) {
+			continue
+		} else if existing.Key == nonce.Key {
+			return ErrDuplicate
+		}
+		valid = append(valid, existing)
+	}
+
+	m.nonces = append(valid, nonce)
+	return nil
+}
+
+// ConsumeNonce deletes the nonce and returns it, nil if there is no such unexpired nonce.
+func (m *MemoryStorage) ConsumeNonce(ctx context.Context, key string) (*Nonce, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, nonce := range m.nonces {
+		if nonce.Key == key {
+			m.nonces = append(m.nonces[:i], m.nonces[i+1:]...)
+			if !nonce.ExpiresAt.After(time.Now()) {
+				return nil, nil
+			}
+			return &nonce, nil
+		}
+	}
+	return nil, nil
+}
+
+// SaveGrant creates the grant, saving an existing one is a no-op.
+func (m *MemoryStorage) SaveGrant(ctx context.Context, grant Grant) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, existing := range m.grants {
+		if existing.Role == grant.Role && existing.StreamerId == grant.StreamerId && existing.GranteeId == grant.GranteeId {
+			return nil
+		}
+	}
+
+	m.grants = append(m.grants, grant)
+	return nil
+}
+
+func (m *MemoryStorage) DeleteGrant(ctx context.Context, role string, streamerId string, granteeId string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for i, grant := range m.grants {
+		if grant.Role == role && grant.StreamerId == streamerId && grant.GranteeId == granteeId {
+			m.grants = append(m.grants[:i], m.grants[i+1:]...)
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// GetGranteeGrants returns every role of the account.
+func (m *MemoryStorage) GetGranteeGrants(ctx context.Context, granteeId string) ([]Grant, error) {
+	return m.matchGrants(func(g *Grant) bool { return g.GranteeId == granteeId }), nil
+}
+
+// GetStreamerGrants returns grants of the role scoped to the streamer, platform wide ones for empty streamerId.
+func (m *MemoryStorage) GetStreamerGrants(ctx context.Context, role string, streamerId string) ([]Grant, error) {
+	return m.matchGrants(func(g *Grant) bool { return g.Role == role && g.StreamerId == streamerId }), nil
+}
+
+func (m *MemoryStorage) matchGrants(match func(g *Grant) bool) []Grant {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	grants := []Grant{}
+	for i := range m.grants {
+		if match(&m.grants[i]) {
+			grants = append(grants, m.grants[i])
+		}
+	}
+	return grants
+}
@@ -0,0 +1,323 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
+)
+
+func TestMemorySaveDonation(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage()
+
+	err := memory.CreateDonation(ctx, Donation{Sign: "sign-1", StreamerId: "streamer-1", From: "bob", Message: "hi", Amount: 5})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := memory.CreateDonation(ctx, Donation{Sign: "sign-1", StreamerId: "streamer-1"}); !errors.Is(err, ErrDuplicate) {
+		t.Fatalf("expected ErrDuplicate for announced sign, got %v", err)
+	}
+
+	tx := func(hash string, sign string, amount uint64) Tx {
+		return Tx{Sign: sign, TxHash: hash, Amount: amount, Currency: CurrencyTON, WalletAddress: "wallet", CreatedAt: time.Now().UTC()}
+	}
+
+	tests := []struct {
+		name        string
+		tx          Tx
+		from        string
+		amount      uint64
+		duplicateOf string
+		donations   int
+	}{
+		{
+			name:      "transaction confirms announced donation",
+			tx:        tx("hash-1", "sign-1", 7),
+			from:      "bob",
+			amount:    7,
+			donations: 1,
+		},
+		{
+			name:      "same transaction again is a no-op",
+			tx:        tx("hash-1", "sign-1", 7),
+			from:      "bob",
+			amount:    7,
+			donations: 1,
+		},
+		{
+			name:        "another transaction with the claimed sign is kept separately",
+			tx:          tx("hash-2", "sign-1", 3),
+			amount:      3,
+			duplicateOf: "sign-1",
+			donations:   2,
+		},
+		{
+			name:        "duplicate transaction again is a no-op",
+			tx:          tx("hash-2", "sign-1", 3),
+			amount:      3,
+			duplicateOf: "sign-1",
+			donations:   2,
+		},
+		{
+			name:      "transaction without announced donation",
+			tx:        tx("hash-3", "sign-2", 4),
+			amount:    4,
+			donations: 3,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			donation, err := memory.SaveDonation(ctx, test.tx, "streamer-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if donation.TxHash != test.tx.TxHash || !donation.Verified || donation.StreamerId != "streamer-1" {
+				t.Fatalf("expected verified donation of %s, got %+v", test.tx.TxHash, donation)
+			}
+			if donation.From != test.from || donation.Amount != test.amount || donation.DuplicateOf != test.duplicateOf {
+				t.Fatalf("expected from %q, amount %d and duplicate of %q, got %+v", test.from, test.amount, test.duplicateOf, donation)
+			}
+			if test.duplicateOf != "" && donation.Sign != "" {
+				t.Fatalf("expected duplicate to be stored without sign, got %q", donation.Sign)
+			}
+
+			donations, err := memory.GetStreamerDonations(ctx, "streamer-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(*donations) != test.donations {
+				t.Fatalf("expected %d donations, got %d", test.donations, len(*donations))
+			}
+		})
+	}
+
+	// The sign still resolves to the donation it was announced with.
+	donation, err := memory.GetDonationBySign(ctx, "sign-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if donation == nil || donation.TxHash != "hash-1" {
+		t.Fatalf("expected sign to resolve to hash-1, got %+v", donation)
+	}
+}
+
+func TestMemoryAddToCurrentAmount(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage()
+	if err := memory.SaveStreamer(ctx, Streamer{StreamerId: "streamer-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	later := now.Add(time.Hour)
+	earlier := now.Add(-time.Hour)
+	create := func(widget Widget) string {
+		widget.StreamerId = "streamer-1"
+		widget.IsActive = true
+		created, err := memory.CreateWidget(ctx, widget)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return created.Id.Hex()
+	}
+
+	tonGoal := create(Widget{Type: widgets.TypeDonationGoal, Title: "ton", AmountGoal: 10})
+	usdGoal := create(Widget{Type: widgets.TypeDonationGoal, Title: "usd", AmountGoal: 500, Currency: "USD"})
+	scheduled := create(Widget{Type: widgets.TypeDonationGoal, Title: "scheduled", AmountGoal: 10, StartsAt: &later})
+	ended := create(Widget{Type: widgets.TypeDonationGoal, Title: "ended", AmountGoal: 10, EndsAt: &earlier})
+	counter := create(Widget{Type: widgets.TypeDonationCounter, Title: "counter"})
+
+	// Steps run in order against the same widgets.
+	tests := []struct {
+		name      string
+		amount    uint64
+		fiatCents map[string]uint64
+		completed []string
+		skipped   []string
+		// current are the expected amounts of widgets after the step.
+		current map[string]uint64
+	}{
+		{
+			name:      "donation is credited in goal currencies",
+			amount:    4,
+			fiatCents: map[string]uint64{"USD": 200},
+			current:   map[string]uint64{tonGoal: 4, usdGoal: 200, scheduled: 0, ended: 0, counter: 4},
+		},
+		{
+			name:      "ton goal is completed, fiat goal is skipped without rates",
+			amount:    6,
+			completed: []string{"ton"},
+			skipped:   []string{"usd"},
+			current:   map[string]uint64{tonGoal: 10, usdGoal: 200, scheduled: 0, ended: 0, counter: 10},
+		},
+		{
+			name:      "completed goal is not credited, fiat goal is completed",
+			amount:    1,
+			fiatCents: map[string]uint64{"USD": 300},
+			completed: []string{"usd"},
+			current:   map[string]uint64{tonGoal: 10, usdGoal: 500, scheduled: 0, ended: 0, counter: 11},
+		},
+		{
+			name:      "completed goals are not completed again",
+			amount:    5,
+			fiatCents: map[string]uint64{"USD": 250},
+			current:   map[string]uint64{tonGoal: 10, usdGoal: 500, scheduled: 0, ended: 0, counter: 16},
+		},
+	}
+
+	titles := func(goals []Widget) []string {
+		var titles []string
+		for _, goal := range goals {
+			titles = append(titles, goal.Title)
+		}
+		return titles
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			credit, err := memory.AddToCurrentAmount(ctx, "streamer-1", "", test.amount, test.fiatCents)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if !reflect.DeepEqual(titles(credit.Completed), test.completed) {
+				t.Fatalf("expected completed %v, got %v", test.completed, titles(credit.Completed))
+			}
+			if !reflect.DeepEqual(titles(credit.Skipped), test.skipped) {
+				t.Fatalf("expected skipped %v, got %v", test.skipped, titles(credit.Skipped))
+			}
+			for _, completed := range credit.Completed {
+				if !completed.IsCompleted || completed.CompletedAt == nil {
+					t.Fatalf("expected %s to be marked completed, got %+v", completed.Title, completed)
+				}
+			}
+
+			for widgetId, current := range test.current {
+				widget, err := memory.GetWidget(ctx, "streamer-1", widgetId)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if widget.AmountCurrent != current {
+					t.Fatalf("expected %s to have %d, got %d", widget.Title, current, widget.AmountCurrent)
+				}
+			}
+		})
+	}
+}
+
+func TestMemoryResetGoal(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage()
+	if err := memory.SaveStreamer(ctx, Streamer{StreamerId: "streamer-1"}); err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Now().UTC()
+	earlier := now.Add(-time.Hour)
+	later := now.Add(time.Hour)
+
+	tests := []struct {
+		name   string
+		endsAt *time.Time
+		reset  GoalReset
+		// endsAt of the new round, nil for none.
+		expected *time.Time
+	}{
+		{
+			name:   "passed end date is cleared",
+			endsAt: &earlier,
+		},
+		{
+			name:     "future end date is kept",
+			endsAt:   &later,
+			expected: &later,
+		},
+		{
+			name:     "new end date replaces passed one",
+			endsAt:   &earlier,
+			reset:    GoalReset{EndsAt: &later},
+			expected: &later,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			created, err := memory.CreateWidget(ctx, Widget{
+				StreamerId: "streamer-1",
+				Type:       widgets.TypeDonationGoal,
+				AmountGoal: 10,
+				IsActive:   true,
+				EndsAt:     test.endsAt,
+			})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, err := memory.AddToCurrentAmount(ctx, "streamer-1", created.Id.Hex(), 10, nil); err != nil {
+				t.Fatal(err)
+			}
+
+			widget, err := memory.ResetGoal(ctx, "streamer-1", created.Id.Hex(), test.reset)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if widget.AmountCurrent != 0 || widget.DonationsCount != 0 || widget.IsCompleted || widget.CompletedAt != nil {
+				t.Fatalf("expected goal to start over, got %+v", widget)
+			}
+			if len(widget.History) != 1 {
+				t.Fatalf("expected previous round in history, got %+v", widget.History)
+			}
+			if (widget.EndsAt == nil) != (test.expected == nil) || (widget.EndsAt != nil && !widget.EndsAt.Equal(*test.expected)) {
+				t.Fatalf("expected ends at %v, got %v", test.expected, widget.EndsAt)
+			}
+			if widget.GoalStatus(now) != GoalStatusRunning {
+				t.Fatalf("expected reset goal to be running, got %s", widget.GoalStatus(now))
+			}
+		})
+	}
+}
+
+func TestMemoryConsumeRefreshToken(t *testing.T) {
+	ctx := context.Background()
+	memory := NewMemoryStorage()
+
+	now := time.Now().UTC()
+	tokens := []RefreshToken{
+		{Hash: "valid", StreamerId: "streamer-1", CreatedAt: now, ExpiresAt: now.Add(time.Hour)},
+		{Hash: "expired", StreamerId: "streamer-1", CreatedAt: now.Add(-2 * time.Hour), ExpiresAt: now.Add(-time.Hour)},
+	}
+	for _, token := range tokens {
+		if err := memory.CreateRefreshToken(ctx, token); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		hash  string
+		found bool
+	}{
+		{name: "valid token", hash: "valid", found: true},
+		{name: "token is single use", hash: "valid"},
+		{name: "expired token", hash: "expired"},
+		{name: "unknown token", hash: "unknown"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			token, err := memory.ConsumeRefreshToken(ctx, test.hash)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (token != nil) != test.found {
+				t.Fatalf("expected found %t, got %+v", test.found, token)
+			}
+		})
+	}
+}
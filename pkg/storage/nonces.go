@@ -0,0 +1,51 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// Nonce is a single-use value, like a ton_proof payload issued to a client or an id of a received webhook.
+type Nonce struct {
+	// Key is hex SHA-256 of a secret value, or a namespaced id of a public one.
+	Key string `bson:"key"`
+	// Mongo removes expired nonces by TTL index, see Bootstrap.
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func (m *MongoStorage) CreateNonce(ctx context.Context, nonce Nonce) error {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_NONCES_COLLECTION_NAME")
+
+	_, err := m.client.Database(dbName).Collection(collectionName).InsertOne(ctx, nonce)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// ConsumeNonce deletes the nonce and returns it, nil if there is no such unexpired nonce.
+func (m *MongoStorage) ConsumeNonce(ctx context.Context, key string) (*Nonce, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_NONCES_COLLECTION_NAME")
+
+	// TTL monitor runs once a minute, expired nonces could still be there.
+	filter := bson.D{
+		{Key: "key", Value: key},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}}}
+	result := m.client.Database(dbName).Collection(collectionName).FindOneAndDelete(ctx, filter)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	var nonce Nonce
+	if err := result.Decode(&nonce); err != nil {
+		return nil, err
+	}
+
+	return &nonce, nil
+}
@@ -0,0 +1,1028 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+)
+
+// PostgresStorage implements repositories on top of schema from migrations directory, apply it with goose.
+type PostgresStorage struct {
+	pool *pgxpool.Pool
+}
+
+func NewPostgresClient(ctx context.Context) (*PostgresStorage, error) {
+	// Required by utils.ValidateEnvVariables for the postgres backend, there is no default.
+	connection := os.Getenv("POSTGRES_CONNECTION")
+	if connection == "" {
+		return nil, errors.New("POSTGRES_CONNECTION is not set")
+	}
+
+	pool, err := pgxpool.New(ctx, connection)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, err
+	}
+
+	return &PostgresStorage{pool: pool}, nil
+}
+
+// Repositories returns PostgresStorage as every repository.
+func (p *PostgresStorage) Repositories() Repositories {
+	return Repositories{
+		Streamers: p,
+		Donations: p,
+		Widgets:   p,
+		Sessions:  p,
+
+		RefreshTokens: p,
+		Grants:        p,
+		Nonces:        p,
+	}
+}
+
+const uniqueViolation = "23505"
+
+func isUniqueViolation(err error, constraint string) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation && pgErr.ConstraintName == constraint
+}
+
+// nullString stores empty strings as NULL, so that unique columns allow many empty values.
+func nullString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+func nullTime(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
+
+const streamerColumns = `streamer_id, cognito_id, coalesce(wallet_address, ''), coalesce(slug, ''),
+	display_name, avatar_url, description, twitch_user_id, twitch_login, twitch_display_name, twitch_linked_at,
+	disabled, disabled_reason`
+
+func (p *PostgresStorage) getStreamer(ctx context.Context, condition string, arg any) (*Streamer, error) {
+	var streamer Streamer
+	var twitchUserId, twitchLogin, twitchDisplayName *string
+	var twitchLinkedAt *time.Time
+
+	err := p.pool.QueryRow(ctx, "SELECT "+streamerColumns+" FROM streamers WHERE "+condition, arg).Scan(
+		&streamer.StreamerId, &streamer.CognitoId, &streamer.WalletAddress, &streamer.Slug,
+		&streamer.DisplayName, &streamer.AvatarUrl, &streamer.Description,
+		&twitchUserId, &twitchLogin, &twitchDisplayName, &twitchLinkedAt,
+		&streamer.Disabled, &streamer.DisabledReason)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	if twitchUserId != nil {
+		streamer.Twitch = &TwitchAccount{UserId: *twitchUserId}
+		if twitchLogin != nil {
+			streamer.Twitch.Login = *twitchLogin
+		}
+		if twitchDisplayName != nil {
+			streamer.Twitch.DisplayName = *twitchDisplayName
+		}
+		if twitchLinkedAt != nil {
+			streamer.Twitch.LinkedAt = twitchLinkedAt.UTC()
+		}
+	}
+
+	rows, err := p.pool.Query(ctx, `SELECT address, is_active, active_from, retired_at, periods
+		FROM streamer_wallets WHERE streamer_id = $1 ORDER BY position`, streamer.StreamerId)
+	if err != nil {
+		return nil, err
+	}
+
+	streamer.Wallets, err = pgx.CollectRows(rows, func(row pgx.CollectableRow) (StreamerWallet, error) {
+		var wallet StreamerWallet
+		err := row.Scan(&wallet.Address, &wallet.IsActive, &wallet.ActiveFrom, &wallet.RetiredAt, &wallet.Periods)
+		return wallet, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &streamer, nil
+}
+
+func (p *PostgresStorage) GetStreamerByCognitoId(ctx context.Context, cognitoId string) (*Streamer, error) {
+	return p.getStreamer(ctx, "cognito_id = $1", cognitoId)
+}
+
+func (p *PostgresStorage) GetStreamerByStreamerId(ctx context.Context, streamerId string) (*Streamer, error) {
+	return p.getStreamer(ctx, "streamer_id = $1", streamerId)
+}
+
+// GetStreamerByWalletAddress looks up the streamer by any of the wallets, including retired ones.
+func (p *PostgresStorage) GetStreamerByWalletAddress(ctx context.Context, walletAddress string) (*Streamer, error) {
+	return p.getStreamer(ctx,
+		"wallet_address = $1 OR streamer_id IN (SELECT streamer_id FROM streamer_wallets WHERE address = $1)",
+		walletAddress)
+}
+
+func (p *PostgresStorage) GetStreamerBySlug(ctx context.Context, slug string) (*Streamer, error) {
+	return p.getStreamer(ctx, "slug = $1", slug)
+}
+
+func (p *PostgresStorage) GetStreamerByTwitchUserId(ctx context.Context, twitchUserId string) (*Streamer, error) {
+	return p.getStreamer(ctx, "twitch_user_id = $1", twitchUserId)
+}
+
+// SaveStreamer upserts streamer profile by streamer id, wallets and linked accounts are kept.
+func (p *PostgresStorage) SaveStreamer(ctx context.Context, streamer Streamer) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO streamers
+		(streamer_id, cognito_id, wallet_address, slug, display_name, avatar_url, description)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)
+		ON CONFLICT (streamer_id) DO UPDATE SET
+			cognito_id = excluded.cognito_id,
+			wallet_address = excluded.wallet_address,
+			slug = excluded.slug,
+			display_name = excluded.display_name,
+			avatar_url = excluded.avatar_url,
+			description = excluded.description`,
+		streamer.StreamerId, streamer.CognitoId, nullString(streamer.WalletAddress), nullString(streamer.Slug),
+		streamer.DisplayName, streamer.AvatarUrl, streamer.Description)
+	if isUniqueViolation(err, "streamers_wallet_address_key") {
+		return ErrWalletAddressTaken
+	} else if isUniqueViolation(err, "streamers_slug_key") {
+		return ErrSlugTaken
+	}
+
+	return err
+}
+
+// AddStreamerWallet adds active payout wallet to the streamer or reactivates previously retired one.
+func (p *PostgresStorage) AddStreamerWallet(ctx context.Context, streamerId string, walletAddress string) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO streamer_wallets (streamer_id, address, is_active, active_from)
+		SELECT streamer_id, $2, TRUE, now() FROM streamers WHERE streamer_id = $1
+		ON CONFLICT (streamer_id, address) DO UPDATE SET
+			is_active = TRUE,
+			active_from = now(),
+			retired_at = NULL,
+			periods = streamer_wallets.periods || jsonb_build_array(jsonb_build_object(
+				'active_from', streamer_wallets.active_from, 'retired_at', streamer_wallets.retired_at))
+		WHERE NOT streamer_wallets.is_active`,
+		streamerId, walletAddress)
+	if isUniqueViolation(err, "streamer_wallets_address_key") {
+		return ErrWalletAddressTaken
+	}
+
+	return err
+}
+
+// RetireStreamerWallet stops accepting donations to the wallet but keeps it in streamer history.
+func (p *PostgresStorage) RetireStreamerWallet(ctx context.Context, streamerId string, walletAddress string) (bool, error) {
+	result, err := p.pool.Exec(ctx, `UPDATE streamer_wallets SET is_active = FALSE, retired_at = now()
+		WHERE streamer_id = $1 AND address = $2 AND is_active`,
+		streamerId, walletAddress)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// SetStreamerTwitch links Twitch channel to the streamer, nil account unlinks it.
+func (p *PostgresStorage) SetStreamerTwitch(ctx context.Context, streamerId string, account *TwitchAccount) error {
+	var userId, login, displayName *string
+	var linkedAt *time.Time
+	if account != nil {
+		userId, login, displayName = &account.UserId, &account.Login, &account.DisplayName
+		linkedAt = &account.LinkedAt
+	}
+
+	_, err := p.pool.Exec(ctx, `UPDATE streamers SET
+		twitch_user_id = $2, twitch_login = $3, twitch_display_name = $4, twitch_linked_at = $5
+		WHERE streamer_id = $1`,
+		streamerId, userId, login, displayName, linkedAt)
+	return err
+}
+
+// SetStreamerDisabled disables the account with a reason or enables it back, false when there is no such streamer.
+func (p *PostgresStorage) SetStreamerDisabled(ctx context.Context, streamerId string, disabled bool, reason string) (bool, error) {
+	if !disabled {
+		reason = ""
+	}
+
+	result, err := p.pool.Exec(ctx, "UPDATE streamers SET disabled = $2, disabled_reason = $3 WHERE streamer_id = $1",
+		streamerId, disabled, reason)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+const donationColumns = `coalesce(sign, ''), coalesce(tx_hash, ''), coalesce(streamer_id, ''), wallet_address, sender_address,
+	amount, currency, nickname, message, lt, verified, acked, fiat_rates, session_id, widget_id,
+	coalesce(duplicate_of, ''), created_at, moderation_status, moderation_reason, moderated_by, moderated_at`
+
+func scanDonation(row pgx.CollectableRow) (Donation, error) {
+	var donation Donation
+	var amount, lt int64
+	var createdAt, moderatedAt *time.Time
+	var moderationStatus *string
+	var moderation DonationModeration
+
+	err := row.Scan(
+		&donation.Sign, &donation.TxHash, &donation.StreamerId, &donation.WalletAddress, &donation.SenderAddress,
+		&amount, &donation.Currency, &donation.From, &donation.Message, &lt,
+		&donation.Verified, &donation.Acked, &donation.FiatRates, &donation.SessionId, &donation.WidgetId,
+		&donation.DuplicateOf, &createdAt, &moderationStatus, &moderation.Reason, &moderation.By, &moderatedAt)
+	if err != nil {
+		return donation, err
+	}
+
+	if moderationStatus != nil {
+		moderation.Status = *moderationStatus
+		if moderatedAt != nil {
+			moderation.At = moderatedAt.UTC()
+		}
+		donation.Moderation = &moderation
+	}
+
+	donation.Amount = uint64(amount)
+	donation.Lt = uint64(lt)
+	if createdAt != nil {
+		donation.CreatedAt = createdAt.UTC()
+	}
+
+	return donation, nil
+}
+
+func (p *PostgresStorage) queryDonations(ctx context.Context, query string, args ...any) (*[]Donation, error) {
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := pgx.CollectRows(rows, scanDonation)
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+func (p *PostgresStorage) GetStreamerDonations(ctx context.Context, streamerId string) (*[]Donation, error) {
+	return p.queryDonations(ctx, "SELECT "+donationColumns+" FROM donations WHERE streamer_id = $1 ORDER BY id", streamerId)
+}
+
+// GetPublicDonations returns the latest verified donations of a streamer, newest first.
+func (p *PostgresStorage) GetPublicDonations(ctx context.Context, streamerId string, limit int64) (*[]Donation, error) {
+	return p.queryDonations(ctx, "SELECT "+donationColumns+` FROM donations
+		WHERE streamer_id = $1 AND verified AND moderation_status IS DISTINCT FROM 'hidden'
+		ORDER BY lt DESC LIMIT $2`, streamerId, limit)
+}
+
+func (p *PostgresStorage) GetDonationBySign(ctx context.Context, sign string) (*Donation, error) {
+	return p.queryDonation(ctx, "SELECT "+donationColumns+" FROM donations WHERE sign = $1", sign)
+}
+
+func (p *PostgresStorage) GetDonationByTxHash(ctx context.Context, txHash string) (*Donation, error) {
+	return p.queryDonation(ctx, "SELECT "+donationColumns+" FROM donations WHERE tx_hash = $1", txHash)
+}
+
+func (p *PostgresStorage) queryDonation(ctx context.Context, query string, args ...any) (*Donation, error) {
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	donation, err := pgx.CollectOneRow(rows, scanDonation)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &donation, nil
+}
+
+func (p *PostgresStorage) CreateDonation(ctx context.Context, donation Donation) error {
+	currency := donation.Currency
+	if currency == "" {
+		currency = CurrencyTON
+	}
+
+	// Untyped nil is stored as NULL, nil map would be encoded as JSON null.
+	var fiatRates any
+	if len(donation.FiatRates) > 0 {
+		fiatRates = donation.FiatRates
+	}
+
+	_, err := p.pool.Exec(ctx, `INSERT INTO donations
+		(sign, tx_hash, streamer_id, wallet_address, sender_address, amount, currency, nickname, message,
+		lt, verified, acked, fiat_rates, session_id, widget_id, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16)`,
+		donation.Sign, nullString(donation.TxHash), nullString(donation.StreamerId), donation.WalletAddress,
+		donation.SenderAddress, int64(donation.Amount), currency, donation.From, donation.Message,
+		int64(donation.Lt), donation.Verified, donation.Acked, fiatRates, donation.SessionId, donation.WidgetId,
+		nullTime(donation.CreatedAt))
+	if isUniqueViolation(err, "donations_sign_key") {
+		return ErrDuplicate
+	}
+	return err
+}
+
+func (p *PostgresStorage) SaveDonation(ctx context.Context, transaction Tx, streamerId string) (*Donation, error) {
+	byTxHash := "SELECT " + donationColumns + " FROM donations WHERE tx_hash = $1"
+	saved, err := p.queryDonation(ctx, byTxHash, transaction.TxHash)
+	if err != nil || saved != nil {
+		return saved, err
+	}
+
+	currency := transaction.Currency
+	if currency == "" {
+		currency = CurrencyTON
+	}
+	args := []any{transaction.Sign, nullString(transaction.TxHash), nullString(streamerId), transaction.WalletAddress,
+		int64(transaction.Amount), transaction.SenderAddress, currency, int64(transaction.Lt),
+		nullTime(transaction.CreatedAt)}
+
+	// Claim donation announced with the sign, or create it when the announcement is missing.
+	// Nothing is returned when the sign is already claimed by another transaction.
+	donation, err := p.queryDonation(ctx, `INSERT INTO donations
+		(sign, tx_hash, streamer_id, wallet_address, amount, sender_address, currency, lt, verified, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, TRUE, $9)
+		ON CONFLICT (sign) DO UPDATE SET
+			tx_hash = excluded.tx_hash,
+			streamer_id = excluded.streamer_id,
+			wallet_address = excluded.wallet_address,
+			amount = excluded.amount,
+			sender_address = excluded.sender_address,
+			currency = excluded.currency,
+			lt = excluded.lt,
+			verified = TRUE
+		WHERE donations.tx_hash IS NULL
+		RETURNING `+donationColumns, args...)
+	if donation == nil && err == nil {
+		donation, err = p.queryDonation(ctx, `INSERT INTO donations
+			(duplicate_of, tx_hash, streamer_id, wallet_address, amount, sender_address, currency, lt, verified, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, TRUE, $9)
+			RETURNING `+donationColumns, args...)
+	}
+	if isUniqueViolation(err, "donations_tx_hash_key") {
+		// Transaction was saved concurrently.
+		return savedConcurrently(p.queryDonation(ctx, byTxHash, transaction.TxHash))
+	}
+
+	return donation, err
+}
+
+func (p *PostgresStorage) AckDonation(ctx context.Context, transaction Tx, ack DonationAck) error {
+	var fiatRates any
+	if len(ack.FiatRates) > 0 {
+		fiatRates = ack.FiatRates
+	}
+
+	_, err := p.pool.Exec(ctx, `UPDATE donations SET
+			acked = TRUE,
+			fiat_rates = coalesce($2, fiat_rates),
+			session_id = CASE WHEN $3 <> '' THEN $3 ELSE session_id END
+		WHERE tx_hash = $1`,
+		transaction.TxHash, fiatRates, ack.SessionId)
+	return err
+}
+
+// sqlClauses collects SQL clauses with numbered placeholders and their arguments.
+type sqlClauses struct {
+	clauses []string
+	args    []any
+}
+
+func (c *sqlClauses) add(clause string, arg any) {
+	c.args = append(c.args, arg)
+	c.clauses = append(c.clauses, fmt.Sprintf(clause, len(c.args)))
+}
+
+func (c *sqlClauses) join(sep string) string {
+	return strings.Join(c.clauses, sep)
+}
+
+// donationsWhere matches donations of the streamer made in the optional session and range.
+func donationsWhere(streamerId string, sessionId string, from *time.Time, to *time.Time) *sqlClauses {
+	where := &sqlClauses{}
+	where.add("streamer_id = $%d", streamerId)
+	if sessionId != "" {
+		where.add("session_id = $%d", sessionId)
+	}
+	if from != nil {
+		where.add("created_at >= $%d", *from)
+	}
+	if to != nil {
+		where.add("created_at < $%d", *to)
+	}
+	return where
+}
+
+// GetModerationQueue returns verified donations with a message which were not moderated yet, newest first.
+func (p *PostgresStorage) GetModerationQueue(ctx context.Context, streamerId string, limit int64) (*[]Donation, error) {
+	return p.queryDonations(ctx, "SELECT "+donationColumns+` FROM donations
+		WHERE streamer_id = $1 AND verified AND message <> '' AND moderation_status IS NULL
+		ORDER BY lt DESC LIMIT $2`, streamerId, limit)
+}
+
+// ModerateDonation sets moderation of the donation, any streamer's when streamerId is empty. Returns nil if there is no such donation.
+func (p *PostgresStorage) ModerateDonation(ctx context.Context, streamerId string, txHash string, moderation DonationModeration) (*Donation, error) {
+	return p.queryDonation(ctx, `UPDATE donations SET
+		moderation_status = $3, moderation_reason = $4, moderated_by = $5, moderated_at = $6
+		WHERE tx_hash = $1 AND ($2 = '' OR streamer_id = $2)
+		RETURNING `+donationColumns,
+		txHash, streamerId, moderation.Status, moderation.Reason, moderation.By, moderation.At)
+}
+
+// GetTopDonors ranks donors of verified donations which are not hidden, sums are grouped in SQL and ranked like in Mongo.
+func (p *PostgresStorage) GetTopDonors(ctx context.Context, query TopDonorsQuery) (*TopDonors, error) {
+	currency := query.Currency
+	if currency == "" {
+		currency = CurrencyTON
+	}
+
+	donorKey := "nickname"
+	if query.GroupBy == DonorByWallet {
+		donorKey = "sender_address"
+	}
+
+	where := donationsWhere(query.StreamerId, query.SessionId, query.From, query.To)
+	rows, err := p.pool.Query(ctx, "SELECT "+donorKey+`, currency, sum(amount)::bigint, count(*)
+		FROM donations WHERE verified AND moderation_status IS DISTINCT FROM 'hidden' AND `+where.join(" AND ")+" GROUP BY 1, 2", where.args...)
+	if err != nil {
+		return nil, err
+	}
+
+	amounts, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (donorAmount, error) {
+		var amount donorAmount
+		var sum, count int64
+		err := row.Scan(&amount.Donor, &amount.Currency, &sum, &count)
+		amount.Amount, amount.Count = uint64(sum), uint64(count)
+		return amount, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return rankTopDonors(amounts, currency, query.Limit), nil
+}
+
+// GetAnalytics aggregates streamer donations into time buckets in the streamer timezone, weeks start on monday.
+func (p *PostgresStorage) GetAnalytics(ctx context.Context, query AnalyticsQuery) (*Analytics, error) {
+	where := donationsWhere(query.StreamerId, query.SessionId, query.From, query.To)
+	where.add("currency = $%d", query.Currency)
+	switch query.Status {
+	case DonationStatusPending:
+		where.clauses = append(where.clauses, "NOT verified")
+	case DonationStatusVerified:
+		where.clauses = append(where.clauses, "verified AND NOT acked")
+	case DonationStatusAcked:
+		where.clauses = append(where.clauses, "acked")
+	default:
+		where.clauses = append(where.clauses, "verified")
+	}
+
+	stats := `count(*), coalesce(sum(amount), 0)::bigint,
+		count(DISTINCT coalesce(nullif(sender_address, ''), nullif(nickname, '')))`
+
+	var summary AnalyticsStats
+	err := p.pool.QueryRow(ctx, "SELECT "+stats+" FROM donations WHERE "+where.join(" AND "), where.args...).
+		Scan(&summary.Count, &summary.Sum, &summary.UniqueDonors)
+	if err != nil {
+		return nil, err
+	}
+
+	// Truncating local time and converting it back gives bucket start as an instant.
+	args := append(where.args, query.Interval, query.Timezone)
+	bucket := fmt.Sprintf("date_trunc($%d, created_at AT TIME ZONE $%d) AT TIME ZONE $%d", len(args)-1, len(args), len(args))
+	rows, err := p.pool.Query(ctx, "SELECT "+bucket+", "+stats+" FROM donations WHERE "+where.join(" AND ")+
+		" GROUP BY 1 ORDER BY 1", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	buckets, err := pgx.CollectRows(rows, func(row pgx.CollectableRow) (AnalyticsBucket, error) {
+		var bucket AnalyticsBucket
+		err := row.Scan(&bucket.Start, &bucket.Count, &bucket.Sum, &bucket.UniqueDonors)
+		bucket.Start = bucket.Start.UTC()
+		bucket.Average = average(bucket.AnalyticsStats)
+		return bucket, err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	summary.Average = average(summary)
+	return &Analytics{Summary: summary, Buckets: buckets}, nil
+}
+
+const widgetColumns = `id, streamer_id, type, title, amount_goal, amount_current, donations_count, is_active,
+	currency, config, starts_at, ends_at, started_at, is_completed, completed_at, is_archived, history`
+
+func scanWidget(row pgx.CollectableRow) (Widget, error) {
+	var widget Widget
+	var id string
+	var amountGoal, amountCurrent, donationsCount int64
+
+	err := row.Scan(&id, &widget.StreamerId, &widget.Type, &widget.Title,
+		&amountGoal, &amountCurrent, &donationsCount, &widget.IsActive,
+		&widget.Currency, &widget.Config, &widget.StartsAt, &widget.EndsAt, &widget.StartedAt,
+		&widget.IsCompleted, &widget.CompletedAt, &widget.IsArchived, &widget.History)
+	if err != nil {
+		return widget, err
+	}
+
+	widget.Id, err = primitive.ObjectIDFromHex(id)
+	widget.AmountGoal = uint64(amountGoal)
+	widget.AmountCurrent = uint64(amountCurrent)
+	widget.DonationsCount = uint64(donationsCount)
+
+	return widget, err
+}
+
+func (p *PostgresStorage) queryWidget(ctx context.Context, query string, args ...any) (*Widget, error) {
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	widget, err := pgx.CollectOneRow(rows, scanWidget)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &widget, nil
+}
+
+// GetWidgets returns streamer widgets, archived goals are returned only when asked for.
+func (p *PostgresStorage) GetWidgets(ctx context.Context, streamerId string, archived bool) (*[]Widget, error) {
+	rows, err := p.pool.Query(ctx, "SELECT "+widgetColumns+` FROM widgets
+		WHERE streamer_id = $1 AND is_archived = $2 ORDER BY created_at LIMIT 100`, streamerId, archived)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := pgx.CollectRows(rows, scanWidget)
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// GetActiveGoalWidget returns the active donation goal widget, or nil if the streamer has none.
+func (p *PostgresStorage) GetActiveGoalWidget(ctx context.Context, streamerId string) (*Widget, error) {
+	return p.queryWidget(ctx, "SELECT "+widgetColumns+` FROM widgets
+		WHERE streamer_id = $1 AND type = $2 AND is_active AND NOT is_archived
+		ORDER BY created_at LIMIT 1`, streamerId, widgets.TypeDonationGoal)
+}
+
+// GetWidget returns streamer's widget by id, widgets of other streamers are not found.
+func (p *PostgresStorage) GetWidget(ctx context.Context, streamerId string, widgetId string) (*Widget, error) {
+	if !primitive.IsValidObjectID(widgetId) {
+		return nil, ErrInvalidWidgetId
+	}
+
+	return p.queryWidget(ctx, "SELECT "+widgetColumns+" FROM widgets WHERE id = $1 AND streamer_id = $2",
+		widgetId, streamerId)
+}
+
+// CreateWidget stores new widget of existing streamer and returns it with the assigned id.
+func (p *PostgresStorage) CreateWidget(ctx context.Context, widget Widget) (*Widget, error) {
+	widget.Id = primitive.NewObjectID()
+	history := widget.History
+	if history == nil {
+		history = []GoalRecord{}
+	}
+
+	_, err := p.pool.Exec(ctx, `INSERT INTO widgets
+		(id, streamer_id, type, title, amount_goal, amount_current, donations_count, is_active, currency, config,
+		starts_at, ends_at, started_at, is_completed, completed_at, is_archived, history)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $15, $16, $17)`,
+		widget.Id.Hex(), widget.StreamerId, widget.Type, widget.Title,
+		int64(widget.AmountGoal), int64(widget.AmountCurrent), int64(widget.DonationsCount), widget.IsActive,
+		widget.Currency, widget.Config, widget.StartsAt, widget.EndsAt, widget.StartedAt,
+		widget.IsCompleted, widget.CompletedAt, widget.IsArchived, history)
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == "23503" {
+		return nil, errors.New("Streamer does not exist.")
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &widget, nil
+}
+
+// UpdateWidget applies the update to streamer's widget and returns the updated one, nil if there is no such widget.
+func (p *PostgresStorage) UpdateWidget(ctx context.Context, streamerId string, widgetId string, update WidgetUpdate) (*Widget, bool, error) {
+	if !primitive.IsValidObjectID(widgetId) {
+		return nil, false, ErrInvalidWidgetId
+	}
+
+	set := &sqlClauses{args: []any{widgetId, streamerId}}
+	if update.Title != nil {
+		set.add("title = $%d", *update.Title)
+	}
+	if update.AmountGoal != nil {
+		set.add(`amount_goal = $%[1]d,
+			is_completed = $%[1]d > 0 AND amount_current >= $%[1]d,
+			completed_at = CASE WHEN $%[1]d > 0 AND amount_current >= $%[1]d THEN coalesce(completed_at, now()) END`,
+			int64(*update.AmountGoal))
+	}
+	if update.IsActive != nil {
+		set.add("is_active = $%d", *update.IsActive)
+	}
+	if update.Config != nil {
+		set.add("config = $%d", *update.Config)
+	}
+	if update.StartsAt != nil {
+		set.add("starts_at = $%d", *update.StartsAt)
+	}
+	if update.EndsAt != nil {
+		set.add("ends_at = $%d", *update.EndsAt)
+	}
+	if len(set.clauses) == 0 {
+		widget, err := p.GetWidget(ctx, streamerId, widgetId)
+		return widget, false, err
+	}
+
+	query := "UPDATE widgets SET " + set.join(", ") + " WHERE id = $1 AND streamer_id = $2 RETURNING " + widgetColumns
+	if update.AmountGoal == nil {
+		widget, err := p.queryWidget(ctx, query, set.args...)
+		return widget, false, err
+	}
+
+	// The goal is locked to tell whether this update completed it rather than a concurrent donation.
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, false, err
+	}
+	defer tx.Rollback(ctx)
+
+	var wasCompleted bool
+	err = tx.QueryRow(ctx, "SELECT is_completed FROM widgets WHERE id = $1 AND streamer_id = $2 FOR UPDATE",
+		widgetId, streamerId).Scan(&wasCompleted)
+	if err == pgx.ErrNoRows {
+		return nil, false, nil
+	} else if err != nil {
+		return nil, false, err
+	}
+
+	rows, err := tx.Query(ctx, query, set.args...)
+	if err != nil {
+		return nil, false, err
+	}
+
+	widget, err := pgx.CollectOneRow(rows, scanWidget)
+	if err != nil {
+		return nil, false, err
+	}
+
+	return &widget, !wasCompleted && widget.IsCompleted, tx.Commit(ctx)
+}
+
+// DeleteWidget removes streamer's widget, returns false if there is no such widget.
+func (p *PostgresStorage) DeleteWidget(ctx context.Context, streamerId string, widgetId string) (bool, error) {
+	if !primitive.IsValidObjectID(widgetId) {
+		return false, ErrInvalidWidgetId
+	}
+
+	result, err := p.pool.Exec(ctx, "DELETE FROM widgets WHERE id = $1 AND streamer_id = $2", widgetId, streamerId)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// AddToCurrentAmount credits donation to streamer's running goal widgets, see MongoStorage.AddToCurrentAmount.
+// Goals are locked for the transaction, so concurrent donations can't both complete the same goal.
+func (p *PostgresStorage) AddToCurrentAmount(ctx context.Context, streamerId string, widgetId string, donatedAmount uint64, fiatCents map[string]uint64) (*GoalCredit, error) {
+	if widgetId != "" && !primitive.IsValidObjectID(widgetId) {
+		return nil, ErrInvalidWidgetId
+	}
+
+	tx, err := p.pool.Begin(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `UPDATE widgets SET amount_current = amount_current + $3, donations_count = donations_count + 1
+		WHERE streamer_id = $1 AND type = $2 AND is_active`,
+		streamerId, widgets.TypeDonationCounter, int64(donatedAmount))
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now().UTC()
+	rows, err := tx.Query(ctx, "SELECT "+widgetColumns+` FROM widgets
+		WHERE streamer_id = $1 AND type = $2 AND is_active AND NOT is_archived AND NOT is_completed
+			AND (starts_at IS NULL OR starts_at <= $3) AND (ends_at IS NULL OR ends_at > $3)
+			AND ($4 = '' OR id = $4)
+		FOR UPDATE`,
+		streamerId, widgets.TypeDonationGoal, now, widgetId)
+	if err != nil {
+		return nil, err
+	}
+
+	goals, err := pgx.CollectRows(rows, scanWidget)
+	if err != nil {
+		return nil, err
+	}
+
+	credit := &GoalCredit{}
+	for _, goal := range goals {
+		amount, ok := goal.CreditAmount(donatedAmount, fiatCents)
+		if !ok {
+			credit.Skipped = append(credit.Skipped, goal)
+			continue
+		}
+
+		goal.AmountCurrent += amount
+		goal.DonationsCount++
+		if goal.AmountGoal > 0 && goal.AmountCurrent >= goal.AmountGoal && goal.AmountCurrent-amount < goal.AmountGoal {
+			goal.IsCompleted = true
+			goal.CompletedAt = &now
+			credit.Completed = append(credit.Completed, goal)
+		}
+
+		_, err = tx.Exec(ctx, `UPDATE widgets SET amount_current = $2, donations_count = $3,
+			is_completed = $4, completed_at = $5 WHERE id = $1`,
+			goal.Id.Hex(), int64(goal.AmountCurrent), int64(goal.DonationsCount), goal.IsCompleted, goal.CompletedAt)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return credit, tx.Commit(ctx)
+}
+
+// goalHistoryAppendSQL appends current round to the history, values on the right of SET are the ones before update.
+const goalHistoryAppendSQL = `history = history || jsonb_build_array(jsonb_build_object(
+	'amount_goal', amount_goal,
+	'amount_final', amount_current,
+	'donations_count', donations_count,
+	'is_completed', is_completed,
+	'started_at', started_at,
+	'ended_at', $3::timestamptz))`
+
+// ResetGoal moves current goal round into history and starts a new one from zero.
+func (p *PostgresStorage) ResetGoal(ctx context.Context, streamerId string, widgetId string, reset GoalReset) (*Widget, error) {
+	var amountGoal *int64
+	if reset.AmountGoal != nil {
+		value := int64(*reset.AmountGoal)
+		amountGoal = &value
+	}
+
+	return p.updateGoal(ctx, streamerId, widgetId, goalHistoryAppendSQL+`,
+		amount_current = 0,
+		donations_count = 0,
+		is_completed = FALSE,
+		completed_at = NULL,
+		started_at = $3,
+		amount_goal = coalesce($5, amount_goal),
+		starts_at = coalesce($6, starts_at),
+		ends_at = coalesce($7, CASE WHEN ends_at > $3 THEN ends_at END)`,
+		amountGoal, reset.StartsAt, reset.EndsAt)
+}
+
+// ArchiveGoal moves current goal round into history and deactivates the widget for good.
+func (p *PostgresStorage) ArchiveGoal(ctx context.Context, streamerId string, widgetId string) (*Widget, error) {
+	return p.updateGoal(ctx, streamerId, widgetId, goalHistoryAppendSQL+`,
+		is_archived = TRUE,
+		is_active = FALSE`)
+}
+
+// updateGoal applies set clause to the goal, it may use $3 as current time and further placeholders from $5.
+func (p *PostgresStorage) updateGoal(ctx context.Context, streamerId string, widgetId string, set string, args ...any) (*Widget, error) {
+	if !primitive.IsValidObjectID(widgetId) {
+		return nil, ErrInvalidWidgetId
+	}
+
+	args = append([]any{widgetId, streamerId, time.Now().UTC(), widgets.TypeDonationGoal}, args...)
+
+	return p.queryWidget(ctx, "UPDATE widgets SET "+set+
+		" WHERE id = $1 AND streamer_id = $2 AND type = $4 AND NOT is_archived RETURNING "+widgetColumns, args...)
+}
+
+const sessionColumns = "id, streamer_id, title, source, external_id, started_at, ended_at"
+
+func scanSession(row pgx.CollectableRow) (StreamSession, error) {
+	var session StreamSession
+	var id string
+
+	err := row.Scan(&id, &session.StreamerId, &session.Title, &session.Source, &session.ExternalId,
+		&session.StartedAt, &session.EndedAt)
+	if err != nil {
+		return session, err
+	}
+
+	session.Id, err = primitive.ObjectIDFromHex(id)
+	session.StartedAt = session.StartedAt.UTC()
+	return session, err
+}
+
+func (p *PostgresStorage) querySession(ctx context.Context, query string, args ...any) (*StreamSession, error) {
+	rows, err := p.pool.Query(ctx, query, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := pgx.CollectOneRow(rows, scanSession)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// StartSession creates new active session.
+func (p *PostgresStorage) StartSession(ctx context.Context, session StreamSession) (*StreamSession, error) {
+	session.Id = primitive.NewObjectID()
+
+	_, err := p.pool.Exec(ctx, `INSERT INTO sessions (id, streamer_id, title, source, external_id, started_at, ended_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+		session.Id.Hex(), session.StreamerId, session.Title, session.Source, session.ExternalId,
+		session.StartedAt, session.EndedAt)
+	if isUniqueViolation(err, "sessions_streamer_active_key") {
+		return nil, ErrSessionActive
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// GetActiveSession returns session which is not ended yet, or nil.
+func (p *PostgresStorage) GetActiveSession(ctx context.Context, streamerId string) (*StreamSession, error) {
+	return p.querySession(ctx, "SELECT "+sessionColumns+` FROM sessions
+		WHERE streamer_id = $1 AND ended_at IS NULL ORDER BY started_at DESC LIMIT 1`, streamerId)
+}
+
+func (p *PostgresStorage) GetSession(ctx context.Context, streamerId string, sessionId string) (*StreamSession, error) {
+	if !primitive.IsValidObjectID(sessionId) {
+		return nil, ErrInvalidSessionId
+	}
+
+	return p.querySession(ctx, "SELECT "+sessionColumns+" FROM sessions WHERE id = $1 AND streamer_id = $2",
+		sessionId, streamerId)
+}
+
+// GetSessions returns streamer sessions, newest first.
+func (p *PostgresStorage) GetSessions(ctx context.Context, streamerId string, limit int64) (*[]StreamSession, error) {
+	rows, err := p.pool.Query(ctx, "SELECT "+sessionColumns+` FROM sessions
+		WHERE streamer_id = $1 ORDER BY started_at DESC LIMIT $2`, streamerId, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	results, err := pgx.CollectRows(rows, scanSession)
+	if err != nil {
+		return nil, err
+	}
+
+	return &results, nil
+}
+
+// EndSession ends the session, or the active one when sessionId is empty. Returns nil if there is no such active session.
+func (p *PostgresStorage) EndSession(ctx context.Context, streamerId string, sessionId string) (*StreamSession, error) {
+	if sessionId != "" && !primitive.IsValidObjectID(sessionId) {
+		return nil, ErrInvalidSessionId
+	}
+
+	return p.querySession(ctx, `UPDATE sessions SET ended_at = now()
+		WHERE streamer_id = $1 AND ended_at IS NULL AND ($2 = '' OR id = $2)
+		RETURNING `+sessionColumns, streamerId, sessionId)
+}
+
+// CreateRefreshToken also prunes expired tokens, Postgres has no TTL to drop them.
+func (p *PostgresStorage) CreateRefreshToken(ctx context.Context, token RefreshToken) error {
+	if _, err := p.pool.Exec(ctx, "DELETE FROM refresh_tokens WHERE expires_at < now()"); err != nil {
+		return err
+	}
+
+	_, err := p.pool.Exec(ctx, `INSERT INTO refresh_tokens (hash, streamer_id, wallet_address, created_at, expires_at)
+		VALUES ($1, $2, $3, $4, $5)`,
+		token.Hash, token.StreamerId, token.WalletAddress, token.CreatedAt, token.ExpiresAt)
+	if isUniqueViolation(err, "refresh_tokens_pkey") {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// ConsumeRefreshToken deletes the token and returns it, nil if there is no such token or it has expired.
+func (p *PostgresStorage) ConsumeRefreshToken(ctx context.Context, hash string) (*RefreshToken, error) {
+	var token RefreshToken
+	err := p.pool.QueryRow(ctx, `DELETE FROM refresh_tokens WHERE hash = $1 AND expires_at > now()
+		RETURNING hash, streamer_id, wallet_address, created_at, expires_at`, hash).
+		Scan(&token.Hash, &token.StreamerId, &token.WalletAddress, &token.CreatedAt, &token.ExpiresAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// DeleteStreamerRefreshTokens signs the streamer out of every device.
+func (p *PostgresStorage) DeleteStreamerRefreshTokens(ctx context.Context, streamerId string) error {
+	_, err := p.pool.Exec(ctx, "DELETE FROM refresh_tokens WHERE streamer_id = $1", streamerId)
+	return err
+}
+
+// CreateNonce also prunes expired nonces, Postgres has no TTL to drop them.
+func (p *PostgresStorage) CreateNonce(ctx context.Context, nonce Nonce) error {
+	if _, err := p.pool.Exec(ctx, "DELETE FROM nonces WHERE expires_at < now()"); err != nil {
+		return err
+	}
+
+	_, err := p.pool.Exec(ctx, "INSERT INTO nonces (key, expires_at) VALUES ($1, $2)", nonce.Key, nonce.ExpiresAt)
+	if isUniqueViolation(err, "nonces_pkey") {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// ConsumeNonce deletes the nonce and returns it, nil if there is no such unexpired nonce.
+func (p *PostgresStorage) ConsumeNonce(ctx context.Context, key string) (*Nonce, error) {
+	var nonce Nonce
+	err := p.pool.QueryRow(ctx, `DELETE FROM nonces WHERE key = $1 AND expires_at > now()
+		RETURNING key, expires_at`, key).
+		Scan(&nonce.Key, &nonce.ExpiresAt)
+	if err == pgx.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &nonce, nil
+}
+
+// SaveGrant creates the grant, saving an existing one is a no-op.
+func (p *PostgresStorage) SaveGrant(ctx context.Context, grant Grant) error {
+	_, err := p.pool.Exec(ctx, `INSERT INTO grants (role, streamer_id, grantee_id, granted_by, created_at)
+		VALUES ($1, $2, $3, $4, $5) ON CONFLICT DO NOTHING`,
+		grant.Role, grant.StreamerId, grant.GranteeId, grant.GrantedBy, grant.CreatedAt)
+	return err
+}
+
+func (p *PostgresStorage) DeleteGrant(ctx context.Context, role string, streamerId string, granteeId string) (bool, error) {
+	result, err := p.pool.Exec(ctx, "DELETE FROM grants WHERE role = $1 AND streamer_id = $2 AND grantee_id = $3",
+		role, streamerId, granteeId)
+	if err != nil {
+		return false, err
+	}
+
+	return result.RowsAffected() > 0, nil
+}
+
+// GetGranteeGrants returns every role of the account.
+func (p *PostgresStorage) GetGranteeGrants(ctx context.Context, granteeId string) ([]Grant, error) {
+	return p.queryGrants(ctx, "grantee_id = $1", granteeId)
+}
+
+// GetStreamerGrants returns grants of the role scoped to the streamer, platform wide ones for empty streamerId.
+func (p *PostgresStorage) GetStreamerGrants(ctx context.Context, role string, streamerId string) ([]Grant, error) {
+	return p.queryGrants(ctx, "role = $1 AND streamer_id = $2", role, streamerId)
+}
+
+func (p *PostgresStorage) queryGrants(ctx context.Context, condition string, args ...any) ([]Grant, error) {
+	rows, err := p.pool.Query(ctx, `SELECT role, streamer_id, grantee_id, granted_by, created_at
+		FROM grants WHERE `+condition+" ORDER BY created_at", args...)
+	if err != nil {
+		return nil, err
+	}
+
+	return pgx.CollectRows(rows, func(row pgx.CollectableRow) (Grant, error) {
+		var grant Grant
+		err := row.Scan(&grant.Role, &grant.StreamerId, &grant.GranteeId, &grant.GrantedBy, &grant.CreatedAt)
+		return grant, err
+	})
+}
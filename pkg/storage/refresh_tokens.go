@@ -0,0 +1,63 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/mongo"
+)
+
+// RefreshToken lets a streamer signed in with a TON wallet get new access tokens without signing again.
+type RefreshToken struct {
+	// Hash is hex SHA-256 of the token, the token itself is only known to the client.
+	Hash          string    `bson:"hash"`
+	StreamerId    string    `bson:"streamer_id"`
+	WalletAddress string    `bson:"wallet_address"`
+	CreatedAt     time.Time `bson:"created_at"`
+	// Mongo removes expired tokens by TTL index, see Bootstrap.
+	ExpiresAt time.Time `bson:"expires_at"`
+}
+
+func (m *MongoStorage) CreateRefreshToken(ctx context.Context, token RefreshToken) error {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_REFRESH_TOKENS_COLLECTION_NAME")
+
+	_, err := m.client.Database(dbName).Collection(collectionName).InsertOne(ctx, token)
+	if mongo.IsDuplicateKeyError(err) {
+		return ErrDuplicate
+	}
+	return err
+}
+
+// ConsumeRefreshToken deletes the token and returns it, nil if there is no such token or it has expired.
+func (m *MongoStorage) ConsumeRefreshToken(ctx context.Context, hash string) (*RefreshToken, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_REFRESH_TOKENS_COLLECTION_NAME")
+
+	// TTL monitor runs once a minute, expired tokens could still be there.
+	filter := bson.D{
+		{Key: "hash", Value: hash},
+		{Key: "expires_at", Value: bson.D{{Key: "$gt", Value: time.Now()}}}}
+	result := m.client.Database(dbName).Collection(collectionName).FindOneAndDelete(ctx, filter)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	var token RefreshToken
+	if err := result.Decode(&token); err != nil {
+		return nil, err
+	}
+
+	return &token, nil
+}
+
+// DeleteStreamerRefreshTokens signs the streamer out of every device.
+func (m *MongoStorage) DeleteStreamerRefreshTokens(ctx context.Context, streamerId string) error {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_REFRESH_TOKENS_COLLECTION_NAME")
+
+	_, err := m.client.Database(dbName).Collection(collectionName).DeleteMany(ctx, bson.D{{Key: "streamer_id", Value: streamerId}})
+	return err
+}
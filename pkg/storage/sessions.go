@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+	"time"
+
+	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
+	"go.mongodb.org/mongo-driver/mongo"
+	"go.mongodb.org/mongo-driver/mongo/options"
+)
+
+var ErrInvalidSessionId = errors.New("Invalid session id.")
+
+var ErrSessionActive = errors.New("Stream session is already active.")
+
+// Stream session sources.
+const (
+	SessionSourceManual = "manual"
+	SessionSourceTwitch = "twitch"
+)
+
+// StreamSession is one broadcast of a streamer, acked donations are tagged with the active session.
+type StreamSession struct {
+	Id         primitive.ObjectID `json:"id" bson:"_id,omitempty"`
+	StreamerId string             `json:"streamerId" bson:"streamer_id"`
+	Title      string             `json:"title,omitempty" bson:"title,omitempty"`
+	Source     string             `json:"source" bson:"source"`
+	ExternalId string             `json:"external_id,omitempty" bson:"external_id,omitempty"`
+	StartedAt  time.Time          `json:"started_at" bson:"started_at"`
+	EndedAt    *time.Time         `json:"ended_at,omitempty" bson:"ended_at,omitempty"`
+
+	// ActiveStreamerId is streamer id while the session is active. Mongo partial indexes can't
+	// filter on a missing ended_at, unique index on this field keeps one active session per streamer.
+	ActiveStreamerId string `json:"-" bson:"active_streamer_id,omitempty"`
+}
+
+// StartSession creates new active session.
+func (m *MongoStorage) StartSession(ctx context.Context, session StreamSession) (*StreamSession, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_SESSIONS_COLLECTION_NAME")
+
+	if session.EndedAt == nil {
+		session.ActiveStreamerId = session.StreamerId
+	}
+
+	result, err := m.client.Database(dbName).Collection(collectionName).InsertOne(ctx, session)
+	if mongo.IsDuplicateKeyError(err) {
+		return nil, ErrSessionActive
+	} else if err != nil {
+		return nil, err
+	}
+
+	session.Id, _ = result.InsertedID.(primitive.ObjectID)
+	return &session, nil
+}
+
+// GetActiveSession returns session which is not ended yet, or nil.
+func (m *MongoStorage) GetActiveSession(ctx context.Context, streamerId string) (*StreamSession, error) {
+	filter := bson.D{
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "ended_at", Value: nil}}
+	return m.findSession(ctx, filter)
+}
+
+func (m *MongoStorage) GetSession(ctx context.Context, streamerId string, sessionId string) (*StreamSession, error) {
+	id, err := primitive.ObjectIDFromHex(sessionId)
+	if err != nil {
+		return nil, ErrInvalidSessionId
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "streamer_id", Value: streamerId}}
+	return m.findSession(ctx, filter)
+}
+
+func (m *MongoStorage) findSession(ctx context.Context, filter bson.D) (*StreamSession, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_SESSIONS_COLLECTION_NAME")
+
+	opts := options.FindOne().SetSort(bson.D{{Key: "started_at", Value: -1}})
+	result := m.client.Database(dbName).Collection(collectionName).FindOne(ctx, filter, opts)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	var session StreamSession
+	if err := result.Decode(&session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
+
+// GetSessions returns streamer sessions, newest first.
+func (m *MongoStorage) GetSessions(ctx context.Context, streamerId string, limit int64) (*[]StreamSession, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_SESSIONS_COLLECTION_NAME")
+
+	filter := bson.D{{Key: "streamer_id", Value: streamerId}}
+	opts := options.Find().SetSort(bson.D{{Key: "started_at", Value: -1}}).SetLimit(limit)
+	iter, err := m.client.Database(dbName).Collection(collectionName).Find(ctx, filter, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	results := []StreamSession{}
+	if err := iter.All(ctx, &results); err != nil {
+		return nil, errors.New("Failed to retrieve data!")
+	}
+
+	return &results, nil
+}
+
+// EndSession ends the session, or the active one when sessionId is empty. Returns nil if there is no such active session.
+func (m *MongoStorage) EndSession(ctx context.Context, streamerId string, sessionId string) (*StreamSession, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_SESSIONS_COLLECTION_NAME")
+
+	filter := bson.D{
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "ended_at", Value: nil}}
+	if sessionId != "" {
+		id, err := primitive.ObjectIDFromHex(sessionId)
+		if err != nil {
+			return nil, ErrInvalidSessionId
+		}
+		filter = append(filter, bson.E{Key: "_id", Value: id})
+	}
+
+	update := bson.D{
+		{Key: "$set", Value: bson.D{{Key: "ended_at", Value: time.Now().UTC()}}},
+		{Key: "$unset", Value: bson.D{{Key: "active_streamer_id", Value: ""}}}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	result := m.client.Database(dbName).Collection(collectionName).FindOneAndUpdate(ctx, filter, update, opts)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	var session StreamSession
+	if err := result.Decode(&session); err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+}
@@ -1,16 +1,130 @@
 package storage
 
-import "context"
+import (
+	"context"
+	"errors"
+)
 
-type Storage interface {
-	GetLastTransactionLt(ctx context.Context) (uint64, error)
-	CheckTransaction(ctx context.Context, txHash string) (bool, error)
+// Storage backends selected by STORAGE_BACKEND, Mongo is the default.
+const (
+	BackendMongo    = "mongo"
+	BackendMemory   = "memory"
+	BackendPostgres = "postgres"
+)
 
-	GetTransactionBySign(ctx context.Context, sign string) (*Tx, error)
+var ErrWalletAddressTaken = errors.New("Wallet address is registered by another streamer.")
 
-	AckTransaction(ctx context.Context, txHash string) error
-	StoreTransaction(ctx context.Context, tx Tx) error
+var ErrSlugTaken = errors.New("Slug is registered by another streamer.")
 
-	StoreStreamer(ctx context.Context, streamer Streamer) error
-	GetStreamerClientIdByWalletAddress(ctx context.Context, walletAddress string) (string, error)
+// ErrDuplicate is returned when a write breaks a unique constraint, like donation sign.
+var ErrDuplicate = errors.New("Record already exists.")
+
+// StreamerRepository stores streamer profiles, payout wallets and linked accounts.
+// Lookups return nil streamer without error when there is no match.
+type StreamerRepository interface {
+	GetStreamerByCognitoId(ctx context.Context, cognitoId string) (*Streamer, error)
+	GetStreamerByStreamerId(ctx context.Context, streamerId string) (*Streamer, error)
+	GetStreamerByWalletAddress(ctx context.Context, walletAddress string) (*Streamer, error)
+	GetStreamerBySlug(ctx context.Context, slug string) (*Streamer, error)
+	GetStreamerByTwitchUserId(ctx context.Context, twitchUserId string) (*Streamer, error)
+	SaveStreamer(ctx context.Context, streamer Streamer) error
+
+	AddStreamerWallet(ctx context.Context, streamerId string, walletAddress string) error
+	RetireStreamerWallet(ctx context.Context, streamerId string, walletAddress string) (bool, error)
+	SetStreamerTwitch(ctx context.Context, streamerId string, account *TwitchAccount) error
+	// SetStreamerDisabled disables the account with a reason or enables it back, false when there is no such streamer.
+	SetStreamerDisabled(ctx context.Context, streamerId string, disabled bool, reason string) (bool, error)
+}
+
+// DonationRepository stores donations announced by viewers and confirmed by on-chain transactions.
+type DonationRepository interface {
+	GetStreamerDonations(ctx context.Context, streamerId string) (*[]Donation, error)
+	// GetPublicDonations returns the latest verified donations which are not hidden by moderation.
+	GetPublicDonations(ctx context.Context, streamerId string, limit int64) (*[]Donation, error)
+	GetDonationBySign(ctx context.Context, sign string) (*Donation, error)
+	GetDonationByTxHash(ctx context.Context, txHash string) (*Donation, error)
+	// CreateDonation fails with ErrDuplicate when a donation with the same sign exists.
+	CreateDonation(ctx context.Context, donation Donation) error
+	// SaveDonation confirms donation announced with the transaction sign and returns it.
+	// Transaction which reuses the sign of a donation confirmed by another transaction
+	// is stored as a separate donation with DuplicateOf set. Saving the same transaction again is a no-op.
+	SaveDonation(ctx context.Context, transaction Tx, streamerId string) (*Donation, error)
+	// AckDonation marks donation saved for the transaction as acknowledged.
+	AckDonation(ctx context.Context, transaction Tx, ack DonationAck) error
+
+	// GetModerationQueue returns verified donations with a message which were not moderated yet, newest first.
+	GetModerationQueue(ctx context.Context, streamerId string, limit int64) (*[]Donation, error)
+	// ModerateDonation sets moderation of the donation confirmed by the transaction, any streamer's when
+	// streamerId is empty. Returns nil if there is no such donation.
+	ModerateDonation(ctx context.Context, streamerId string, txHash string, moderation DonationModeration) (*Donation, error)
+
+	GetTopDonors(ctx context.Context, query TopDonorsQuery) (*TopDonors, error)
+	GetAnalytics(ctx context.Context, query AnalyticsQuery) (*Analytics, error)
+}
+
+// WidgetRepository stores streamer widgets and goal progress.
+type WidgetRepository interface {
+	GetWidgets(ctx context.Context, streamerId string, archived bool) (*[]Widget, error)
+	GetActiveGoalWidget(ctx context.Context, streamerId string) (*Widget, error)
+	GetWidget(ctx context.Context, streamerId string, widgetId string) (*Widget, error)
+	CreateWidget(ctx context.Context, widget Widget) (*Widget, error)
+	// UpdateWidget applies the update, completed is true when a changed goal amount completed the goal.
+	UpdateWidget(ctx context.Context, streamerId string, widgetId string, update WidgetUpdate) (widget *Widget, completed bool, err error)
+	DeleteWidget(ctx context.Context, streamerId string, widgetId string) (bool, error)
+
+	AddToCurrentAmount(ctx context.Context, streamerId string, widgetId string, donatedAmount uint64, fiatCents map[string]uint64) (*GoalCredit, error)
+	ResetGoal(ctx context.Context, streamerId string, widgetId string, reset GoalReset) (*Widget, error)
+	ArchiveGoal(ctx context.Context, streamerId string, widgetId string) (*Widget, error)
+}
+
+// SessionRepository stores stream sessions, a streamer has at most one active session.
+type SessionRepository interface {
+	// StartSession fails with ErrSessionActive when the streamer has an active session already.
+	StartSession(ctx context.Context, session StreamSession) (*StreamSession, error)
+	GetActiveSession(ctx context.Context, streamerId string) (*StreamSession, error)
+	GetSession(ctx context.Context, streamerId string, sessionId string) (*StreamSession, error)
+	GetSessions(ctx context.Context, streamerId string, limit int64) (*[]StreamSession, error)
+	EndSession(ctx context.Context, streamerId string, sessionId string) (*StreamSession, error)
+}
+
+// RefreshTokenRepository stores refresh tokens of streamers signed in with a TON wallet, by hash only.
+type RefreshTokenRepository interface {
+	CreateRefreshToken(ctx context.Context, token RefreshToken) error
+	// ConsumeRefreshToken deletes the token and returns it, nil if there is no such token or it has expired. Tokens are single use,
+	// so of concurrent refreshes with the same token only one succeeds.
+	ConsumeRefreshToken(ctx context.Context, hash string) (*RefreshToken, error)
+	// DeleteStreamerRefreshTokens signs the streamer out of every device.
+	DeleteStreamerRefreshTokens(ctx context.Context, streamerId string) error
+}
+
+// NonceRepository stores single-use values until they expire.
+type NonceRepository interface {
+	// CreateNonce fails with ErrDuplicate when the nonce exists.
+	CreateNonce(ctx context.Context, nonce Nonce) error
+	// ConsumeNonce deletes the nonce and returns it, nil if there is no such unexpired nonce.
+	// Of concurrent consumers of the same nonce only one gets it.
+	ConsumeNonce(ctx context.Context, key string) (*Nonce, error)
+}
+
+// GrantRepository stores roles given to accounts, see package roles.
+type GrantRepository interface {
+	// SaveGrant creates the grant, saving an existing one is a no-op.
+	SaveGrant(ctx context.Context, grant Grant) error
+	DeleteGrant(ctx context.Context, role string, streamerId string, granteeId string) (bool, error)
+	// GetGranteeGrants returns every role of the account.
+	GetGranteeGrants(ctx context.Context, granteeId string) ([]Grant, error)
+	// GetStreamerGrants returns grants of the role scoped to the streamer, platform wide ones for empty streamerId.
+	GetStreamerGrants(ctx context.Context, role string, streamerId string) ([]Grant, error)
+}
+
+// Repositories is the set of repositories handlers and the TON connector work with.
+type Repositories struct {
+	Streamers StreamerRepository
+	Donations DonationRepository
+	Widgets   WidgetRepository
+	Sessions  SessionRepository
+
+	RefreshTokens RefreshTokenRepository
+	Grants        GrantRepository
+	Nonces        NonceRepository
 }
@@ -2,7 +2,10 @@ package storage
 
 import (
 	"context"
+	"errors"
 	"os"
+	"strings"
+	"time"
 
 	"go.mongodb.org/mongo-driver/bson"
 	"go.mongodb.org/mongo-driver/bson/primitive"
@@ -14,11 +17,74 @@ type Streamer struct {
 	StreamerId    string `json:"streamerId,omitempty" bson:"streamer_id,omitempty"`
 	WalletAddress string `json:"wallet_address,omitempty" bson:"wallet_address,omitempty"`
 	CognitoId     string `json:"cognito_id,omitempty" bson:"cognito_id,omitempty"`
+
+	// Public profile shown on the donation page.
+	Slug        string `json:"slug,omitempty" bson:"slug,omitempty"`
+	DisplayName string `json:"display_name,omitempty" bson:"display_name,omitempty"`
+	AvatarUrl   string `json:"avatar_url,omitempty" bson:"avatar_url,omitempty"`
+	Description string `json:"description,omitempty" bson:"description,omitempty"`
+
+	// All payout wallets ever registered, WalletAddress is the primary one.
+	// Retired wallets are kept so that their past transactions still resolve to the streamer.
+	Wallets []StreamerWallet `json:"wallets,omitempty" bson:"wallets,omitempty"`
+
+	// Linked Twitch channel, its stream.online/offline events start and end sessions.
+	Twitch *TwitchAccount `json:"twitch,omitempty" bson:"twitch,omitempty"`
+
+	// Disabled accounts can't use the API and don't accept donations, set by platform admins.
+	Disabled       bool   `json:"disabled,omitempty" bson:"disabled,omitempty"`
+	DisabledReason string `json:"disabled_reason,omitempty" bson:"disabled_reason,omitempty"`
+}
+
+type TwitchAccount struct {
+	UserId      string    `json:"user_id" bson:"user_id"`
+	Login       string    `json:"login" bson:"login"`
+	DisplayName string    `json:"display_name,omitempty" bson:"display_name,omitempty"`
+	LinkedAt    time.Time `json:"linked_at" bson:"linked_at"`
+}
+
+type StreamerWallet struct {
+	Address    string     `json:"address" bson:"address"`
+	IsActive   bool       `json:"is_active" bson:"is_active"`
+	ActiveFrom time.Time  `json:"active_from" bson:"active_from"`
+	RetiredAt  *time.Time `json:"retired_at,omitempty" bson:"retired_at,omitempty"`
+	// Periods are earlier active periods of a reactivated wallet, oldest first.
+	Periods []WalletPeriod `json:"periods,omitempty" bson:"periods,omitempty"`
+}
+
+type WalletPeriod struct {
+	ActiveFrom time.Time `json:"active_from" bson:"active_from"`
+	RetiredAt  time.Time `json:"retired_at" bson:"retired_at"`
 }
 
-// type StreamersRepository interface {
-// 	GetStreamer(ctx context.Context, streamerId string) (Streamer, error)
-// }
+// retiredPeriod is the period which ended when the wallet was retired.
+func (w *StreamerWallet) retiredPeriod() WalletPeriod {
+	period := WalletPeriod{ActiveFrom: w.ActiveFrom}
+	if w.RetiredAt != nil {
+		period.RetiredAt = *w.RetiredAt
+	}
+	return period
+}
+
+// GetWallets returns streamer wallets, streamers registered before wallet history have only the primary one.
+func (s *Streamer) GetWallets() []StreamerWallet {
+	if len(s.Wallets) == 0 && s.WalletAddress != "" {
+		return []StreamerWallet{{Address: s.WalletAddress, IsActive: true}}
+	}
+
+	return s.Wallets
+}
+
+// IsActiveWallet checks if streamer currently accepts donations to the wallet.
+func (s *Streamer) IsActiveWallet(walletAddress string) bool {
+	for _, wallet := range s.GetWallets() {
+		if wallet.Address == walletAddress {
+			return wallet.IsActive
+		}
+	}
+
+	return false
+}
 
 func (m *MongoStorage) GetStreamerByCognitoId(ctx context.Context, cognitoId string) (*Streamer, error) {
 	filter := bson.D{{Key: "cognito_id", Value: cognitoId}}
@@ -30,8 +96,22 @@ func (m *MongoStorage) GetStreamerByStreamerId(ctx context.Context, streamerId s
 	return getStreamer(ctx, m.client, filter)
 }
 
+// GetStreamerByWalletAddress looks up the streamer by any of the wallets, including retired ones.
 func (m *MongoStorage) GetStreamerByWalletAddress(ctx context.Context, walletAddress string) (*Streamer, error) {
-	filter := bson.D{{Key: "wallet_address", Value: walletAddress}}
+	filter := bson.D{{Key: "$or", Value: bson.A{
+		bson.D{{Key: "wallet_address", Value: walletAddress}},
+		bson.D{{Key: "wallets.address", Value: walletAddress}},
+	}}}
+	return getStreamer(ctx, m.client, filter)
+}
+
+func (m *MongoStorage) GetStreamerBySlug(ctx context.Context, slug string) (*Streamer, error) {
+	filter := bson.D{{Key: "slug", Value: slug}}
+	return getStreamer(ctx, m.client, filter)
+}
+
+func (m *MongoStorage) GetStreamerByTwitchUserId(ctx context.Context, twitchUserId string) (*Streamer, error) {
+	filter := bson.D{{Key: "twitch.user_id", Value: twitchUserId}}
 	return getStreamer(ctx, m.client, filter)
 }
 
@@ -54,7 +134,7 @@ func getStreamer(ctx context.Context, client *mongo.Client, filter primitive.D)
 	return &result, nil
 }
 
-func (m *MongoStorage) SaveStreamer(ctx context.Context, streamer Streamer) (*mongo.UpdateResult, error) {
+func (m *MongoStorage) SaveStreamer(ctx context.Context, streamer Streamer) error {
 	dbName := os.Getenv("DB_NAME")
 	collectionName := os.Getenv("DB_STREAMERS_COLLECTION_NAME")
 
@@ -63,13 +143,160 @@ func (m *MongoStorage) SaveStreamer(ctx context.Context, streamer Streamer) (*mo
 	update := bson.D{{Key: "$set", Value: bson.D{
 		{Key: "streamer_id", Value: streamer.StreamerId},
 		{Key: "cognito_id", Value: streamer.CognitoId},
-		{Key: "wallet_address", Value: streamer.WalletAddress}}}}
+		{Key: "wallet_address", Value: streamer.WalletAddress},
+		{Key: "slug", Value: streamer.Slug},
+		{Key: "display_name", Value: streamer.DisplayName},
+		{Key: "avatar_url", Value: streamer.AvatarUrl},
+		{Key: "description", Value: streamer.Description}}}}
 
-	result, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update, opts)
+	_, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update, opts)
+	return streamerError(err)
+}
 
+// streamerError reports violation of unique streamer indexes, see Bootstrap.
+func streamerError(err error) error {
+	switch duplicateKey(err) {
+	case "wallet_address", "wallets.address":
+		return ErrWalletAddressTaken
+	case "slug":
+		return ErrSlugTaken
+	}
+	return err
+}
+
+const duplicateKeyCode = 11000
+
+// duplicateKey returns the first field of the unique index violated by a write, empty for other errors.
+func duplicateKey(err error) string {
+	var raws []bson.Raw
+	var writeException mongo.WriteException
+	var commandError mongo.CommandError
+	if errors.As(err, &writeException) {
+		for _, writeError := range writeException.WriteErrors {
+			if writeError.Code == duplicateKeyCode {
+				raws = append(raws, writeError.Raw)
+			}
+		}
+	} else if errors.As(err, &commandError) && commandError.Code == duplicateKeyCode {
+		raws = append(raws, commandError.Raw)
+	}
+
+	for _, raw := range raws {
+		if pattern, ok := raw.Lookup("keyPattern").DocumentOK(); ok {
+			if elements, err := pattern.Elements(); err == nil && len(elements) > 0 {
+				return elements[0].Key()
+			}
+		}
+
+		// Servers before 4.4 only name the index in the message, like "index: slug_1 dup key",
+		// unique streamer indexes are on single field.
+		message, _ := raw.Lookup("errmsg").StringValueOK()
+		if _, index, ok := strings.Cut(message, "index: "); ok {
+			index, _, _ = strings.Cut(index, " ")
+			return strings.TrimSuffix(index, "_1")
+		}
+	}
+	return ""
+}
+
+// AddStreamerWallet adds active payout wallet to the streamer or reactivates previously retired one.
+func (m *MongoStorage) AddStreamerWallet(ctx context.Context, streamerId string, walletAddress string) error {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_STREAMERS_COLLECTION_NAME")
+	collection := m.client.Database(dbName).Collection(collectionName)
+
+	streamer, err := m.GetStreamerByStreamerId(ctx, streamerId)
+	if err != nil || streamer == nil {
+		return err
+	}
+
+	for _, wallet := range streamer.Wallets {
+		if wallet.Address != walletAddress {
+			continue
+		} else if wallet.IsActive {
+			return nil
+		}
+
+		// Period is taken from the loaded wallet, the update applies only while it is still retired.
+		filter := bson.D{
+			{Key: "streamer_id", Value: streamerId},
+			{Key: "wallets", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+				{Key: "address", Value: walletAddress},
+				{Key: "is_active", Value: false}}}}}}
+		update := bson.D{
+			{Key: "$set", Value: bson.D{
+				{Key: "wallets.$.is_active", Value: true},
+				{Key: "wallets.$.active_from", Value: time.Now().UTC()}}},
+			{Key: "$unset", Value: bson.D{{Key: "wallets.$.retired_at", Value: ""}}},
+			{Key: "$push", Value: bson.D{{Key: "wallets.$.periods", Value: wallet.retiredPeriod()}}}}
+
+		_, err := collection.UpdateOne(ctx, filter, update)
+		return err
+	}
+
+	filter := bson.D{{Key: "streamer_id", Value: streamerId}}
+	update := bson.D{{Key: "$push", Value: bson.D{{Key: "wallets", Value: StreamerWallet{
+		Address:    walletAddress,
+		IsActive:   true,
+		ActiveFrom: time.Now().UTC(),
+	}}}}}
+
+	_, err = collection.UpdateOne(ctx, filter, update)
+	return streamerError(err)
+}
+
+// RetireStreamerWallet stops accepting donations to the wallet but keeps it in streamer history.
+func (m *MongoStorage) RetireStreamerWallet(ctx context.Context, streamerId string, walletAddress string) (bool, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_STREAMERS_COLLECTION_NAME")
+
+	filter := bson.D{
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "wallets", Value: bson.D{{Key: "$elemMatch", Value: bson.D{
+			{Key: "address", Value: walletAddress},
+			{Key: "is_active", Value: true}}}}}}
+	update := bson.D{{Key: "$set", Value: bson.D{
+		{Key: "wallets.$.is_active", Value: false},
+		{Key: "wallets.$.retired_at", Value: time.Now().UTC()}}}}
+
+	result, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update)
 	if err != nil {
-		return nil, err
+		return false, err
+	}
+
+	return result.MatchedCount > 0, nil
+}
+
+// SetStreamerTwitch links Twitch channel to the streamer, nil account unlinks it.
+func (m *MongoStorage) SetStreamerTwitch(ctx context.Context, streamerId string, account *TwitchAccount) error {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_STREAMERS_COLLECTION_NAME")
+
+	filter := bson.D{{Key: "streamer_id", Value: streamerId}}
+	update := bson.D{{Key: "$unset", Value: bson.D{{Key: "twitch", Value: ""}}}}
+	if account != nil {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "twitch", Value: account}}}}
+	}
+
+	_, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update)
+	return err
+}
+
+// SetStreamerDisabled disables the account with a reason or enables it back, false when there is no such streamer.
+func (m *MongoStorage) SetStreamerDisabled(ctx context.Context, streamerId string, disabled bool, reason string) (bool, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_STREAMERS_COLLECTION_NAME")
+
+	filter := bson.D{{Key: "streamer_id", Value: streamerId}}
+	update := bson.D{{Key: "$unset", Value: bson.D{{Key: "disabled", Value: ""}, {Key: "disabled_reason", Value: ""}}}}
+	if disabled {
+		update = bson.D{{Key: "$set", Value: bson.D{{Key: "disabled", Value: true}, {Key: "disabled_reason", Value: reason}}}}
+	}
+
+	result, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update)
+	if err != nil {
+		return false, err
 	}
 
-	return result, nil
+	return result.MatchedCount > 0, nil
 }
@@ -4,21 +4,79 @@ import (
 	"context"
 	"errors"
 	"os"
+	"time"
 
+	"github.com/vladtenlive/ton-donate/pkg/widgets"
 	"go.mongodb.org/mongo-driver/bson"
+	"go.mongodb.org/mongo-driver/bson/primitive"
 	"go.mongodb.org/mongo-driver/mongo"
 	"go.mongodb.org/mongo-driver/mongo/options"
 )
 
+var ErrInvalidWidgetId = errors.New("Invalid widget id.")
+
 type Widget struct {
-	StreamerId    string `json:"streamerId,omitempty" bson:"streamer_id,omitempty"`
-	Type          string `json:"type,omitempty" bson:"type,omitempty"`
-	AmountGoal    uint64 `json:"amount_goal,omitempty" bson:"amount_goal,omitempty"`
-	AmountCurrent uint64 `json:"amount_current,omitempty" bson:"amount_current,omitempty"`
-	IsActive      bool   `json:"isActive,omitempty" bson:"is_active,omitempty"`
+	Id             primitive.ObjectID `json:"id,omitempty" bson:"_id,omitempty"`
+	StreamerId     string             `json:"streamerId,omitempty" bson:"streamer_id,omitempty"`
+	Type           string             `json:"type,omitempty" bson:"type,omitempty"`
+	Title          string             `json:"title,omitempty" bson:"title,omitempty"`
+	AmountGoal     uint64             `json:"amount_goal,omitempty" bson:"amount_goal,omitempty"`
+	AmountCurrent  uint64             `json:"amount_current,omitempty" bson:"amount_current,omitempty"`
+	DonationsCount uint64             `json:"donations_count,omitempty" bson:"donations_count,omitempty"`
+	IsActive       bool               `json:"isActive,omitempty" bson:"is_active,omitempty"`
+
+	// Currency of goal amounts, TON amounts are in nanoTON and fiat ones are in cents.
+	Currency string `json:"currency,omitempty" bson:"currency,omitempty"`
+
+	// Configuration of the widget type, see widgets package for schemas.
+	Config widgets.Config `json:"config" bson:"config"`
+
+	// Goal lifecycle, donations are credited only between optional start and end dates.
+	StartsAt    *time.Time   `json:"starts_at,omitempty" bson:"starts_at,omitempty"`
+	EndsAt      *time.Time   `json:"ends_at,omitempty" bson:"ends_at,omitempty"`
+	StartedAt   *time.Time   `json:"started_at,omitempty" bson:"started_at,omitempty"`
+	IsCompleted bool         `json:"is_completed,omitempty" bson:"is_completed,omitempty"`
+	CompletedAt *time.Time   `json:"completed_at,omitempty" bson:"completed_at,omitempty"`
+	IsArchived  bool         `json:"is_archived,omitempty" bson:"is_archived,omitempty"`
+	History     []GoalRecord `json:"history,omitempty" bson:"history,omitempty"`
 }
 
-func (m *MongoStorage) GetWidgets(ctx context.Context, streamerId string) (*[]Widget, error) {
+// CreditAmount picks the donation amount in the goal currency, false if the donation has no amount in it.
+func (w *Widget) CreditAmount(nanoTon uint64, fiatCents map[string]uint64) (uint64, bool) {
+	if w.Currency == "" || w.Currency == CurrencyTON {
+		return nanoTon, true
+	}
+
+	cents, ok := fiatCents[w.Currency]
+	return cents, ok
+}
+
+// GoalCredit is what crediting a donation did to the goals.
+type GoalCredit struct {
+	// Completed are goals which were completed by the donation.
+	Completed []Widget
+	// Skipped are goals in a fiat currency the donation has no amount in, they are not credited.
+	Skipped []Widget
+}
+
+// reachedGoal tells whether the current round reached a set goal amount.
+func (w *Widget) reachedGoal() bool {
+	return w.AmountGoal > 0 && w.AmountCurrent >= w.AmountGoal
+}
+
+// WidgetUpdate holds fields to change, nil fields are left as is.
+// Changing the goal amount completes the goal or brings it back to progress.
+type WidgetUpdate struct {
+	Title      *string
+	AmountGoal *uint64
+	IsActive   *bool
+	Config     *widgets.Config
+	StartsAt   *time.Time
+	EndsAt     *time.Time
+}
+
+// GetWidgets returns streamer widgets, archived goals are returned only when asked for.
+func (m *MongoStorage) GetWidgets(ctx context.Context, streamerId string, archived bool) (*[]Widget, error) {
 	dbName := os.Getenv("DB_NAME")
 	collectionName := os.Getenv("DB_WIDGETS_COLLECTION_NAME")
 
@@ -32,7 +90,13 @@ func (m *MongoStorage) GetWidgets(ctx context.Context, streamerId string) (*[]Wi
 	// Load all widgets
 	filter = bson.D{
 		{Key: "streamer_id", Value: streamerId},
-		// {Key: "is_completed", Value: false}, // ToDo: We will probably need also is completed flag for filtering
+		{Key: "is_archived", Value: bson.D{{Key: "$ne", Value: true}}},
+	}
+	if archived {
+		filter = bson.D{
+			{Key: "streamer_id", Value: streamerId},
+			{Key: "is_archived", Value: true},
+		}
 	}
 	opts := options.Find().SetLimit(100)
 	iter, err := m.client.Database(dbName).Collection(collectionName).Find(ctx, filter, opts)
@@ -48,7 +112,163 @@ func (m *MongoStorage) GetWidgets(ctx context.Context, streamerId string) (*[]Wi
 	return &results, nil
 }
 
-func (m *MongoStorage) CreateWidget(ctx context.Context, widget Widget) (*mongo.InsertOneResult, error) {
+// GetActiveGoalWidget returns the active donation goal widget, or nil if the streamer has none.
+func (m *MongoStorage) GetActiveGoalWidget(ctx context.Context, streamerId string) (*Widget, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_WIDGETS_COLLECTION_NAME")
+
+	filter := bson.D{
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "type", Value: widgets.TypeDonationGoal},
+		{Key: "is_active", Value: true},
+		{Key: "is_archived", Value: bson.D{{Key: "$ne", Value: true}}},
+	}
+	result := m.client.Database(dbName).Collection(collectionName).FindOne(ctx, filter)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	var widget Widget
+	if err := result.Decode(&widget); err != nil {
+		return nil, err
+	}
+
+	return &widget, nil
+}
+
+// GetWidget returns streamer's widget by id, widgets of other streamers are not found.
+func (m *MongoStorage) GetWidget(ctx context.Context, streamerId string, widgetId string) (*Widget, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_WIDGETS_COLLECTION_NAME")
+
+	id, err := primitive.ObjectIDFromHex(widgetId)
+	if err != nil {
+		return nil, ErrInvalidWidgetId
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "streamer_id", Value: streamerId}}
+	result := m.client.Database(dbName).Collection(collectionName).FindOne(ctx, filter)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, nil
+	}
+
+	var widget Widget
+	if err := result.Decode(&widget); err != nil {
+		return nil, err
+	}
+
+	return &widget, nil
+}
+
+// UpdateWidget applies the update to streamer's widget and returns the updated one, nil if there is no such widget.
+func (m *MongoStorage) UpdateWidget(ctx context.Context, streamerId string, widgetId string, update WidgetUpdate) (*Widget, bool, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_WIDGETS_COLLECTION_NAME")
+
+	id, err := primitive.ObjectIDFromHex(widgetId)
+	if err != nil {
+		return nil, false, ErrInvalidWidgetId
+	}
+
+	set := bson.D{}
+	if update.Title != nil {
+		set = append(set, bson.E{Key: "title", Value: *update.Title})
+	}
+	if update.AmountGoal != nil {
+		set = append(set, bson.E{Key: "amount_goal", Value: *update.AmountGoal})
+	}
+	if update.IsActive != nil {
+		set = append(set, bson.E{Key: "is_active", Value: *update.IsActive})
+	}
+	if update.Config != nil {
+		set = append(set, bson.E{Key: "config", Value: *update.Config})
+	}
+	if update.StartsAt != nil {
+		set = append(set, bson.E{Key: "starts_at", Value: *update.StartsAt})
+	}
+	if update.EndsAt != nil {
+		set = append(set, bson.E{Key: "ends_at", Value: *update.EndsAt})
+	}
+	if len(set) == 0 {
+		widget, err := m.GetWidget(ctx, streamerId, widgetId)
+		return widget, false, err
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "streamer_id", Value: streamerId}}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	collection := m.client.Database(dbName).Collection(collectionName)
+	result := collection.FindOneAndUpdate(ctx, filter, bson.D{{Key: "$set", Value: set}}, opts)
+	if result.Err() == mongo.ErrNoDocuments {
+		return nil, false, nil
+	}
+
+	var widget Widget
+	if err := result.Decode(&widget); err != nil {
+		return nil, false, err
+	}
+
+	if update.AmountGoal == nil || widget.IsCompleted == widget.reachedGoal() {
+		return &widget, false, nil
+	}
+
+	// Completion is changed only if no concurrent donation has changed it since, so that it is reported once.
+	wasCompleted := widget.IsCompleted
+	observed := append(bson.D{{Key: "is_completed", Value: true}}, filter...)
+	if !wasCompleted {
+		observed[0].Value = bson.D{{Key: "$ne", Value: true}}
+	}
+	result = collection.FindOneAndUpdate(ctx, observed, goalCompletion(time.Now().UTC()), opts)
+	if result.Err() == mongo.ErrNoDocuments {
+		reloaded, err := m.GetWidget(ctx, streamerId, widgetId)
+		return reloaded, false, err
+	} else if err := result.Decode(&widget); err != nil {
+		return nil, false, err
+	}
+
+	return &widget, !wasCompleted && widget.IsCompleted, nil
+}
+
+// goalCompletion is an update pipeline setting completion by current and goal amounts, completed_at
+// of an already completed goal is kept.
+func goalCompletion(now time.Time) bson.A {
+	return bson.A{
+		bson.D{{Key: "$set", Value: bson.D{{Key: "is_completed", Value: bson.D{{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$gt", Value: bson.A{"$amount_goal", 0}}},
+			bson.D{{Key: "$gte", Value: bson.A{"$amount_current", "$amount_goal"}}}}}}}}}},
+		bson.D{{Key: "$set", Value: bson.D{{Key: "completed_at", Value: bson.D{{Key: "$cond", Value: bson.A{
+			"$is_completed",
+			bson.D{{Key: "$ifNull", Value: bson.A{"$completed_at", now}}},
+			"$$REMOVE"}}}}}}},
+	}
+}
+
+// DeleteWidget removes streamer's widget, returns false if there is no such widget.
+func (m *MongoStorage) DeleteWidget(ctx context.Context, streamerId string, widgetId string) (bool, error) {
+	dbName := os.Getenv("DB_NAME")
+	collectionName := os.Getenv("DB_WIDGETS_COLLECTION_NAME")
+
+	id, err := primitive.ObjectIDFromHex(widgetId)
+	if err != nil {
+		return false, ErrInvalidWidgetId
+	}
+
+	filter := bson.D{
+		{Key: "_id", Value: id},
+		{Key: "streamer_id", Value: streamerId}}
+	result, err := m.client.Database(dbName).Collection(collectionName).DeleteOne(ctx, filter)
+	if err != nil {
+		return false, err
+	}
+
+	return result.DeletedCount > 0, nil
+}
+
+// CreateWidget stores new widget of existing streamer and returns it with the assigned id.
+func (m *MongoStorage) CreateWidget(ctx context.Context, widget Widget) (*Widget, error) {
 	dbName := os.Getenv("DB_NAME")
 	collectionName := os.Getenv("DB_WIDGETS_COLLECTION_NAME")
 
@@ -67,32 +287,106 @@ func (m *MongoStorage) CreateWidget(ctx context.Context, widget Widget) (*mongo.
 		return nil, err
 	}
 
-	return result, nil
+	widget.Id, _ = result.InsertedID.(primitive.ObjectID)
+	return &widget, nil
 }
 
-func (m *MongoStorage) AddToCurrentAmount(ctx context.Context, streamerId string, donatedAmount uint64) (*mongo.UpdateResult, error) {
+// AddToCurrentAmount credits donation to streamer's running goal widgets: only to the linked one when
+// widgetId is set, otherwise to every active goal. Donation counters are always updated.
+// Goals denominated in fiat are credited by the donation amount in cents of their currency and skipped
+// when it is missing. Completed goals are not credited until they are reset.
+// Widgets are never created here, type-less widgets upserted by earlier releases are migrated
+// to goals by Bootstrap, see migrateLegacyWidgets.
+func (m *MongoStorage) AddToCurrentAmount(ctx context.Context, streamerId string, widgetId string, donatedAmount uint64, fiatCents map[string]uint64) (*GoalCredit, error) {
 	dbName := os.Getenv("DB_NAME")
 	collectionName := os.Getenv("DB_WIDGETS_COLLECTION_NAME")
+	collection := m.client.Database(dbName).Collection(collectionName)
 
-	// Check if streamer exist
-	filter := bson.D{{Key: "streamer_id", Value: streamerId}}
-	_, err := getStreamer(ctx, m.client, filter)
-	if err != nil {
+	filter := bson.D{
+		{Key: "streamer_id", Value: streamerId},
+		{Key: "type", Value: widgets.TypeDonationCounter},
+		{Key: "is_active", Value: true}}
+	update := bson.D{{Key: "$inc", Value: bson.D{
+		{Key: "amount_current", Value: donatedAmount},
+		{Key: "donations_count", Value: 1}}}}
+	if _, err := collection.UpdateMany(ctx, filter, update); err != nil {
 		return nil, err
 	}
 
-	opts := options.Update().SetUpsert(true)
+	now := time.Now().UTC()
 	filter = bson.D{
 		{Key: "streamer_id", Value: streamerId},
-		{Key: "is_active", Value: true}}
-	update := bson.D{{Key: "$inc", Value: bson.D{
-		{Key: "amount_current", Value: donatedAmount}}}}
-
-	result, err := m.client.Database(dbName).Collection(collectionName).UpdateOne(ctx, filter, update, opts)
+		{Key: "type", Value: widgets.TypeDonationGoal},
+		{Key: "is_active", Value: true},
+		{Key: "is_archived", Value: bson.D{{Key: "$ne", Value: true}}},
+		{Key: "is_completed", Value: bson.D{{Key: "$ne", Value: true}}},
+		{Key: "$and", Value: bson.A{
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "starts_at", Value: nil}},
+				bson.D{{Key: "starts_at", Value: bson.D{{Key: "$lte", Value: now}}}}}}},
+			bson.D{{Key: "$or", Value: bson.A{
+				bson.D{{Key: "ends_at", Value: nil}},
+				bson.D{{Key: "ends_at", Value: bson.D{{Key: "$gt", Value: now}}}}}}},
+		}}}
+	findFilter := filter
+	if widgetId != "" {
+		id, err := primitive.ObjectIDFromHex(widgetId)
+		if err != nil {
+			return nil, ErrInvalidWidgetId
+		}
+		findFilter = append(bson.D{{Key: "_id", Value: id}}, filter...)
+	}
 
+	iter, err := collection.Find(ctx, findFilter, options.Find().SetProjection(bson.D{
+		{Key: "_id", Value: 1},
+		{Key: "currency", Value: 1}}))
 	if err != nil {
 		return nil, err
 	}
 
-	return result, nil
+	var goals []Widget
+	if err := iter.All(ctx, &goals); err != nil {
+		return nil, err
+	}
+
+	// Goals are credited one by one to see the amount before and after the donation.
+	credit := &GoalCredit{}
+	opts := options.FindOneAndUpdate().SetReturnDocument(options.After)
+	for _, goal := range goals {
+		amount, ok := goal.CreditAmount(donatedAmount, fiatCents)
+		if !ok {
+			credit.Skipped = append(credit.Skipped, goal)
+			continue
+		}
+
+		goalFilter := append(bson.D{{Key: "_id", Value: goal.Id}}, filter...)
+		update := bson.D{{Key: "$inc", Value: bson.D{
+			{Key: "amount_current", Value: amount},
+			{Key: "donations_count", Value: 1}}}}
+
+		var widget Widget
+		err := collection.FindOneAndUpdate(ctx, goalFilter, update, opts).Decode(&widget)
+		if err == mongo.ErrNoDocuments {
+			// Deactivated in the meantime.
+			continue
+		} else if err != nil {
+			return credit, err
+		}
+
+		if widget.AmountGoal > 0 && widget.AmountCurrent >= widget.AmountGoal && widget.AmountCurrent-amount < widget.AmountGoal {
+			widget.IsCompleted = true
+			widget.CompletedAt = &now
+
+			completeUpdate := bson.D{{Key: "$set", Value: bson.D{
+				{Key: "is_completed", Value: true},
+				{Key: "completed_at", Value: now}}}}
+			if _, err := collection.UpdateByID(ctx, widget.Id, completeUpdate); err != nil {
+				return credit, err
+			}
+
+			credit.Completed = append(credit.Completed, widget)
+		}
+	}
+
+	return credit, nil
 }
@@ -16,13 +16,27 @@ func (e NotificationError) Error() string {
 }
 
 type NotificationRequest struct {
-	Id         string // could be tx hash for example, for more detailed error handling
-	Amount     uint64 `json:"amount"`
-	Text       string `json:"text"`
-	Nickname   string `json:"nickname"`
-	StreamerId string `json:"clientId"`
+	Id         string             // could be tx hash for example, for more detailed error handling
+	Amount     uint64             `json:"amount"`
+	Fiat       map[string]float64 `json:"fiat,omitempty"` // amount by fiat currency
+	Text       string             `json:"text"`
+	Nickname   string             `json:"nickname"`
+	StreamerId string             `json:"clientId"`
 }
 
+// GoalCompletedRequest is sent once when donation makes goal widget reach its amount.
+type GoalCompletedRequest struct {
+	Event         string `json:"event"`
+	WidgetId      string `json:"widgetId"`
+	Title         string `json:"title,omitempty"`
+	AmountGoal    uint64 `json:"amount_goal"`
+	AmountCurrent uint64 `json:"amount_current"`
+	Currency      string `json:"currency,omitempty"`
+	StreamerId    string `json:"clientId"`
+}
+
+const GoalCompletedEvent = "goal_completed"
+
 type Notifier struct {
 	client    *http.Client
 	widgetUri string
@@ -36,6 +50,15 @@ func NewNotifier(client *http.Client, widgetUri string) *Notifier {
 }
 
 func (n *Notifier) Send(r NotificationRequest) error {
+	return n.post(r, r.Id)
+}
+
+func (n *Notifier) SendGoalCompleted(r GoalCompletedRequest) error {
+	r.Event = GoalCompletedEvent
+	return n.post(r, r.WidgetId)
+}
+
+func (n *Notifier) post(r any, id string) error {
 	data, err := json.Marshal(r)
 	if err != nil {
 		return err
@@ -52,7 +75,7 @@ func (n *Notifier) Send(r NotificationRequest) error {
 
 	// log.Println("RESPONSE:", resp)
 	if resp.StatusCode != http.StatusCreated {
-		return NotificationError{r.Id}
+		return NotificationError{id}
 	}
 
 	return nil
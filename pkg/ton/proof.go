@@ -0,0 +1,263 @@
+package ton
+
+import (
+	"bytes"
+	"context"
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/ton"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const (
+	tonProofPrefix   = "ton-proof-item-v2/"
+	tonConnectPrefix = "ton-connect"
+
+	// How long an issued payload and a signed proof stay valid.
+	ProofTTL = 15 * time.Minute
+)
+
+var ErrInvalidProof = errors.New("invalid ton_proof")
+
+// Proof is the ton_proof item returned by TON Connect wallets.
+type Proof struct {
+	Timestamp int64       `json:"timestamp"`
+	Domain    ProofDomain `json:"domain"`
+	Signature string      `json:"signature"`
+	Payload   string      `json:"payload"`
+	StateInit string      `json:"state_init,omitempty"`
+}
+
+type ProofDomain struct {
+	LengthBytes uint32 `json:"lengthBytes"`
+	Value       string `json:"value"`
+}
+
+// ProofVerifier issues ton_proof payloads and checks signed proofs.
+// Payloads are expiry and random nonce signed with the server secret, issued payloads are
+// stored by hash and spent by the first proof which passes verification.
+type ProofVerifier struct {
+	client  *ton.APIClient
+	nonces  storage.NonceRepository
+	secret  []byte
+	domains []string
+}
+
+func NewProofVerifier(client *ton.APIClient, nonces storage.NonceRepository, secret string, domains []string) *ProofVerifier {
+	return &ProofVerifier{
+		client:  client,
+		nonces:  nonces,
+		secret:  []byte(secret),
+		domains: domains,
+	}
+}
+
+// IssuePayload returns new payload bound to the subject, subject could be empty for anonymous flows.
+func (v *ProofVerifier) IssuePayload(ctx context.Context, subject string) (string, error) {
+	expiresAt := time.Now().Add(ProofTTL)
+
+	data := make([]byte, 16)
+	binary.BigEndian.PutUint64(data[:8], uint64(expiresAt.Unix()))
+	if _, err := rand.Read(data[8:]); err != nil {
+		return "", err
+	}
+
+	payload := hex.EncodeToString(append(data, v.payloadMac(data, subject)...))
+	if err := v.nonces.CreateNonce(ctx, storage.Nonce{Key: payloadKey(payload), ExpiresAt: expiresAt}); err != nil {
+		return "", err
+	}
+
+	return payload, nil
+}
+
+// payloadKey is the nonce key of an issued payload.
+func payloadKey(payload string) string {
+	hash := sha256.Sum256([]byte(payload))
+	return "ton_proof:" + hex.EncodeToString(hash[:])
+}
+
+func (v *ProofVerifier) checkPayload(payload string, subject string) error {
+	data, err := hex.DecodeString(payload)
+	if err != nil || len(data) != 32 {
+		return fmt.Errorf("%w: malformed payload", ErrInvalidProof)
+	}
+
+	if !hmac.Equal(data[16:], v.payloadMac(data[:16], subject)) {
+		return fmt.Errorf("%w: payload was not issued by this server", ErrInvalidProof)
+	}
+
+	expiresAt := time.Unix(int64(binary.BigEndian.Uint64(data[:8])), 0)
+	if time.Now().After(expiresAt) {
+		return fmt.Errorf("%w: payload expired", ErrInvalidProof)
+	}
+
+	return nil
+}
+
+func (v *ProofVerifier) payloadMac(data []byte, subject string) []byte {
+	mac := hmac.New(sha256.New, v.secret)
+	mac.Write(data)
+	mac.Write([]byte(subject))
+	return mac.Sum(nil)[:16]
+}
+
+// Verify checks that proof was signed by the owner of the wallet for our domain and payload issued to the subject.
+func (v *ProofVerifier) Verify(ctx context.Context, walletAddress *address.Address, proof *Proof, subject string) error {
+	if proof == nil {
+		return fmt.Errorf("%w: proof is required", ErrInvalidProof)
+	}
+
+	if err := v.checkPayload(proof.Payload, subject); err != nil {
+		return err
+	}
+
+	if !v.isAllowedDomain(proof.Domain.Value) || proof.Domain.LengthBytes != uint32(len(proof.Domain.Value)) {
+		return fmt.Errorf("%w: unexpected domain %q", ErrInvalidProof, proof.Domain.Value)
+	}
+
+	signedAt := time.Unix(proof.Timestamp, 0)
+	if time.Since(signedAt) > ProofTTL || time.Until(signedAt) > time.Minute {
+		return fmt.Errorf("%w: proof timestamp is out of range", ErrInvalidProof)
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(proof.Signature)
+	if err != nil || len(signature) != ed25519.SignatureSize {
+		return fmt.Errorf("%w: malformed signature", ErrInvalidProof)
+	}
+
+	publicKey, err := v.getPublicKey(ctx, walletAddress, proof.StateInit)
+	if err != nil {
+		return err
+	}
+
+	if !ed25519.Verify(publicKey, proofHash(walletAddress, proof), signature) {
+		return fmt.Errorf("%w: signature mismatch", ErrInvalidProof)
+	}
+
+	// Spent last, so that invalid proofs can't burn the payload of the client it was issued to.
+	nonce, err := v.nonces.ConsumeNonce(ctx, payloadKey(proof.Payload))
+	if err != nil {
+		return err
+	} else if nonce == nil {
+		return fmt.Errorf("%w: payload was already used", ErrInvalidProof)
+	}
+
+	return nil
+}
+
+func (v *ProofVerifier) isAllowedDomain(domain string) bool {
+	for _, allowed := range v.domains {
+		if strings.EqualFold(strings.TrimSpace(allowed), domain) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// proofHash builds the message signed by the wallet, see TON Connect ton_proof specification.
+func proofHash(walletAddress *address.Address, proof *Proof) []byte {
+	var message bytes.Buffer
+	message.WriteString(tonProofPrefix)
+	binary.Write(&message, binary.BigEndian, walletAddress.Workchain())
+	message.Write(walletAddress.Data())
+	binary.Write(&message, binary.LittleEndian, proof.Domain.LengthBytes)
+	message.WriteString(proof.Domain.Value)
+	binary.Write(&message, binary.LittleEndian, uint64(proof.Timestamp))
+	message.WriteString(proof.Payload)
+
+	messageHash := sha256.Sum256(message.Bytes())
+
+	var full bytes.Buffer
+	full.Write([]byte{0xff, 0xff})
+	full.WriteString(tonConnectPrefix)
+	full.Write(messageHash[:])
+
+	hash := sha256.Sum256(full.Bytes())
+	return hash[:]
+}
+
+// getPublicKey asks deployed wallet for its key, not yet deployed wallets are checked by state init.
+func (v *ProofVerifier) getPublicKey(ctx context.Context, walletAddress *address.Address, stateInit string) (ed25519.PublicKey, error) {
+	if v.client != nil {
+		if publicKey, err := v.getDeployedPublicKey(ctx, walletAddress); err == nil {
+			return publicKey, nil
+		}
+	}
+
+	if stateInit == "" {
+		return nil, fmt.Errorf("%w: wallet is not deployed and state init is missing", ErrInvalidProof)
+	}
+
+	return publicKeyFromStateInit(walletAddress, stateInit)
+}
+
+func (v *ProofVerifier) getDeployedPublicKey(ctx context.Context, walletAddress *address.Address) (ed25519.PublicKey, error) {
+	block, err := v.client.CurrentMasterchainInfo(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := v.client.RunGetMethod(ctx, block, walletAddress, "get_public_key")
+	if err != nil {
+		return nil, err
+	}
+
+	key, err := result.Int(0)
+	if err != nil {
+		return nil, err
+	}
+
+	publicKey := make([]byte, ed25519.PublicKeySize)
+	key.FillBytes(publicKey)
+
+	return publicKey, nil
+}
+
+func publicKeyFromStateInit(walletAddress *address.Address, stateInit string) (ed25519.PublicKey, error) {
+	boc, err := base64.StdEncoding.DecodeString(stateInit)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed state init", ErrInvalidProof)
+	}
+
+	root, err := cell.FromBOC(boc)
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed state init", ErrInvalidProof)
+	}
+
+	// State init hash is the account id, so its data can be trusted for this address.
+	if !bytes.Equal(root.Hash(), walletAddress.Data()) {
+		return nil, fmt.Errorf("%w: state init does not match wallet address", ErrInvalidProof)
+	}
+
+	var init tlb.StateInit
+	if err := tlb.LoadFromCell(&init, root.BeginParse()); err != nil || init.Data == nil {
+		return nil, fmt.Errorf("%w: malformed state init", ErrInvalidProof)
+	}
+
+	// Wallets v3 and v4 keep seqno and subwallet id before the public key.
+	data := init.Data.BeginParse()
+	if _, err := data.LoadUInt(64); err != nil {
+		return nil, fmt.Errorf("%w: unsupported wallet data", ErrInvalidProof)
+	}
+
+	publicKey, err := data.LoadSlice(256)
+	if err != nil {
+		return nil, fmt.Errorf("%w: unsupported wallet data", ErrInvalidProof)
+	}
+
+	return publicKey, nil
+}
@@ -0,0 +1,242 @@
+package ton
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/xssnick/tonutils-go/address"
+	"github.com/xssnick/tonutils-go/tlb"
+	"github.com/xssnick/tonutils-go/tvm/cell"
+)
+
+const testDomain = "donate.example.com"
+
+// testWallet is a not yet deployed wallet v4 whose address is derived from its state init.
+type testWallet struct {
+	key       ed25519.PrivateKey
+	address   *address.Address
+	stateInit string
+}
+
+func newTestWallet(t *testing.T) *testWallet {
+	t.Helper()
+
+	publicKey, key, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data := cell.BeginCell().
+		MustStoreUInt(0, 32).         // seqno
+		MustStoreUInt(698983191, 32). // subwallet id
+		MustStoreSlice(publicKey, 256).
+		EndCell()
+	code := cell.BeginCell().MustStoreUInt(0xC0DE, 16).EndCell()
+
+	root, err := (&tlb.StateInit{Code: code, Data: data}).ToCell()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testWallet{
+		key:       key,
+		address:   address.NewAddress(0, 0, root.Hash()),
+		stateInit: base64.StdEncoding.EncodeToString(root.ToBOC()),
+	}
+}
+
+// prove signs a proof of the payload for the domain at the time.
+func (w *testWallet) prove(payload string, domain string, signedAt time.Time) *Proof {
+	proof := &Proof{
+		Timestamp: signedAt.Unix(),
+		Domain:    ProofDomain{LengthBytes: uint32(len(domain)), Value: domain},
+		Payload:   payload,
+		StateInit: w.stateInit,
+	}
+	proof.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(w.key, proofHash(w.address, proof)))
+	return proof
+}
+
+func TestProofVerifierVerify(t *testing.T) {
+	ctx := context.Background()
+	wallet := newTestWallet(t)
+	other := newTestWallet(t)
+
+	tests := []struct {
+		name string
+		// proof builds the proof to verify, payloads are issued to subject streamer-1.
+		proof func(v *ProofVerifier, payload string) (*address.Address, *Proof)
+		err   string
+	}{
+		{
+			name: "valid",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				return wallet.address, wallet.prove(payload, testDomain, time.Now())
+			},
+		},
+		{
+			name: "domain is not allowed",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				return wallet.address, wallet.prove(payload, "evil.example.com", time.Now())
+			},
+			err: `invalid ton_proof: unexpected domain "evil.example.com"`,
+		},
+		{
+			name: "domain length does not match",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				proof := wallet.prove(payload, testDomain, time.Now())
+				proof.Domain.LengthBytes++
+				return wallet.address, proof
+			},
+			err: `invalid ton_proof: unexpected domain "donate.example.com"`,
+		},
+		{
+			name: "expired timestamp",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				return wallet.address, wallet.prove(payload, testDomain, time.Now().Add(-ProofTTL-time.Minute))
+			},
+			err: "invalid ton_proof: proof timestamp is out of range",
+		},
+		{
+			name: "timestamp in the future",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				return wallet.address, wallet.prove(payload, testDomain, time.Now().Add(5*time.Minute))
+			},
+			err: "invalid ton_proof: proof timestamp is out of range",
+		},
+		{
+			name: "tampered signature",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				proof := wallet.prove(payload, testDomain, time.Now())
+				signature, _ := base64.StdEncoding.DecodeString(proof.Signature)
+				signature[0] ^= 0xff
+				proof.Signature = base64.StdEncoding.EncodeToString(signature)
+				return wallet.address, proof
+			},
+			err: "invalid ton_proof: signature mismatch",
+		},
+		{
+			name: "signed by another key",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				proof := wallet.prove(payload, testDomain, time.Now())
+				proof.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(other.key, proofHash(wallet.address, proof)))
+				return wallet.address, proof
+			},
+			err: "invalid ton_proof: signature mismatch",
+		},
+		{
+			name: "state init of another wallet",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				proof := other.prove(payload, testDomain, time.Now())
+				proof.Signature = base64.StdEncoding.EncodeToString(ed25519.Sign(other.key, proofHash(wallet.address, proof)))
+				return wallet.address, proof
+			},
+			err: "invalid ton_proof: state init does not match wallet address",
+		},
+		{
+			name: "missing state init",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				proof := wallet.prove(payload, testDomain, time.Now())
+				proof.StateInit = ""
+				return wallet.address, proof
+			},
+			err: "invalid ton_proof: wallet is not deployed and state init is missing",
+		},
+		{
+			name: "payload issued to another subject",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				payload, err := v.IssuePayload(ctx, "streamer-2")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return wallet.address, wallet.prove(payload, testDomain, time.Now())
+			},
+			err: "invalid ton_proof: payload was not issued by this server",
+		},
+		{
+			name: "payload signed with another secret",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				forged := NewProofVerifier(nil, storage.NewMemoryStorage(), "another secret", []string{testDomain})
+				payload, err := forged.IssuePayload(ctx, "streamer-1")
+				if err != nil {
+					t.Fatal(err)
+				}
+				return wallet.address, wallet.prove(payload, testDomain, time.Now())
+			},
+			err: "invalid ton_proof: payload was not issued by this server",
+		},
+		{
+			name: "malformed payload",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				return wallet.address, wallet.prove("payload", testDomain, time.Now())
+			},
+			err: "invalid ton_proof: malformed payload",
+		},
+		{
+			name: "replayed payload",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				proof := wallet.prove(payload, testDomain, time.Now())
+				if err := v.Verify(ctx, wallet.address, proof, "streamer-1"); err != nil {
+					t.Fatalf("expected first proof to pass, got %v", err)
+				}
+				return wallet.address, proof
+			},
+			err: "invalid ton_proof: payload was already used",
+		},
+		{
+			name: "missing proof",
+			proof: func(v *ProofVerifier, payload string) (*address.Address, *Proof) {
+				return wallet.address, nil
+			},
+			err: "invalid ton_proof: proof is required",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			v := NewProofVerifier(nil, storage.NewMemoryStorage(), "secret", []string{"localhost", " " + testDomain})
+			payload, err := v.IssuePayload(ctx, "streamer-1")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			walletAddress, proof := test.proof(v, payload)
+			err = v.Verify(ctx, walletAddress, proof, "streamer-1")
+			if test.err == "" {
+				if err != nil {
+					t.Fatalf("expected proof to pass, got %v", err)
+				}
+				return
+			}
+
+			if !errors.Is(err, ErrInvalidProof) || err.Error() != test.err {
+				t.Fatalf("expected %q, got %v", test.err, err)
+			}
+		})
+	}
+}
+
+func TestProofVerifierFailedProofKeepsPayload(t *testing.T) {
+	ctx := context.Background()
+	wallet := newTestWallet(t)
+	v := NewProofVerifier(nil, storage.NewMemoryStorage(), "secret", []string{testDomain})
+
+	payload, err := v.IssuePayload(ctx, "streamer-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A proof which fails verification must not spend the payload of the client it was issued to.
+	if err := v.Verify(ctx, wallet.address, wallet.prove(payload, "evil.example.com", time.Now()), "streamer-1"); err == nil {
+		t.Fatal("expected proof for another domain to fail")
+	}
+	if err := v.Verify(ctx, wallet.address, wallet.prove(payload, testDomain, time.Now()), "streamer-1"); err != nil {
+		t.Fatalf("expected payload to stay usable, got %v", err)
+	}
+}
@@ -8,7 +8,9 @@ import (
 	"os"
 	"time"
 
+	"github.com/vladtenlive/ton-donate/pkg/prices"
 	"github.com/vladtenlive/ton-donate/pkg/storage"
+	"github.com/vladtenlive/ton-donate/pkg/utils"
 	"github.com/xssnick/tonutils-go/address"
 	"github.com/xssnick/tonutils-go/liteclient"
 	"github.com/xssnick/tonutils-go/tlb"
@@ -16,20 +18,30 @@ import (
 )
 
 type Connector struct {
-	Address      *address.Address
-	Network      string
-	Client       *ton.APIClient
-	storage      storage.Storage
-	mongoStorage *storage.MongoStorage
-	notifier     *Notifier
+	Address   *address.Address
+	Network   string
+	Client    *ton.APIClient
+	streamers storage.StreamerRepository
+	donations storage.DonationRepository
+	widgets   storage.WidgetRepository
+	sessions  storage.SessionRepository
+	notifier  *Notifier
+	prices    prices.Provider
+	chat      ChatSender
+}
+
+// ChatSender posts donation alerts to the chat of streamer's linked Twitch channel.
+type ChatSender interface {
+	SendChatMessage(ctx context.Context, broadcasterUserId string, message string) error
 }
 
 func New(
 	ctx context.Context,
 	watchAddress string,
-	storage storage.Storage,
-	mongoStorage *storage.MongoStorage,
+	repositories storage.Repositories,
 	notifier *Notifier,
+	prices prices.Provider,
+	chat ChatSender,
 ) (*Connector, error) {
 	connPool := liteclient.NewConnectionPool()
 	configUrl := os.Getenv("TON_CONFIG_URL")
@@ -42,12 +54,16 @@ func New(
 	client := ton.NewAPIClient(connPool)
 
 	return &Connector{
-		storage:      storage,
-		mongoStorage: mongoStorage,
-		Address:      address.MustParseAddr(watchAddress),
-		Client:       client,
-		notifier:     notifier,
-		Network:      os.Getenv("TON_NET"),
+		streamers: repositories.Streamers,
+		donations: repositories.Donations,
+		widgets:   repositories.Widgets,
+		sessions:  repositories.Sessions,
+		Address:   address.MustParseAddr(watchAddress),
+		Client:    client,
+		notifier:  notifier,
+		prices:    prices,
+		chat:      chat,
+		Network:   os.Getenv("TON_NET"),
 	}, nil
 }
 
@@ -94,14 +110,14 @@ func (c *Connector) GetTransactions(ctx context.Context) {
 	for _, tx := range txs {
 		transaction := parseBody(tx)
 
-		donation, err := c.mongoStorage.GetDonationBySign(ctx, transaction.Sign)
-		if err != nil {
-			log.Println("(1) GetDonationBySign: ", err)
-			// ToDo: just skip for now, later we can figure out
+		if transaction.Sign == "" { // ToDO: why some transactions have empty sign and wallet?
 			continue
 		}
 
-		if (donation != nil && donation.Sign == "") || transaction.Sign == "" { // ToDO: why some transactions have empty sign and wallet?
+		donation, err := c.donations.GetDonationBySign(ctx, transaction.Sign)
+		if err != nil {
+			log.Println("GetDonationBySign: ", err)
+			// ToDo: just skip for now, later we can figure out
 			continue
 		}
 
@@ -111,15 +127,12 @@ func (c *Connector) GetTransactions(ctx context.Context) {
 			continue
 		}
 
-		_, err = c.mongoStorage.SaveDonation(ctx, transaction, streamerId)
+		donation, err = c.donations.SaveDonation(ctx, transaction, streamerId)
 		if err != nil {
 			log.Println("Failed to save donation transaction info: ", err)
-		}
-
-		donation, err = c.mongoStorage.GetDonationBySign(ctx, transaction.Sign)
-		if err != nil {
-			log.Println("(2) GetDonationBySign: ", err)
-			// ToDo: just skip for now, later we can figure out
+			continue
+		} else if donation == nil {
+			log.Println("Donation transaction info was not saved: ", transaction.TxHash)
 			continue
 		}
 
@@ -128,11 +141,24 @@ func (c *Connector) GetTransactions(ctx context.Context) {
 			continue
 		}
 
+		if donation.DuplicateOf != "" {
+			log.Println("Transaction reuses sign of another donation, processing it separately: ", transaction.TxHash)
+		}
+
 		// fmt.Println("transaction: ", transaction)
 		donationAmount := uint64(transaction.Amount)
+
+		// Rate is fixed at acknowledgement, donation is still processed without fiat values if it is unavailable.
+		fiatRates, err := c.prices.Rates(ctx, prices.FiatCurrencies())
+		if err != nil {
+			log.Println("Failed to load fiat rates: ", err)
+			fiatRates = nil
+		}
+
 		notificationReq := NotificationRequest{
 			Id:         fmt.Sprintf(transaction.TxHash), // or could be d.Sign depends on Storage
 			Amount:     donationAmount,
+			Fiat:       prices.FiatAmounts(donationAmount, fiatRates),
 			Text:       transaction.Message,
 			Nickname:   donation.From,
 			StreamerId: donation.StreamerId,
@@ -146,28 +172,88 @@ func (c *Connector) GetTransactions(ctx context.Context) {
 				log.Println(err)
 			}
 		} else {
-			_, err = c.mongoStorage.AckDonation(ctx, transaction)
+			sessionId := ""
+			session, err := c.sessions.GetActiveSession(ctx, donation.StreamerId)
+			if err != nil {
+				log.Println("Failed to load active stream session: ", err)
+			} else if session != nil {
+				sessionId = session.Id.Hex()
+			}
+
+			err = c.donations.AckDonation(ctx, transaction, storage.DonationAck{
+				FiatRates: fiatRates,
+				SessionId: sessionId,
+			})
 			if err != nil {
 				log.Println("Failed to ack donation with sign: ", transaction.Sign)
 				return
 			}
 
-			_, err = c.mongoStorage.AddToCurrentAmount(ctx, donation.StreamerId, donationAmount)
+			c.sendChatAlert(ctx, notificationReq)
+
+			credit, err := c.widgets.AddToCurrentAmount(ctx, donation.StreamerId, donation.WidgetId, donationAmount, prices.FiatCents(donationAmount, fiatRates))
 			if err != nil {
 				log.Println("Failed to add donation to widget total sum: ", transaction.Sign)
 			}
+			if credit == nil {
+				continue
+			}
+
+			for _, goal := range credit.Skipped {
+				log.Printf("No %s rate to credit goal widget %s with donation %s", goal.Currency, goal.Id.Hex(), transaction.Sign)
+			}
+
+			for _, goal := range credit.Completed {
+				err = c.notifier.SendGoalCompleted(GoalCompletedRequest{
+					WidgetId:      goal.Id.Hex(),
+					Title:         goal.Title,
+					AmountGoal:    goal.AmountGoal,
+					AmountCurrent: goal.AmountCurrent,
+					Currency:      goal.Currency,
+					StreamerId:    goal.StreamerId,
+				})
+				if err != nil {
+					log.Println("Failed to send goal completion: ", err)
+				}
+			}
 		}
 	}
 }
 
+func (c *Connector) sendChatAlert(ctx context.Context, r NotificationRequest) {
+	if c.chat == nil {
+		return
+	}
+
+	streamer, err := c.streamers.GetStreamerByStreamerId(ctx, r.StreamerId)
+	if err != nil || streamer == nil || streamer.Twitch == nil {
+		return
+	}
+
+	message := fmt.Sprintf("%s donated %s TON", r.Nickname, tlb.FromNanoTONU(r.Amount).TON())
+	if r.Text != "" {
+		message += ": " + r.Text
+	}
+
+	// Twitch chat messages are limited to 500 characters.
+	if runes := []rune(message); len(runes) > 500 {
+		message = string(runes[:500])
+	}
+
+	if err := c.chat.SendChatMessage(ctx, streamer.Twitch.UserId, message); err != nil {
+		log.Println("Failed to send donation alert to Twitch chat: ", err)
+	}
+}
+
 func parseBody(trx *tlb.Transaction) storage.Tx {
 	txHash := fmt.Sprintf("%x", trx.Hash)
 
 	payload := trx.IO.In.Msg.Payload().BeginParse()
 	payload.LoadUInt(32) // skip op code
+	walletAddress := ""
 	streamerAddress, err := payload.LoadAddr()
-	if err == nil {
-		// fmt.Println(streamerAddress)
+	if err == nil && streamerAddress.Type() == address.StdAddress {
+		walletAddress = utils.FormatWalletAddress(streamerAddress)
 	}
 	sign, err := payload.LoadStringSnake()
 	if err == nil {
@@ -176,14 +262,22 @@ func parseBody(trx *tlb.Transaction) storage.Tx {
 
 	txInfo := trx.IO.In.AsInternal()
 
+	senderAddress := ""
+	if txInfo.SrcAddr != nil && txInfo.SrcAddr.Type() == address.StdAddress {
+		senderAddress = utils.FormatWalletAddress(txInfo.SrcAddr)
+	}
+
 	transaction := storage.Tx{
 		Sign:          sign,
 		Message:       txInfo.Comment(),
 		TxHash:        txHash,
-		WalletAddress: fmt.Sprintf("%s", streamerAddress),
+		WalletAddress: walletAddress,
+		SenderAddress: senderAddress,
+		Currency:      storage.CurrencyTON,
 		Amount:        txInfo.Amount.NanoTON().Uint64(),
 		Lt:            trx.LT,
 		Acked:         false,
+		CreatedAt:     time.Unix(int64(trx.Now), 0).UTC(),
 	}
 
 	return transaction
@@ -192,10 +286,13 @@ func parseBody(trx *tlb.Transaction) storage.Tx {
 func getOrLoadStreamerId(ctx context.Context, c *Connector, donation *storage.Donation, transaction storage.Tx) (string, error) {
 	if donation == nil || donation.StreamerId == "" {
 		log.Println("Mapping streamer id by transaction wallet address. Possibly donation request failed to save.")
-		streamer, err := c.mongoStorage.GetStreamerByWalletAddress(ctx, transaction.WalletAddress)
+		// Retired wallets are matched too, so donations sent before rotation keep their streamer.
+		streamer, err := c.streamers.GetStreamerByWalletAddress(ctx, transaction.WalletAddress)
 		if err != nil {
 			log.Println("Failed to map streamer id by transaction wallet address.")
 			return "", err
+		} else if streamer == nil {
+			return "", errors.New("no streamer with transaction wallet address")
 		}
 
 		return streamer.StreamerId, nil
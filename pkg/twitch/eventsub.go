@@ -0,0 +1,71 @@
+package twitch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// EventSub message types and subscription types we handle.
+const (
+	MessageTypeVerification = "webhook_callback_verification"
+	MessageTypeNotification = "notification"
+	MessageTypeRevocation   = "revocation"
+
+	EventStreamOnline  = "stream.online"
+	EventStreamOffline = "stream.offline"
+
+	// MaxMessageAge is how old messages are rejected, ids of newer ones are kept to drop redeliveries.
+	MaxMessageAge = 10 * time.Minute
+)
+
+var ErrInvalidSignature = errors.New("invalid eventsub signature")
+
+type EventSubMessage struct {
+	Challenge    string `json:"challenge"`
+	Subscription struct {
+		Id     string `json:"id"`
+		Type   string `json:"type"`
+		Status string `json:"status"`
+	} `json:"subscription"`
+	Event struct {
+		Id                   string `json:"id"`
+		BroadcasterUserId    string `json:"broadcaster_user_id"`
+		BroadcasterUserLogin string `json:"broadcaster_user_login"`
+		Type                 string `json:"type"`
+		StartedAt            string `json:"started_at"`
+	} `json:"event"`
+}
+
+// VerifyEventSub checks HMAC signature and age of EventSub message and parses it.
+func (c *Client) VerifyEventSub(header http.Header, body []byte) (*EventSubMessage, error) {
+	messageId := header.Get("Twitch-Eventsub-Message-Id")
+	timestamp := header.Get("Twitch-Eventsub-Message-Timestamp")
+	signature := header.Get("Twitch-Eventsub-Message-Signature")
+
+	mac := hmac.New(sha256.New, []byte(c.config.EventSubSecret))
+	mac.Write([]byte(messageId))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+	expected := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	if c.config.EventSubSecret == "" || !hmac.Equal([]byte(expected), []byte(signature)) {
+		return nil, ErrInvalidSignature
+	}
+
+	sentAt, err := time.Parse(time.RFC3339Nano, timestamp)
+	if err != nil || time.Since(sentAt) > MaxMessageAge {
+		return nil, ErrInvalidSignature
+	}
+
+	var message EventSubMessage
+	if err := json.Unmarshal(body, &message); err != nil {
+		return nil, err
+	}
+
+	return &message, nil
+}
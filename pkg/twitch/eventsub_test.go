@@ -0,0 +1,123 @@
+package twitch
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// signEventSub sets the headers Twitch sends with an EventSub message signed by secret.
+func signEventSub(header http.Header, secret string, messageId string, sentAt time.Time, body []byte) {
+	timestamp := sentAt.UTC().Format(time.RFC3339Nano)
+
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(messageId))
+	mac.Write([]byte(timestamp))
+	mac.Write(body)
+
+	header.Set("Twitch-Eventsub-Message-Id", messageId)
+	header.Set("Twitch-Eventsub-Message-Timestamp", timestamp)
+	header.Set("Twitch-Eventsub-Message-Signature", "sha256="+hex.EncodeToString(mac.Sum(nil)))
+}
+
+func TestVerifyEventSub(t *testing.T) {
+	body := []byte(`{"subscription": {"type": "stream.online"}, "event": {"broadcaster_user_id": "42"}}`)
+
+	tests := []struct {
+		name   string
+		secret string
+		header func() http.Header
+		err    bool
+	}{
+		{
+			name: "valid signature",
+			header: func() http.Header {
+				header := http.Header{}
+				signEventSub(header, "secret", "message-1", time.Now(), body)
+				return header
+			},
+		},
+		{
+			name: "signed with another secret",
+			header: func() http.Header {
+				header := http.Header{}
+				signEventSub(header, "other", "message-1", time.Now(), body)
+				return header
+			},
+			err: true,
+		},
+		{
+			name: "message id replaced",
+			header: func() http.Header {
+				header := http.Header{}
+				signEventSub(header, "secret", "message-1", time.Now(), body)
+				header.Set("Twitch-Eventsub-Message-Id", "message-2")
+				return header
+			},
+			err: true,
+		},
+		{
+			name: "body signed differently",
+			header: func() http.Header {
+				header := http.Header{}
+				signEventSub(header, "secret", "message-1", time.Now(), []byte(`{}`))
+				return header
+			},
+			err: true,
+		},
+		{
+			name: "stale timestamp",
+			header: func() http.Header {
+				header := http.Header{}
+				signEventSub(header, "secret", "message-1", time.Now().Add(-MaxMessageAge-time.Minute), body)
+				return header
+			},
+			err: true,
+		},
+		{
+			name: "missing signature",
+			header: func() http.Header {
+				return http.Header{}
+			},
+			err: true,
+		},
+		{
+			name:   "secret is not configured",
+			secret: "-",
+			header: func() http.Header {
+				header := http.Header{}
+				signEventSub(header, "", "message-1", time.Now(), body)
+				return header
+			},
+			err: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			secret := "secret"
+			if test.secret == "-" {
+				secret = ""
+			}
+			c := NewClient(http.DefaultClient, Config{EventSubSecret: secret})
+
+			message, err := c.VerifyEventSub(test.header(), body)
+			if test.err {
+				if err != ErrInvalidSignature {
+					t.Fatalf("expected ErrInvalidSignature, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatal(err)
+			}
+			if message.Subscription.Type != EventStreamOnline || message.Event.BroadcasterUserId != "42" {
+				t.Fatalf("unexpected message %+v", message)
+			}
+		})
+	}
+}
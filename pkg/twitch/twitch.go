@@ -0,0 +1,336 @@
+package twitch
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	authUrl  = "https://id.twitch.tv/oauth2"
+	helixUrl = "https://api.twitch.tv/helix"
+
+	// State of OAuth link flow is valid for this long.
+	stateTTL = 10 * time.Minute
+)
+
+var ErrInvalidState = errors.New("invalid twitch oauth state")
+
+type Config struct {
+	ClientId     string
+	ClientSecret string
+	RedirectUrl  string
+
+	EventSubCallbackUrl string
+	EventSubSecret      string
+
+	// Optional bot account posting donation alerts to chat.
+	BotUserId       string
+	BotAccessToken  string
+	BotRefreshToken string
+}
+
+// ConfigFromEnv reads TWITCH_* variables, Twitch integration is disabled when client id is empty.
+func ConfigFromEnv() Config {
+	return Config{
+		ClientId:            os.Getenv("TWITCH_CLIENT_ID"),
+		ClientSecret:        os.Getenv("TWITCH_CLIENT_SECRET"),
+		RedirectUrl:         os.Getenv("TWITCH_REDIRECT_URL"),
+		EventSubCallbackUrl: os.Getenv("TWITCH_EVENTSUB_CALLBACK_URL"),
+		EventSubSecret:      os.Getenv("TWITCH_EVENTSUB_SECRET"),
+		BotUserId:           os.Getenv("TWITCH_BOT_USER_ID"),
+		BotAccessToken:      os.Getenv("TWITCH_BOT_ACCESS_TOKEN"),
+		BotRefreshToken:     os.Getenv("TWITCH_BOT_REFRESH_TOKEN"),
+	}
+}
+
+type Client struct {
+	client *http.Client
+	config Config
+
+	mu             sync.Mutex
+	appToken       string
+	appTokenExpiry time.Time
+	botToken       string
+	botRefresh     string
+}
+
+func NewClient(client *http.Client, config Config) *Client {
+	return &Client{
+		client:     client,
+		config:     config,
+		botToken:   config.BotAccessToken,
+		botRefresh: config.BotRefreshToken,
+	}
+}
+
+type User struct {
+	Id          string `json:"id"`
+	Login       string `json:"login"`
+	DisplayName string `json:"display_name"`
+}
+
+type token struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int64  `json:"expires_in"`
+}
+
+// AuthorizeUrl returns Twitch consent page url, state binds the callback to the streamer.
+func (c *Client) AuthorizeUrl(streamerId string) string {
+	query := url.Values{
+		"client_id":     {c.config.ClientId},
+		"redirect_uri":  {c.config.RedirectUrl},
+		"response_type": {"code"},
+		"state":         {c.signState(streamerId, time.Now().Add(stateTTL))},
+	}
+
+	return authUrl + "/authorize?" + query.Encode()
+}
+
+func (c *Client) signState(streamerId string, expiresAt time.Time) string {
+	payload := streamerId + "|" + strconv.FormatInt(expiresAt.Unix(), 10)
+	mac := hmac.New(sha256.New, []byte(c.config.ClientSecret))
+	mac.Write([]byte(payload))
+
+	return base64.RawURLEncoding.EncodeToString([]byte(payload + "|" + hex.EncodeToString(mac.Sum(nil))))
+}
+
+// VerifyState returns streamer id the state was issued for.
+func (c *Client) VerifyState(state string) (string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(state)
+	if err != nil {
+		return "", ErrInvalidState
+	}
+
+	parts := strings.Split(string(data), "|")
+	if len(parts) != 3 {
+		return "", ErrInvalidState
+	}
+
+	expiresAt, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || time.Now().Unix() > expiresAt {
+		return "", ErrInvalidState
+	}
+
+	if !hmac.Equal([]byte(state), []byte(c.signState(parts[0], time.Unix(expiresAt, 0)))) {
+		return "", ErrInvalidState
+	}
+
+	return parts[0], nil
+}
+
+// GetLinkedUser exchanges authorization code and returns the Twitch user who gave consent.
+func (c *Client) GetLinkedUser(ctx context.Context, code string) (*User, error) {
+	userToken, err := c.requestToken(ctx, url.Values{
+		"grant_type":   {"authorization_code"},
+		"code":         {code},
+		"redirect_uri": {c.config.RedirectUrl},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var response struct {
+		Data []User `json:"data"`
+	}
+	err = c.helix(ctx, http.MethodGet, "/users", userToken.AccessToken, nil, &response)
+	if err != nil {
+		return nil, err
+	} else if len(response.Data) == 0 {
+		return nil, errors.New("twitch user not found")
+	}
+
+	return &response.Data[0], nil
+}
+
+// Subscribe creates EventSub webhook subscription for the broadcaster, existing subscription is not an error.
+func (c *Client) Subscribe(ctx context.Context, eventType string, broadcasterUserId string) error {
+	appToken, err := c.getAppToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	body := map[string]any{
+		"type":      eventType,
+		"version":   "1",
+		"condition": map[string]string{"broadcaster_user_id": broadcasterUserId},
+		"transport": map[string]string{
+			"method":   "webhook",
+			"callback": c.config.EventSubCallbackUrl,
+			"secret":   c.config.EventSubSecret,
+		},
+	}
+
+	err = c.helix(ctx, http.MethodPost, "/eventsub/subscriptions", appToken, body, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusConflict {
+		return nil
+	}
+
+	return err
+}
+
+// SendChatMessage posts message to broadcaster chat from the bot account.
+func (c *Client) SendChatMessage(ctx context.Context, broadcasterUserId string, message string) error {
+	if !c.HasChatBot() {
+		return nil
+	}
+
+	body := map[string]string{
+		"broadcaster_id": broadcasterUserId,
+		"sender_id":      c.config.BotUserId,
+		"message":        message,
+	}
+
+	c.mu.Lock()
+	botToken, refreshable := c.botToken, c.botRefresh != ""
+	c.mu.Unlock()
+
+	err := c.helix(ctx, http.MethodPost, "/chat/messages", botToken, body, nil)
+	var apiErr *APIError
+	if errors.As(err, &apiErr) && apiErr.Status == http.StatusUnauthorized && refreshable {
+		if botToken, err = c.refreshBotToken(ctx, botToken); err != nil {
+			return err
+		}
+		err = c.helix(ctx, http.MethodPost, "/chat/messages", botToken, body, nil)
+	}
+
+	return err
+}
+
+func (c *Client) HasChatBot() bool {
+	return c.config.BotUserId != "" && c.config.BotAccessToken != ""
+}
+
+// refreshBotToken replaces the rejected bot token, unless a concurrent request has replaced it already:
+// refresh tokens are rotated, so refreshing again with the old one would fail.
+func (c *Client) refreshBotToken(ctx context.Context, rejected string) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.botToken != rejected {
+		return c.botToken, nil
+	}
+
+	botToken, err := c.requestToken(ctx, url.Values{
+		"grant_type":    {"refresh_token"},
+		"refresh_token": {c.botRefresh},
+	})
+	if err != nil {
+		return "", err
+	}
+
+	c.botToken = botToken.AccessToken
+	if botToken.RefreshToken != "" {
+		c.botRefresh = botToken.RefreshToken
+	}
+
+	return c.botToken, nil
+}
+
+func (c *Client) getAppToken(ctx context.Context) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.appToken != "" && time.Now().Before(c.appTokenExpiry) {
+		return c.appToken, nil
+	}
+
+	appToken, err := c.requestToken(ctx, url.Values{"grant_type": {"client_credentials"}})
+	if err != nil {
+		return "", err
+	}
+
+	c.appToken = appToken.AccessToken
+	c.appTokenExpiry = time.Now().Add(time.Duration(appToken.ExpiresIn)*time.Second - time.Minute)
+
+	return c.appToken, nil
+}
+
+func (c *Client) requestToken(ctx context.Context, form url.Values) (*token, error) {
+	form.Set("client_id", c.config.ClientId)
+	form.Set("client_secret", c.config.ClientSecret)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, authUrl+"/token", strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	var result token
+	if err := c.do(req, &result); err != nil {
+		return nil, err
+	}
+
+	return &result, nil
+}
+
+func (c *Client) helix(ctx context.Context, method string, path string, accessToken string, body any, result any) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, helixUrl+path, reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Client-Id", c.config.ClientId)
+	req.Header.Set("Authorization", "Bearer "+accessToken)
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+
+	return c.do(req, result)
+}
+
+// APIError is a non successful response of Twitch API.
+type APIError struct {
+	Status  int
+	Message string
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("twitch api responded with status %d: %s", e.Status, e.Message)
+}
+
+func (c *Client) do(req *http.Request, result any) error {
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		var body struct {
+			Message string `json:"message"`
+		}
+		json.NewDecoder(resp.Body).Decode(&body)
+		return &APIError{Status: resp.StatusCode, Message: body.Message}
+	}
+
+	if result == nil {
+		return nil
+	}
+
+	return json.NewDecoder(resp.Body).Decode(result)
+}
@@ -0,0 +1,162 @@
+package twitch
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestVerifyState(t *testing.T) {
+	c := NewClient(http.DefaultClient, Config{ClientId: "client", ClientSecret: "secret"})
+
+	stateOf := func(authorizeUrl string) string {
+		parsed, err := url.Parse(authorizeUrl)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return parsed.Query().Get("state")
+	}
+
+	tamper := func(state string, streamerId string) string {
+		data, _ := base64.RawURLEncoding.DecodeString(state)
+		parts := strings.Split(string(data), "|")
+		parts[0] = streamerId
+		return base64.RawURLEncoding.EncodeToString([]byte(strings.Join(parts, "|")))
+	}
+
+	tests := []struct {
+		name       string
+		state      string
+		streamerId string
+	}{
+		{
+			name:       "issued state",
+			state:      stateOf(c.AuthorizeUrl("streamer-1")),
+			streamerId: "streamer-1",
+		},
+		{
+			name:  "expired state",
+			state: c.signState("streamer-1", time.Now().Add(-time.Second)),
+		},
+		{
+			name:  "streamer replaced",
+			state: tamper(stateOf(c.AuthorizeUrl("streamer-1")), "streamer-2"),
+		},
+		{
+			name:  "signed with another secret",
+			state: stateOf(NewClient(http.DefaultClient, Config{ClientSecret: "other"}).AuthorizeUrl("streamer-1")),
+		},
+		{
+			name:  "malformed",
+			state: "not a state",
+		},
+		{
+			name:  "empty",
+			state: "",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			streamerId, err := c.VerifyState(test.state)
+			if test.streamerId == "" {
+				if err != ErrInvalidState {
+					t.Fatalf("expected ErrInvalidState, got %q, %v", streamerId, err)
+				}
+				return
+			}
+
+			if err != nil || streamerId != test.streamerId {
+				t.Fatalf("expected %q, got %q, %v", test.streamerId, streamerId, err)
+			}
+		})
+	}
+}
+
+// routeTo sends requests of every host to handler.
+type routeTo struct {
+	handler http.Handler
+}
+
+func (r routeTo) RoundTrip(req *http.Request) (*http.Response, error) {
+	w := httptest.NewRecorder()
+	r.handler.ServeHTTP(w, req)
+	return w.Result(), nil
+}
+
+func TestSendChatMessageRefreshesBotTokenOnce(t *testing.T) {
+	var mu sync.Mutex
+	validToken := "fresh"
+	refreshes := 0
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/oauth2/token", func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+
+		mu.Lock()
+		defer mu.Unlock()
+		if r.Form.Get("refresh_token") != "refresh-1" {
+			// Refresh tokens are single use.
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		refreshes++
+		json.NewEncoder(w).Encode(token{AccessToken: validToken, RefreshToken: "refresh-2"})
+	})
+	// Every sender is rejected before any refreshes, so that refreshes are concurrent.
+	const senders = 8
+	rejected := 0
+	allRejected := make(chan struct{})
+	mux.HandleFunc("/helix/chat/messages", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(io.Discard, r.Body)
+		if r.Header.Get("Authorization") == "Bearer "+validToken {
+			w.WriteHeader(http.StatusOK)
+			return
+		}
+
+		mu.Lock()
+		if rejected++; rejected == senders {
+			close(allRejected)
+		}
+		mu.Unlock()
+
+		<-allRejected
+		w.WriteHeader(http.StatusUnauthorized)
+	})
+
+	c := NewClient(&http.Client{Transport: routeTo{mux}}, Config{
+		ClientId:        "client",
+		BotUserId:       "bot",
+		BotAccessToken:  "expired",
+		BotRefreshToken: "refresh-1",
+	})
+
+	var wg sync.WaitGroup
+	errs := make(chan error, senders)
+	for i := 0; i < senders; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			errs <- c.SendChatMessage(context.Background(), "broadcaster", "Thanks!")
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("expected every message to be sent, got %v", err)
+		}
+	}
+	if refreshes != 1 {
+		t.Fatalf("expected one refresh, got %d", refreshes)
+	}
+}
@@ -0,0 +1,111 @@
+package utils
+
+import (
+	"encoding/hex"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/xssnick/tonutils-go/address"
+)
+
+var ErrInvalidWalletAddress = errors.New("invalid TON wallet address")
+var ErrWrongNetworkAddress = errors.New("TON wallet address belongs to another network")
+
+// ParseWalletAddress accepts user friendly (bounceable or not, url safe or standard base64)
+// and raw "<workchain>:<hex>" addresses and checks them against TON_NET: user friendly addresses
+// must carry the testnet flag on testnet and must not carry it on mainnet. Raw addresses have
+// no network flag and are accepted on both.
+func ParseWalletAddress(walletAddress string) (*address.Address, error) {
+	addr, friendly, err := parseWalletAddress(walletAddress)
+	if err != nil {
+		return nil, err
+	}
+
+	if friendly && addr.IsTestnetOnly() != isTestnet() {
+		return nil, ErrWrongNetworkAddress
+	}
+
+	return addr, nil
+}
+
+// CanonicalWalletAddress reformats an address stored before addresses were normalized,
+// its network flag is not checked.
+func CanonicalWalletAddress(walletAddress string) (string, error) {
+	addr, _, err := parseWalletAddress(walletAddress)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatWalletAddress(addr), nil
+}
+
+// parseWalletAddress parses an address of either form, friendly is false for raw addresses.
+func parseWalletAddress(walletAddress string) (addr *address.Address, friendly bool, err error) {
+	walletAddress = strings.TrimSpace(walletAddress)
+
+	if strings.Contains(walletAddress, ":") {
+		parts := strings.SplitN(walletAddress, ":", 2)
+		workchain, err := strconv.ParseInt(parts[0], 10, 8)
+		if err != nil {
+			return nil, false, ErrInvalidWalletAddress
+		}
+
+		data, err := hex.DecodeString(parts[1])
+		if err != nil || len(data) != 32 {
+			return nil, false, ErrInvalidWalletAddress
+		}
+
+		addr = address.NewAddress(0, byte(workchain), data)
+	} else {
+		replacer := strings.NewReplacer("+", "-", "/", "_")
+
+		addr, err = address.ParseAddr(replacer.Replace(walletAddress))
+		if err != nil {
+			return nil, false, ErrInvalidWalletAddress
+		}
+		friendly = true
+	}
+
+	if addr.Workchain() != 0 && addr.Workchain() != -1 {
+		return nil, false, ErrInvalidWalletAddress
+	}
+
+	return addr, friendly, nil
+}
+
+// NormalizeWalletAddress returns the canonical form addresses are stored and matched in.
+func NormalizeWalletAddress(walletAddress string) (string, error) {
+	addr, err := ParseWalletAddress(walletAddress)
+	if err != nil {
+		return "", err
+	}
+
+	return FormatWalletAddress(addr), nil
+}
+
+// FormatWalletAddress formats an address as bounceable url safe string flagged for the TON_NET network.
+func FormatWalletAddress(addr *address.Address) string {
+	canonical := address.NewAddress(0, byte(addr.Workchain()), addr.Data())
+	canonical.SetBounce(true)
+	canonical.SetTestnetOnly(isTestnet())
+
+	return canonical.String()
+}
+
+// CanonicalWalletAddressPattern is a regular expression matching addresses formatted by FormatWalletAddress,
+// it checks the bounceable and network flags of basechain and masterchain addresses but not the checksum.
+func CanonicalWalletAddressPattern() string {
+	// Base64 of the flags byte and of the first bits of the workchain byte, 0 or -1.
+	prefix := "E[Qf]"
+	if isTestnet() {
+		prefix = "k[Qf]"
+	}
+
+	return "^" + prefix + "[A-Za-z0-9_-]{46}$"
+}
+
+func isTestnet() bool {
+	return os.Getenv("TON_NET") != "mainnet"
+}
@@ -0,0 +1,46 @@
+package utils
+
+import (
+	"fmt"
+	"regexp"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+)
+
+func TestCanonicalWalletAddressPattern(t *testing.T) {
+	data := make([]byte, 32)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+
+	for _, network := range []string{"mainnet", "testnet"} {
+		t.Run(network, func(t *testing.T) {
+			t.Setenv("TON_NET", network)
+			pattern := regexp.MustCompile(CanonicalWalletAddressPattern())
+
+			for _, workchain := range []int32{0, -1} {
+				addr := address.NewAddress(0, byte(workchain), data)
+				canonical := FormatWalletAddress(addr)
+				if !pattern.MatchString(canonical) {
+					t.Fatalf("expected canonical %s to match", canonical)
+				}
+
+				nonBounceable := address.NewAddress(0, byte(workchain), data)
+				nonBounceable.SetBounce(false)
+				nonBounceable.SetTestnetOnly(network == "testnet")
+				otherNetwork := address.NewAddress(0, byte(workchain), data)
+				otherNetwork.SetTestnetOnly(network != "testnet")
+
+				for _, stored := range []string{nonBounceable.String(), otherNetwork.String(), fmt.Sprintf("%d:%x", workchain, data)} {
+					if pattern.MatchString(stored) {
+						t.Fatalf("expected %s not to match", stored)
+					}
+					if formatted, err := CanonicalWalletAddress(stored); err != nil || formatted != canonical {
+						t.Fatalf("expected %s to migrate to %s, got %s, %v", stored, canonical, formatted, err)
+					}
+				}
+			}
+		})
+	}
+}
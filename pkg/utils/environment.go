@@ -4,18 +4,47 @@ import "os"
 
 func ValidateEnvVariables() {
 	envVarNames := []string{
-		"DB_NAME",
-		"DB_CONNECTION",
-		"DB_STREAMERS_COLLECTION_NAME",
-		"DB_DONATIONS_COLLECTION_NAME",
-		"DB_WIDGETS_COLLECTION_NAME",
 		"PORT",
 		"CONTRACT_ADDRESS",
 		"TON_NET",
 		"NOTIFICATION_URL",
-		"TON_CONFIG_URL",
-		"COGNITO_REGION",
-		"COGNITO_USER_POOL_ID",
+		"TON_PROOF_SECRET",
+		"TON_PROOF_DOMAINS",
+	}
+
+	// Database and liteserver settings depend on the storage backend, in-memory storage runs offline
+	// and watches transactions only when TON_CONFIG_URL is set.
+	switch os.Getenv("STORAGE_BACKEND") {
+	case "memory":
+	case "postgres":
+		envVarNames = append(envVarNames, "TON_CONFIG_URL", "POSTGRES_CONNECTION")
+	default:
+		envVarNames = append(envVarNames,
+			"TON_CONFIG_URL",
+			"DB_NAME",
+			"DB_CONNECTION",
+			"DB_STREAMERS_COLLECTION_NAME",
+			"DB_DONATIONS_COLLECTION_NAME",
+			"DB_WIDGETS_COLLECTION_NAME",
+			"DB_SESSIONS_COLLECTION_NAME",
+			"DB_REFRESH_TOKENS_COLLECTION_NAME",
+			"DB_GRANTS_COLLECTION_NAME",
+			"DB_NONCES_COLLECTION_NAME",
+		)
+	}
+
+	// Identity provider settings depend on AUTH_PROVIDER.
+	switch os.Getenv("AUTH_PROVIDER") {
+	case "oidc":
+		envVarNames = append(envVarNames, "OIDC_ISSUER", "OIDC_AUDIENCES")
+	case "static":
+		envVarNames = append(envVarNames, "AUTH_STATIC_SECRET")
+	default:
+		envVarNames = append(envVarNames,
+			"COGNITO_REGION",
+			"COGNITO_USER_POOL_ID",
+			"COGNITO_CLIENT_IDS",
+		)
 	}
 
 	for _, envVarName := range envVarNames {
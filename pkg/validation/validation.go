@@ -0,0 +1,168 @@
+// Package validation checks request payloads against rules declared in `validate` struct tags.
+package validation
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/vladtenlive/ton-donate/pkg/utils"
+)
+
+// Struct validates exported fields of a struct by their `validate` tags, for example
+// `validate:"required,max=64"`, and returns problems keyed by JSON field name, nil when valid.
+//
+// Rules:
+//
+//	required     string is not empty, number is not zero, pointer is not nil
+//	min=N, max=N characters of a string, value of a number
+//	oneof=a b    string is one of space separated values
+//	ton_address  string is a TON wallet address of the TON_NET network
+//	url          string is an absolute http or https url
+//
+// Other rules than required are skipped for empty strings and nil pointers.
+func Struct(v any) map[string]string {
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic("validation: expected struct, got " + value.Kind().String())
+	}
+
+	problems := map[string]string{}
+	valueType := value.Type()
+	for i := 0; i < valueType.NumField(); i++ {
+		field := valueType.Field(i)
+		tag := field.Tag.Get("validate")
+		if tag == "" || !field.IsExported() {
+			continue
+		}
+
+		if problem := check(value.Field(i), strings.Split(tag, ",")); problem != "" {
+			problems[fieldName(field)] = problem
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return problems
+}
+
+// fieldName is the name clients send the field by.
+func fieldName(field reflect.StructField) string {
+	name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+	if name == "" || name == "-" {
+		return field.Name
+	}
+	return name
+}
+
+func check(value reflect.Value, rules []string) string {
+	if value.Kind() == reflect.Pointer {
+		if value.IsNil() {
+			if contains(rules, "required") {
+				return "is required"
+			}
+			return ""
+		}
+		value = value.Elem()
+	}
+
+	if value.Kind() == reflect.String && value.String() == "" {
+		if contains(rules, "required") {
+			return "is required"
+		}
+		return ""
+	}
+
+	for _, rule := range rules {
+		name, arg, _ := strings.Cut(rule, "=")
+
+		var problem string
+		switch name {
+		case "required":
+			if value.IsZero() {
+				problem = "is required"
+			}
+		case "min", "max":
+			problem = checkBound(value, name, arg)
+		case "oneof":
+			options := strings.Fields(arg)
+			if !contains(options, value.String()) {
+				problem = "must be one of: " + strings.Join(options, ", ")
+			}
+		case "ton_address":
+			if _, err := utils.ParseWalletAddress(value.String()); err != nil {
+				problem = "must be a TON wallet address: " + err.Error()
+			}
+		case "url":
+			parsed, err := url.ParseRequestURI(value.String())
+			if err != nil || (parsed.Scheme != "http" && parsed.Scheme != "https") || parsed.Host == "" {
+				problem = "must be an http or https url"
+			}
+		default:
+			panic("validation: unknown rule " + rule)
+		}
+
+		if problem != "" {
+			return problem
+		}
+	}
+
+	return ""
+}
+
+func checkBound(value reflect.Value, rule string, arg string) string {
+	var ok bool
+	switch value.Kind() {
+	case reflect.String:
+		bound := mustParseInt(arg)
+		length := int64(utf8.RuneCountInString(value.String()))
+		if rule == "min" {
+			return describe(length >= bound, "must be at least %d characters", bound)
+		}
+		return describe(length <= bound, "must be at most %d characters", bound)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		bound := mustParseInt(arg)
+		ok = (rule == "min" && value.Int() >= bound) || (rule == "max" && value.Int() <= bound)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		bound, err := strconv.ParseUint(arg, 10, 64)
+		if err != nil {
+			panic("validation: bad bound " + arg)
+		}
+		ok = (rule == "min" && value.Uint() >= bound) || (rule == "max" && value.Uint() <= bound)
+	default:
+		panic("validation: " + rule + " does not apply to " + value.Kind().String())
+	}
+
+	if rule == "min" {
+		return describe(ok, "must be at least %s", arg)
+	}
+	return describe(ok, "must be at most %s", arg)
+}
+
+func describe(ok bool, format string, arg any) string {
+	if ok {
+		return ""
+	}
+	return fmt.Sprintf(format, arg)
+}
+
+func mustParseInt(arg string) int64 {
+	bound, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		panic("validation: bad bound " + arg)
+	}
+	return bound
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package validation
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/xssnick/tonutils-go/address"
+)
+
+type payload struct {
+	Name     string  `json:"name" validate:"required,min=2,max=5"`
+	Nickname string  `json:"nickname,omitempty" validate:"max=3"`
+	Amount   uint64  `json:"amount" validate:"min=1,max=100"`
+	Offset   int     `json:"offset" validate:"min=-10,max=10"`
+	Limit    *int    `json:"limit,omitempty" validate:"required,max=50"`
+	Goal     *uint64 `json:"goal,omitempty" validate:"min=10"`
+	Period   string  `json:"period,omitempty" validate:"oneof=day week"`
+	Wallet   string  `json:"wallet,omitempty" validate:"ton_address"`
+	Link     string  `json:"link,omitempty" validate:"url"`
+	Internal string  `validate:"max=1"`
+	ignored  string  `validate:"required"`
+}
+
+// friendlyWallet is the zero address in bounceable user friendly form, flagged for testnet or mainnet.
+func friendlyWallet(testnet bool) string {
+	addr := address.NewAddress(0, 0, make([]byte, 32))
+	addr.SetBounce(true)
+	addr.SetTestnetOnly(testnet)
+	return addr.String()
+}
+
+func TestStruct(t *testing.T) {
+	t.Setenv("TON_NET", "testnet")
+
+	limit := 10
+	tooBig := 51
+	smallGoal := uint64(5)
+
+	valid := func() payload {
+		return payload{Name: "alice", Amount: 1, Limit: &limit}
+	}
+
+	tests := []struct {
+		name     string
+		change   func(p *payload)
+		problems map[string]string
+	}{
+		{
+			name:   "valid",
+			change: func(p *payload) {},
+		},
+		{
+			name:     "required string",
+			change:   func(p *payload) { p.Name = "" },
+			problems: map[string]string{"name": "is required"},
+		},
+		{
+			name:     "required pointer",
+			change:   func(p *payload) { p.Limit = nil },
+			problems: map[string]string{"limit": "is required"},
+		},
+		{
+			name:     "min characters",
+			change:   func(p *payload) { p.Name = "a" },
+			problems: map[string]string{"name": "must be at least 2 characters"},
+		},
+		{
+			name:     "max characters are counted in runes",
+			change:   func(p *payload) { p.Name = "ёжики!" },
+			problems: map[string]string{"name": "must be at most 5 characters"},
+		},
+		{
+			name:   "multibyte characters within max",
+			change: func(p *payload) { p.Name = "ёжик" },
+		},
+		{
+			name:   "optional empty string skips rules",
+			change: func(p *payload) { p.Nickname = "" },
+		},
+		{
+			name:     "optional string",
+			change:   func(p *payload) { p.Nickname = "long" },
+			problems: map[string]string{"nickname": "must be at most 3 characters"},
+		},
+		{
+			name:     "min unsigned",
+			change:   func(p *payload) { p.Amount = 0 },
+			problems: map[string]string{"amount": "must be at least 1"},
+		},
+		{
+			name:     "max unsigned",
+			change:   func(p *payload) { p.Amount = 101 },
+			problems: map[string]string{"amount": "must be at most 100"},
+		},
+		{
+			name:     "min signed",
+			change:   func(p *payload) { p.Offset = -11 },
+			problems: map[string]string{"offset": "must be at least -10"},
+		},
+		{
+			name:     "max signed",
+			change:   func(p *payload) { p.Offset = 11 },
+			problems: map[string]string{"offset": "must be at most 10"},
+		},
+		{
+			name:     "max of pointed value",
+			change:   func(p *payload) { p.Limit = &tooBig },
+			problems: map[string]string{"limit": "must be at most 50"},
+		},
+		{
+			name:   "nil optional pointer skips rules",
+			change: func(p *payload) { p.Goal = nil },
+		},
+		{
+			name:     "min of optional pointer",
+			change:   func(p *payload) { p.Goal = &smallGoal },
+			problems: map[string]string{"goal": "must be at least 10"},
+		},
+		{
+			name:   "oneof",
+			change: func(p *payload) { p.Period = "week" },
+		},
+		{
+			name:     "not oneof",
+			change:   func(p *payload) { p.Period = "year" },
+			problems: map[string]string{"period": "must be one of: day, week"},
+		},
+		{
+			name:   "ton_address",
+			change: func(p *payload) { p.Wallet = friendlyWallet(true) },
+		},
+		{
+			name:   "raw ton_address",
+			change: func(p *payload) { p.Wallet = "0:" + "0000000000000000000000000000000000000000000000000000000000000000" },
+		},
+		{
+			name:     "malformed ton_address",
+			change:   func(p *payload) { p.Wallet = "wallet" },
+			problems: map[string]string{"wallet": "must be a TON wallet address: invalid TON wallet address"},
+		},
+		{
+			name:     "ton_address of another network",
+			change:   func(p *payload) { p.Wallet = friendlyWallet(false) },
+			problems: map[string]string{"wallet": "must be a TON wallet address: TON wallet address belongs to another network"},
+		},
+		{
+			name:   "url",
+			change: func(p *payload) { p.Link = "https://example.com/alice" },
+		},
+		{
+			name:     "url with another scheme",
+			change:   func(p *payload) { p.Link = "javascript:alert(1)" },
+			problems: map[string]string{"link": "must be an http or https url"},
+		},
+		{
+			name:     "relative url",
+			change:   func(p *payload) { p.Link = "/alice" },
+			problems: map[string]string{"link": "must be an http or https url"},
+		},
+		{
+			name:     "field without json name",
+			change:   func(p *payload) { p.Internal = "ab" },
+			problems: map[string]string{"Internal": "must be at most 1 characters"},
+		},
+		{
+			name: "every problem is reported",
+			change: func(p *payload) {
+				p.Name = ""
+				p.Amount = 0
+			},
+			problems: map[string]string{"name": "is required", "amount": "must be at least 1"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			p := valid()
+			test.change(&p)
+
+			problems := Struct(&p)
+			if !reflect.DeepEqual(problems, test.problems) {
+				t.Fatalf("expected problems %v, got %v", test.problems, problems)
+			}
+		})
+	}
+}
+
+func TestStructPanicsOnUnknownRule(t *testing.T) {
+	defer func() {
+		if recover() == nil {
+			t.Fatal("expected panic")
+		}
+	}()
+
+	Struct(struct {
+		Name string `validate:"email"`
+	}{Name: "alice"})
+}
@@ -0,0 +1,221 @@
+package widgets
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"regexp"
+)
+
+// Widget types, each one has its own configuration schema.
+const (
+	TypeDonationGoal    = "donation_goal"
+	TypeAlertBox        = "alert_box"
+	TypeTopDonors       = "top_donors"
+	TypeRecentDonations = "recent_donations"
+	TypeDonationCounter = "donation_counter"
+)
+
+var Types = []string{
+	TypeDonationGoal,
+	TypeAlertBox,
+	TypeTopDonors,
+	TypeRecentDonations,
+	TypeDonationCounter,
+}
+
+var ErrUnknownType = errors.New("unknown widget type")
+
+var colorRegexp = regexp.MustCompile(`^#[0-9a-fA-F]{6}$`)
+
+// Config keeps configuration of a widget, only the field of widget's type is set.
+type Config struct {
+	DonationGoal    *DonationGoalConfig    `json:"donation_goal,omitempty" bson:"donation_goal,omitempty"`
+	AlertBox        *AlertBoxConfig        `json:"alert_box,omitempty" bson:"alert_box,omitempty"`
+	TopDonors       *TopDonorsConfig       `json:"top_donors,omitempty" bson:"top_donors,omitempty"`
+	RecentDonations *RecentDonationsConfig `json:"recent_donations,omitempty" bson:"recent_donations,omitempty"`
+	DonationCounter *DonationCounterConfig `json:"donation_counter,omitempty" bson:"donation_counter,omitempty"`
+}
+
+type DonationGoalConfig struct {
+	ShowPercentage bool   `json:"show_percentage" bson:"show_percentage"`
+	BarColor       string `json:"bar_color,omitempty" bson:"bar_color,omitempty"`
+}
+
+type AlertBoxConfig struct {
+	MinAmount       uint64 `json:"min_amount" bson:"min_amount"`
+	DurationSeconds int    `json:"duration_seconds" bson:"duration_seconds"`
+	MessageTemplate string `json:"message_template" bson:"message_template"`
+	ShowMessage     bool   `json:"show_message" bson:"show_message"`
+	SoundUrl        string `json:"sound_url,omitempty" bson:"sound_url,omitempty"`
+	ImageUrl        string `json:"image_url,omitempty" bson:"image_url,omitempty"`
+}
+
+type TopDonorsConfig struct {
+	Limit  int    `json:"limit" bson:"limit"`
+	Period string `json:"period" bson:"period"`
+}
+
+type RecentDonationsConfig struct {
+	Limit        int  `json:"limit" bson:"limit"`
+	ShowMessages bool `json:"show_messages" bson:"show_messages"`
+}
+
+type DonationCounterConfig struct {
+	Metric string `json:"metric" bson:"metric"`
+}
+
+// Top donors periods.
+const (
+	PeriodAllTime = "all_time"
+	PeriodStream  = "stream"
+	PeriodMonth   = "month"
+)
+
+// Donation counter metrics.
+const (
+	MetricCount  = "count"
+	MetricAmount = "amount"
+)
+
+func IsValidType(widgetType string) bool {
+	for _, t := range Types {
+		if t == widgetType {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ParseConfig decodes and validates configuration of the widget type, missing fields get defaults.
+func ParseConfig(widgetType string, raw json.RawMessage) (Config, error) {
+	return UpdateConfig(widgetType, Config{}, raw)
+}
+
+// UpdateConfig decodes raw configuration over the current one, so only sent fields change.
+func UpdateConfig(widgetType string, current Config, raw json.RawMessage) (Config, error) {
+	var config Config
+	var err error
+
+	switch widgetType {
+	case TypeDonationGoal:
+		config.DonationGoal = &DonationGoalConfig{ShowPercentage: true}
+		if current.DonationGoal != nil {
+			*config.DonationGoal = *current.DonationGoal
+		}
+		err = decode(raw, config.DonationGoal)
+	case TypeAlertBox:
+		config.AlertBox = &AlertBoxConfig{
+			DurationSeconds: 8,
+			MessageTemplate: "{nickname} donated {amount} TON",
+			ShowMessage:     true,
+		}
+		if current.AlertBox != nil {
+			*config.AlertBox = *current.AlertBox
+		}
+		err = decode(raw, config.AlertBox)
+	case TypeTopDonors:
+		config.TopDonors = &TopDonorsConfig{Limit: 10, Period: PeriodAllTime}
+		if current.TopDonors != nil {
+			*config.TopDonors = *current.TopDonors
+		}
+		err = decode(raw, config.TopDonors)
+	case TypeRecentDonations:
+		config.RecentDonations = &RecentDonationsConfig{Limit: 5, ShowMessages: true}
+		if current.RecentDonations != nil {
+			*config.RecentDonations = *current.RecentDonations
+		}
+		err = decode(raw, config.RecentDonations)
+	case TypeDonationCounter:
+		config.DonationCounter = &DonationCounterConfig{Metric: MetricAmount}
+		if current.DonationCounter != nil {
+			*config.DonationCounter = *current.DonationCounter
+		}
+		err = decode(raw, config.DonationCounter)
+	default:
+		return config, ErrUnknownType
+	}
+	if err != nil {
+		return config, fmt.Errorf("invalid %s config: %w", widgetType, err)
+	}
+
+	return config, config.Validate()
+}
+
+func decode(raw json.RawMessage, v any) error {
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(raw))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+// Value returns the configuration of the set widget type, nil for widgets created before typed configs.
+func (c Config) Value() any {
+	switch {
+	case c.DonationGoal != nil:
+		return c.DonationGoal
+	case c.AlertBox != nil:
+		return c.AlertBox
+	case c.TopDonors != nil:
+		return c.TopDonors
+	case c.RecentDonations != nil:
+		return c.RecentDonations
+	case c.DonationCounter != nil:
+		return c.DonationCounter
+	default:
+		return nil
+	}
+}
+
+func (c Config) Validate() error {
+	if c := c.DonationGoal; c != nil {
+		if c.BarColor != "" && !colorRegexp.MatchString(c.BarColor) {
+			return errors.New("bar_color must be a #rrggbb color")
+		}
+	}
+
+	if c := c.AlertBox; c != nil {
+		if c.DurationSeconds < 1 || c.DurationSeconds > 60 {
+			return errors.New("duration_seconds must be between 1 and 60")
+		}
+		if len(c.MessageTemplate) > 200 {
+			return errors.New("message_template must be at most 200 characters")
+		}
+		if len(c.SoundUrl) > 2048 || len(c.ImageUrl) > 2048 {
+			return errors.New("sound_url and image_url must be at most 2048 characters")
+		}
+	}
+
+	if c := c.TopDonors; c != nil {
+		if c.Limit < 1 || c.Limit > 50 {
+			return errors.New("limit must be between 1 and 50")
+		}
+		if c.Period != PeriodAllTime && c.Period != PeriodStream && c.Period != PeriodMonth {
+			return fmt.Errorf("period must be one of %s, %s, %s", PeriodAllTime, PeriodStream, PeriodMonth)
+		}
+	}
+
+	if c := c.RecentDonations; c != nil {
+		if c.Limit < 1 || c.Limit > 50 {
+			return errors.New("limit must be between 1 and 50")
+		}
+	}
+
+	if c := c.DonationCounter; c != nil {
+		if c.Metric != MetricCount && c.Metric != MetricAmount {
+			return fmt.Errorf("metric must be one of %s, %s", MetricCount, MetricAmount)
+		}
+	}
+
+	return nil
+}
+
+// HasGoal tells if widgets of the type track amount towards a goal.
+func HasGoal(widgetType string) bool {
+	return widgetType == TypeDonationGoal
+}